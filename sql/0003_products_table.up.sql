@@ -51,3 +51,30 @@ CREATE TABLE `products_player` (
   KEY `idx_status` (`status`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='商品播放信息表';
 
+-- @Author AInoriex
+-- @Desc 新增字段key_file, 记录hls分片AES-128加密密钥文件
+-- @Chge 2026年10月18日 新增字段key_file
+ALTER TABLE `eshop`.`products_player`
+ADD COLUMN `key_file` varchar(64) NOT NULL DEFAULT '' COMMENT '加密密钥文件' AFTER `play_url`;
+
+-- @Author AInoriex
+-- @Desc 新增字段preview_url, preview_duration, 记录公开试听片段
+-- @Chge 2026年10月18日 新增字段preview_url, preview_duration
+ALTER TABLE `eshop`.`products_player`
+ADD COLUMN `preview_url` varchar(255) NOT NULL DEFAULT '' COMMENT '试听地址' AFTER `key_file`,
+ADD COLUMN `preview_duration` int(11) NOT NULL DEFAULT '0' COMMENT '试听时长' AFTER `preview_url`;
+
+-- @Author AInoriex
+-- @Desc 新增字段hifi_url, 记录无损音轨hi-fi播放地址
+-- @Chge 2026年10月18日 新增字段hifi_url
+ALTER TABLE `eshop`.`products_player`
+ADD COLUMN `hifi_url` varchar(255) NOT NULL DEFAULT '' COMMENT '无损播放地址' AFTER `play_url`;
+
+-- @Author AInoriex
+-- @Desc 新增字段disc_no, track_no, title, 记录专辑曲目排序及曲目标题
+-- @Chge 2026年10月18日 新增字段disc_no, track_no, title
+ALTER TABLE `eshop`.`products_player`
+ADD COLUMN `disc_no` int(11) NOT NULL DEFAULT '1' COMMENT '碟号' AFTER `product_id`,
+ADD COLUMN `track_no` int(11) NOT NULL DEFAULT '0' COMMENT '曲目序号' AFTER `disc_no`,
+ADD COLUMN `title` varchar(255) NOT NULL DEFAULT '' COMMENT '曲目标题' AFTER `track_no`,
+ADD KEY `idx_product_track` (`product_id`, `disc_no`, `track_no`);
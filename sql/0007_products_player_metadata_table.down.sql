@@ -0,0 +1,4 @@
+-- 切换到eshop数据库
+USE eshop;
+-- 丢弃表结构和数据
+DROP TABLE IF EXISTS `products_player_metadata`;
@@ -0,0 +1,34 @@
+-- 切换到eshop数据库
+USE eshop;
+
+-- @Author AInoriex
+-- @Desc 商品播放文件元数据, 入库时由ffprobe/ebur128解析, 与products_player一对一
+-- @Chge 2026年10月18日 创建表products_player_metadata
+CREATE TABLE `products_player_metadata` (
+  `player_id` varchar(32) NOT NULL COMMENT '播放id',
+  `product_id` varchar(32) NOT NULL COMMENT '商品id',
+  `format_name` varchar(32) NOT NULL DEFAULT '' COMMENT '封装格式',
+  `codec` varchar(32) NOT NULL DEFAULT '' COMMENT '音频编码',
+  `sample_rate` int(11) NOT NULL DEFAULT '0' COMMENT '采样率（Hz）',
+  `channels` int(11) NOT NULL DEFAULT '0' COMMENT '声道数',
+  `channel_layout` varchar(32) NOT NULL DEFAULT '' COMMENT '声道布局',
+  `bit_rate` int(11) NOT NULL DEFAULT '0' COMMENT '码率（bps）',
+  `bits_per_sample` int(11) NOT NULL DEFAULT '0' COMMENT '采样位深',
+  `duration` decimal(12,3) NOT NULL DEFAULT '0.000' COMMENT '精确时长（秒）',
+  `integrated_loudness` decimal(6,2) DEFAULT NULL COMMENT '综合响度（LUFS）',
+  `loudness_range` decimal(6,2) DEFAULT NULL COMMENT '响度范围（LU）',
+  `true_peak` decimal(6,2) DEFAULT NULL COMMENT '真峰值（dBTP）',
+  `title` varchar(255) NOT NULL DEFAULT '' COMMENT '标签 标题',
+  `artist` varchar(255) NOT NULL DEFAULT '' COMMENT '标签 艺术家',
+  `album` varchar(255) NOT NULL DEFAULT '' COMMENT '标签 专辑',
+  `album_artist` varchar(255) NOT NULL DEFAULT '' COMMENT '标签 专辑艺术家',
+  `genre` varchar(64) NOT NULL DEFAULT '' COMMENT '标签 流派',
+  `track` varchar(16) NOT NULL DEFAULT '' COMMENT '标签 音轨号',
+  `date` varchar(32) NOT NULL DEFAULT '' COMMENT '标签 日期',
+  `tags` text COMMENT '全部标签（json）',
+  `cover_url` varchar(255) NOT NULL DEFAULT '' COMMENT '封面地址',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` datetime DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`player_id`),
+  KEY `idx_product_id` (`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='商品播放文件元数据表';
@@ -0,0 +1,25 @@
+-- 切换到eshop数据库
+USE eshop;
+
+-- @Author AInoriex
+-- @Desc 播放会话统计, 由流媒体服务按播放凭证聚合分片请求后定期落库
+-- @Chge 2026年10月18日 创建表products_playback_session
+CREATE TABLE `products_playback_session` (
+  `id` varchar(64) NOT NULL COMMENT '播放会话id',
+  `user_id` varchar(32) NOT NULL COMMENT '用户id',
+  `player_id` varchar(32) NOT NULL COMMENT '播放id',
+  `product_id` varchar(32) NOT NULL DEFAULT '' COMMENT '商品id',
+  `requests` int(11) NOT NULL DEFAULT '0' COMMENT '分片请求次数',
+  `segments` int(11) NOT NULL DEFAULT '0' COMMENT '不重复分片数',
+  `listened_seconds` int(11) NOT NULL DEFAULT '0' COMMENT '估算收听时长（秒）',
+  `duration` int(11) NOT NULL DEFAULT '0' COMMENT '文件时长（秒）',
+  `client_ip` varchar(64) NOT NULL DEFAULT '' COMMENT '客户端ip',
+  `started_at` datetime NOT NULL COMMENT '开始播放时间',
+  `last_played_at` datetime NOT NULL COMMENT '最后请求时间',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` datetime DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_product_started` (`product_id`, `started_at`),
+  KEY `idx_started_at` (`started_at`),
+  KEY `idx_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='播放会话统计表';
@@ -0,0 +1,8 @@
+-- 切换到eshop数据库
+USE eshop;
+
+-- @Author  AInoriex
+-- @Des     purchase_history表新增下载次数字段, 用于限制每笔购买的下载次数
+-- @Create  2026年10月18日
+ALTER TABLE `eshop`.`purchase_history`
+ADD COLUMN `download_count` int(11) NOT NULL DEFAULT '0' COMMENT '已下载次数' AFTER `order_id`;
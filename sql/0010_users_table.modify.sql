@@ -0,0 +1,10 @@
+-- 切换到eshop数据库
+USE eshop;
+
+-- @Author  AInoriex
+-- @Des     用户表新增字段：登录失败次数、账户临时锁定截止时间, 用于防止暴力破解
+-- @Create  2026年10月18日
+ALTER TABLE users
+ADD COLUMN `login_attempts` int(11) NOT NULL DEFAULT '0' COMMENT '锁定前连续登录失败次数' AFTER `banned_at`,
+ADD COLUMN `locked_until` datetime DEFAULT NULL COMMENT '账户临时锁定截止时间' AFTER `login_attempts`,
+ADD KEY `idx_locked_until` (`locked_until`);
@@ -0,0 +1,5 @@
+-- 切换到eshop数据库
+USE eshop;
+-- 丢弃表结构和数据
+DROP TABLE IF EXISTS `role_permissions`;
+DROP TABLE IF EXISTS `roles`;
@@ -0,0 +1,40 @@
+-- 切换到eshop数据库
+USE eshop;
+
+-- @Author AInoriex
+-- @Desc 运营角色及其权限, 用户通过users.roles关联角色名称
+-- @Desc 内置角色admin（全部权限）、user（普通用户, 无后台权限）不在表中维护
+-- @Chge 2026年10月18日 创建表roles、role_permissions
+CREATE TABLE `roles` (
+  `name` varchar(32) NOT NULL COMMENT '角色名称',
+  `description` varchar(255) NOT NULL DEFAULT '' COMMENT '角色描述',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` datetime DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='角色表';
+
+CREATE TABLE `role_permissions` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增唯一ID',
+  `role` varchar(32) NOT NULL COMMENT '角色名称',
+  `permission` varchar(64) NOT NULL COMMENT '权限标识',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_role_permission` (`role`, `permission`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='角色权限表';
+
+-- 预置运营角色
+INSERT INTO `roles` (`name`, `description`) VALUES
+('catalog_editor', '商品编辑：维护商品及音频资源'),
+('support', '客服：查看用户及订单, 不可修改商品'),
+('finance', '财务：查看订单及数据报表');
+
+INSERT INTO `role_permissions` (`role`, `permission`) VALUES
+('catalog_editor', 'product:read'),
+('catalog_editor', 'product:write'),
+('catalog_editor', 'player:read'),
+('catalog_editor', 'player:write'),
+('support', 'user:read'),
+('support', 'order:read'),
+('support', 'product:read'),
+('finance', 'order:read'),
+('finance', 'analytics:read');
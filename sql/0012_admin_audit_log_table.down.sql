@@ -0,0 +1,6 @@
+-- 切换到eshop数据库
+USE eshop;
+-- 丢弃表结构和数据
+DROP TRIGGER IF EXISTS `trg_admin_audit_log_no_update`;
+DROP TRIGGER IF EXISTS `trg_admin_audit_log_no_delete`;
+DROP TABLE IF EXISTS `admin_audit_log`;
@@ -0,0 +1,33 @@
+-- 切换到eshop数据库
+USE eshop;
+
+-- @Author AInoriex
+-- @Desc 管理后台操作审计日志, 记录每次/admin写操作的操作人、目标及变更前后数据
+-- @Desc 仅允许追加写入, 由触发器拒绝更新及删除
+-- @Chge 2026年10月18日 创建表admin_audit_log
+CREATE TABLE `admin_audit_log` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增唯一ID',
+  `request_id` varchar(64) NOT NULL DEFAULT '' COMMENT '请求id',
+  `actor_id` varchar(32) NOT NULL DEFAULT '' COMMENT '操作人用户id',
+  `actor_roles` varchar(255) NOT NULL DEFAULT '' COMMENT '操作人角色, 逗号分隔',
+  `action` varchar(128) NOT NULL COMMENT '操作, 请求方法及路由',
+  `target_type` varchar(32) NOT NULL DEFAULT '' COMMENT '目标实体类型',
+  `target_id` varchar(64) NOT NULL DEFAULT '' COMMENT '目标实体id',
+  `before_data` text COMMENT '变更前数据（JSON, 仅包含变更字段）',
+  `after_data` text COMMENT '变更后数据（JSON, 仅包含变更字段）',
+  `ip` varchar(64) NOT NULL DEFAULT '' COMMENT '操作人ip',
+  `http_status` int(11) NOT NULL DEFAULT '0' COMMENT 'http响应状态码',
+  `result_code` int(11) NOT NULL DEFAULT '0' COMMENT '接口响应错误码, 0为成功',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_actor_created` (`actor_id`, `created_at`),
+  KEY `idx_target` (`target_type`, `target_id`),
+  KEY `idx_request_id` (`request_id`),
+  KEY `idx_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理后台操作审计日志表';
+
+CREATE TRIGGER `trg_admin_audit_log_no_update` BEFORE UPDATE ON `admin_audit_log`
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'admin_audit_log is append-only';
+
+CREATE TRIGGER `trg_admin_audit_log_no_delete` BEFORE DELETE ON `admin_audit_log`
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'admin_audit_log is append-only';
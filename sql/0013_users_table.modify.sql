@@ -0,0 +1,9 @@
+-- 切换到eshop数据库
+USE eshop;
+
+-- @Author  AInoriex
+-- @Des     用户表新增字段：TOTP密钥、TOTP启用时间, 用于后台账号两步验证
+-- @Create  2026年10月18日
+ALTER TABLE users
+ADD COLUMN `totp_secret` varchar(64) DEFAULT NULL COMMENT 'TOTP密钥（base32）, 为空表示未绑定验证器' AFTER `locked_until`,
+ADD COLUMN `totp_enabled_at` datetime DEFAULT NULL COMMENT 'TOTP启用时间' AFTER `totp_secret`;
@@ -0,0 +1,15 @@
+-- 切换到eshop数据库
+USE eshop;
+
+-- @Author AInoriex
+-- @Desc 两步验证恢复码, 仅保存sha256哈希, 每个恢复码只能使用一次
+-- @Chge 2026年10月18日 创建表user_recovery_codes
+CREATE TABLE `user_recovery_codes` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增唯一ID',
+  `user_id` varchar(32) NOT NULL COMMENT '用户ID',
+  `code_hash` char(64) NOT NULL COMMENT '恢复码sha256哈希',
+  `used_at` datetime DEFAULT NULL COMMENT '使用时间, 为空表示未使用',
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uniq_user_code` (`user_id`, `code_hash`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='两步验证恢复码表';
@@ -40,6 +40,7 @@ type Response struct {
 // Success 响应成功 ErrorCode 为 0 表示成功
 func Success(c *gin.Context, data interface{}) {
 	c.Header("Server-Api-Version", ServerApiVersion)
+	c.Set(KeyEventAttrApiErrorCode, int32(0))
 	c.JSON(http.StatusOK, Response{
 		0,
 		data,
@@ -50,6 +51,7 @@ func Success(c *gin.Context, data interface{}) {
 // Fail 响应失败 ErrorCode 不为 0 表示失败
 func Fail(c *gin.Context, errorCode int32, msg string) {
 	c.Header("Server-Api-Version", ServerApiVersion)
+	c.Set(KeyEventAttrApiErrorCode, errorCode)
 	c.JSON(http.StatusOK, Response{
 		errorCode,
 		struct{}{},
@@ -59,6 +61,7 @@ func Fail(c *gin.Context, errorCode int32, msg string) {
 
 // Fail 响应失败 ErrorCode 不为 0 表示失败
 func FailWithDataMap(c *gin.Context, errorCode int32, msg string, dataMap interface{}) {
+	c.Set(KeyEventAttrApiErrorCode, errorCode)
 	c.JSON(http.StatusOK, Response{
 		errorCode,
 		dataMap,
@@ -68,6 +71,7 @@ func FailWithDataMap(c *gin.Context, errorCode int32, msg string, dataMap interf
 
 func FailWithAuthorization(c *gin.Context) {
 	c.Header("Server-Api-Version", ServerApiVersion)
+	c.Set(KeyEventAttrApiErrorCode, int32(-1))
 	c.AbortWithStatusJSON(http.StatusUnauthorized, Response{
 		-1,
 		struct{}{},
@@ -77,6 +81,7 @@ func FailWithAuthorization(c *gin.Context) {
 
 func FailWithFileNotFound(c *gin.Context) {
 	c.Header("Server-Api-Version", ServerApiVersion)
+	c.Set(KeyEventAttrApiErrorCode, int32(-1))
 	c.AbortWithStatusJSON(http.StatusNotFound, Response{
 		-1,
 		struct{}{},
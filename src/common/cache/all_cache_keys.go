@@ -6,24 +6,91 @@ import (
 
 const (
 	// jxs用户登录态
-	KeyJxsUserToken         string = "JxsUser:%v" // userId
-	KeyJxsUserTokenTimeout         = 30 * 60      // 用户Token有效时长30分钟
-	KeyJxsAdminTokenTimeout        = 120 * 60     // 后台用户Token有效时长120分钟
+	KeyJxsUserTokenTimeout  = 30 * 60  // 用户Token有效时长30分钟
+	KeyJxsAdminTokenTimeout = 120 * 60 // 后台用户Token有效时长120分钟
+
+	// jxs用户登录会话, 有效时长由配置的刷新凭证有效时长决定
+	KeyJxsUserSession        string = "JxsSession:%v"         // sessionId
+	KeyJxsUserSessionRotated string = "JxsSession:Rotated:%v" // sessionId, 已轮换的刷新凭证哈希
+	KeyJxsUserSessions       string = "JxsSessions:%v"        // userId
 
 	// jxs邮箱验证
 	KeyJxsVerifyMailCode          string = "JxsVEmailCode:%v:%v" // ip:toEmail
 	KeyJxsVerifyMailCodeMinsLimit        = 5
 	KeyJxsVerifyMailCodeTimeout          = KeyJxsVerifyMailCodeMinsLimit * 60 // 邮箱验证码有效时长5分钟
 
+	// jxs找回密码
+	KeyJxsResetPasswordCode          string = "JxsResetPwdCode:%v" // toEmail
+	KeyJxsResetPasswordSend          string = "JxsResetPwdSend:%v" // toEmail
+	KeyJxsResetPasswordCodeMinsLimit        = 15
+	KeyJxsResetPasswordCodeTimeout          = KeyJxsResetPasswordCodeMinsLimit * 60 // 找回密码验证码有效时长15分钟
+	KeyJxsResetPasswordSendTimeout          = 60                                    // 找回密码邮件发送间隔60秒
+	KeyJxsResetPasswordMaxAttempts          = 5                                     // 找回密码验证码最大尝试次数
+
+	// jxs登录失败计数, 有效时长由配置的计数窗口决定
+	KeyJxsLoginFailAccount string = "JxsLoginFail:Account:%v" // user_id或规范化的email
+	KeyJxsLoginFailIp      string = "JxsLoginFail:Ip:%v"      // ip
+
+	// jxs角色权限
+	KeyJxsRolePermissions        string = "JxsRolePerms:%v" // role
+	KeyJxsRolePermissionsTimeout        = 10 * 60           // 角色权限缓存有效时长10分钟
+
+	// jxs后台两步验证
+	KeyJxsMfaChallenge            string = "JxsMfa:Challenge:%v"   // 两步验证凭证哈希
+	KeyJxsMfaChallengeTimeout            = 5 * 60                  // 两步验证凭证有效时长5分钟
+	KeyJxsMfaChallengeMaxAttempts        = 5                       // 两步验证凭证最大尝试次数
+	KeyJxsTotpUsedStep            string = "JxsMfa:TotpUsed:%v:%v" // userId:时间步, 防止验证码重放
+	KeyJxsTotpUsedStepTimeout            = 3 * 30                  // 覆盖验证码允许偏差的全部时间步
+
 	// ylt登录态
 	KeyYltUserPrefix       string = "YltUser"
 	KeyYltUserToken        string = KeyYltUserPrefix + ":%v" // phone
 	KeyYltUserTokenTimeout        = 3 * 60 * 60              // YLT Token有效时长3小时
+
+	// 流媒体转码任务
+	KeyStreamTranscodeQueue        string = "StreamTranscode:Queue"         // 待处理队列
+	KeyStreamTranscodeProcessing   string = "StreamTranscode:Processing:%v" // nodeId, 节点处理中队列
+	KeyStreamTranscodeNodes        string = "StreamTranscode:Nodes"         // 拥有处理中队列的节点集合
+	KeyStreamTranscodeLease        string = "StreamTranscode:Lease:%v"      // nodeId, 节点租约, 由心跳续期
+	KeyStreamTranscodeLeaseTimeout        = 30                              // 节点租约有效时长30秒, 过期后回收其处理中任务
+	KeyStreamTranscodeJob          string = "StreamTranscode:Job:%v"        // playerId
+	KeyStreamTranscodeJobTimeout          = 7 * 24 * 60 * 60                // 转码任务状态保留7天
+
+	// 流媒体分片上传
+	KeyStreamUpload                string = "StreamUpload:%v"          // uploadId
+	KeyStreamUploadChunks          string = "StreamUpload:Chunks:%v"   // uploadId
+	KeyStreamUploadComplete        string = "StreamUpload:Complete:%v" // uploadId
+	KeyStreamUploadTimeout                = 24 * 60 * 60               // 分片上传任务有效时长24小时
+	KeyStreamUploadCompleteTimeout        = 10 * 60                    // 合并分片锁有效时长10分钟
+
+	// 流媒体播放统计
+	KeyStreamPlayback         string = "StreamPlayback:Session:%v"  // sessionId
+	KeyStreamPlaybackSegments string = "StreamPlayback:Segments:%v" // sessionId
+	KeyStreamPlaybackDirty    string = "StreamPlayback:Dirty"       // 待落库的播放会话
+	KeyStreamPlaybackTimeout         = 24 * 60 * 60                 // 播放会话保留24小时
+
+	// 流媒体并发播放限制及共享检测, 有效时长由配置的统计窗口决定
+	KeyStreamActiveSessions string = "StreamActive:Sessions:%v" // userId
+	KeyStreamActiveIps      string = "StreamActive:Ips:%v"      // userId
+	KeyStreamSharingAlert   string = "StreamActive:Alert:%v"    // userId
+
+	// 流媒体购买下载凭证, 有效时长由配置的下载链接有效时长决定
+	KeyStreamDownload string = "StreamDownload:%v" // ticketId
 )
 
-// jxs用户登录态Key
-func GetJxsUserTokenKey(userId string) string {
-	return fmt.Sprintf(KeyJxsUserToken, userId)
+// jxs用户登录会话Key
+func GetJxsUserSessionKey(sessionId string) string {
+	return fmt.Sprintf(KeyJxsUserSession, sessionId)
+}
+
+// jxs用户登录会话已轮换刷新凭证Key
+func GetJxsUserSessionRotatedKey(sessionId string) string {
+	return fmt.Sprintf(KeyJxsUserSessionRotated, sessionId)
+}
+
+// jxs用户登录会话列表Key
+func GetJxsUserSessionsKey(userId string) string {
+	return fmt.Sprintf(KeyJxsUserSessions, userId)
 }
 
 // jxs邮箱验证Key
@@ -31,7 +98,101 @@ func GetJxsVerifyMailCodeKey(ip string, toEmail string) string {
 	return fmt.Sprintf(KeyJxsVerifyMailCode, ip, toEmail)
 }
 
+// jxs找回密码验证码Key
+func GetJxsResetPasswordCodeKey(toEmail string) string {
+	return fmt.Sprintf(KeyJxsResetPasswordCode, toEmail)
+}
+
+// jxs找回密码邮件发送间隔Key
+func GetJxsResetPasswordSendKey(toEmail string) string {
+	return fmt.Sprintf(KeyJxsResetPasswordSend, toEmail)
+}
+
+// jxs账号登录失败计数Key
+func GetJxsLoginFailAccountKey(account string) string {
+	return fmt.Sprintf(KeyJxsLoginFailAccount, account)
+}
+
+// jxs ip登录失败计数Key
+func GetJxsLoginFailIpKey(ip string) string {
+	return fmt.Sprintf(KeyJxsLoginFailIp, ip)
+}
+
+func GetJxsRolePermissionsKey(role string) string {
+	return fmt.Sprintf(KeyJxsRolePermissions, role)
+}
+
+// jxs后台两步验证凭证Key
+func GetJxsMfaChallengeKey(tokenHash string) string {
+	return fmt.Sprintf(KeyJxsMfaChallenge, tokenHash)
+}
+
+// jxs已使用的TOTP时间步Key
+func GetJxsTotpUsedStepKey(userId string, step int64) string {
+	return fmt.Sprintf(KeyJxsTotpUsedStep, userId, step)
+}
+
 // ylt用户登录态Key
 func GetYltUserTokenKey(phone string) string {
 	return fmt.Sprintf(KeyYltUserToken, phone)
 }
+
+// 流媒体转码节点处理中队列Key
+func GetStreamTranscodeProcessingKey(nodeId string) string {
+	return fmt.Sprintf(KeyStreamTranscodeProcessing, nodeId)
+}
+
+// 流媒体转码节点租约Key
+func GetStreamTranscodeLeaseKey(nodeId string) string {
+	return fmt.Sprintf(KeyStreamTranscodeLease, nodeId)
+}
+
+// 流媒体转码任务Key
+func GetStreamTranscodeJobKey(playerId string) string {
+	return fmt.Sprintf(KeyStreamTranscodeJob, playerId)
+}
+
+// 流媒体分片上传任务Key
+func GetStreamUploadKey(uploadId string) string {
+	return fmt.Sprintf(KeyStreamUpload, uploadId)
+}
+
+// 流媒体分片上传已接收分片Key
+func GetStreamUploadChunksKey(uploadId string) string {
+	return fmt.Sprintf(KeyStreamUploadChunks, uploadId)
+}
+
+// 流媒体分片上传合并锁Key
+func GetStreamUploadCompleteKey(uploadId string) string {
+	return fmt.Sprintf(KeyStreamUploadComplete, uploadId)
+}
+
+// 流媒体播放会话Key
+func GetStreamPlaybackKey(sessionId string) string {
+	return fmt.Sprintf(KeyStreamPlayback, sessionId)
+}
+
+// 流媒体播放会话已请求分片Key
+func GetStreamPlaybackSegmentsKey(sessionId string) string {
+	return fmt.Sprintf(KeyStreamPlaybackSegments, sessionId)
+}
+
+// 流媒体用户活跃播放会话Key
+func GetStreamActiveSessionsKey(userId string) string {
+	return fmt.Sprintf(KeyStreamActiveSessions, userId)
+}
+
+// 流媒体用户播放ip Key
+func GetStreamActiveIpsKey(userId string) string {
+	return fmt.Sprintf(KeyStreamActiveIps, userId)
+}
+
+// 流媒体账号共享告警Key
+func GetStreamSharingAlertKey(userId string) string {
+	return fmt.Sprintf(KeyStreamSharingAlert, userId)
+}
+
+// 流媒体购买下载凭证Key
+func GetStreamDownloadKey(ticketId string) string {
+	return fmt.Sprintf(KeyStreamDownload, ticketId)
+}
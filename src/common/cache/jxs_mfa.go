@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uredis"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 保存jxs后台两步验证凭证, 密码校验通过后签发, 有效期内完成两步验证才签发登录会话
+func SaveJxsMfaChallenge(tokenHash string, userId string) error {
+	ctx := context.Background()
+	key := GetJxsMfaChallengeKey(tokenHash)
+	_, err := uredis.RedisCon.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", userId, "attempts", 0)
+		pipe.Expire(ctx, key, KeyJxsMfaChallengeTimeout*time.Second)
+		return nil
+	})
+	log.Debugf("SaveJxsMfaChallenge params, userId:%s, err:%v", userId, err)
+	return err
+}
+
+// 校验两步验证凭证时计入尝试次数, 超过上限时删除凭证并返回空
+var takeMfaChallengeScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+local attempts = redis.call("HINCRBY", KEYS[1], "attempts", 1)
+if attempts > tonumber(ARGV[1]) then
+	redis.call("DEL", KEYS[1])
+	return false
+end
+return redis.call("HMGET", KEYS[1], "user_id", "secret")
+`)
+
+// 获取jxs后台两步验证凭证, 每次获取计入尝试次数, 超过最大尝试次数后凭证失效
+// @return secret 绑定中的待确认TOTP密钥, 未发起绑定时为空
+func TakeJxsMfaChallenge(tokenHash string) (userId string, secret string, ok bool) {
+	key := GetJxsMfaChallengeKey(tokenHash)
+	values, err := takeMfaChallengeScript.Run(context.Background(), uredis.RedisCon, []string{key}, KeyJxsMfaChallengeMaxAttempts).Slice()
+	if err != nil {
+		if err != redis.Nil {
+			log.Errorf("TakeJxsMfaChallenge redis错误, err:%v", err)
+		}
+		return "", "", false
+	}
+	if len(values) != 2 {
+		return "", "", false
+	}
+	userId, _ = values[0].(string)
+	secret, _ = values[1].(string)
+	return userId, secret, userId != ""
+}
+
+// 保存jxs后台两步验证绑定中的待确认TOTP密钥
+func SetJxsMfaChallengeSecret(tokenHash string, secret string) error {
+	key := GetJxsMfaChallengeKey(tokenHash)
+	err := uredis.RedisCon.HSet(context.Background(), key, "secret", secret).Err()
+	log.Debugf("SetJxsMfaChallengeSecret params, err:%v", err)
+	return err
+}
+
+// 删除jxs后台两步验证凭证
+func DelJxsMfaChallenge(tokenHash string) bool {
+	key := GetJxsMfaChallengeKey(tokenHash)
+	err := uredis.DelKey(uredis.RedisCon, key)
+	log.Debugf("DelJxsMfaChallenge params, err:%v", err)
+	return err == nil
+}
+
+// 占用jxs已使用的TOTP时间步, 同一验证码重复使用时返回false
+func LockJxsTotpUsedStep(userId string, step int64) (bool, error) {
+	key := GetJxsTotpUsedStepKey(userId, step)
+	return uredis.SetNx(uredis.RedisCon, key, 1, KeyJxsTotpUsedStepTimeout)
+}
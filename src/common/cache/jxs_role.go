@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"encoding/json"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uredis"
+)
+
+// 获取角色权限缓存
+// @return ok 缓存是否存在
+func GetJxsRolePermissions(role string) (ok bool, permissions []string) {
+	key := GetJxsRolePermissionsKey(role)
+	b, err := uredis.GetString(uredis.RedisCon, key)
+	if err != nil || b == nil {
+		return false, nil
+	}
+	if err = json.Unmarshal(b, &permissions); err != nil {
+		log.Errorf("GetJxsRolePermissions 解析缓存失败, role:%s, err:%v", role, err)
+		return false, nil
+	}
+	return true, permissions
+}
+
+// 保存角色权限缓存, 角色不存在或无权限时保存空列表
+func SaveJxsRolePermissions(role string, permissions []string) error {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	b, _ := json.Marshal(permissions)
+	key := GetJxsRolePermissionsKey(role)
+	err := uredis.SetString(uredis.RedisCon, key, b, KeyJxsRolePermissionsTimeout)
+	log.Debugf("SaveJxsRolePermissions params, role:%s, permissions:%v, err:%v", role, permissions, err)
+	return err
+}
+
+// 删除角色权限缓存, 角色权限变更时调用
+func DelJxsRolePermissions(role string) error {
+	key := GetJxsRolePermissionsKey(role)
+	err := uredis.DelKey(uredis.RedisCon, key)
+	log.Debugf("DelJxsRolePermissions params, role:%s, err:%v", role, err)
+	return err
+}
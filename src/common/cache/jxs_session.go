@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uredis"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	JxsUserSessionRotateOk      int64 = 1  // 刷新凭证轮换成功
+	JxsUserSessionRotateInvalid int64 = 0  // 会话不存在或刷新凭证无效
+	JxsUserSessionRotateReused  int64 = -1 // 已轮换的刷新凭证被重复使用
+)
+
+// 保存jxs用户登录会话, 并加入用户会话列表
+// @param fields 会话信息 user_id/device/ip/created_at/last_seen/refresh_hash/mfa
+func SaveJxsUserSession(userId string, sessionId string, fields map[string]interface{}, timeout int64) error {
+	ctx := context.Background()
+	key := GetJxsUserSessionKey(sessionId)
+	sessionsKey := GetJxsUserSessionsKey(userId)
+	_, err := uredis.RedisCon.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, fields)
+		pipe.Expire(ctx, key, time.Duration(timeout)*time.Second)
+		pipe.ZAdd(ctx, sessionsKey, &redis.Z{Score: float64(time.Now().Unix()), Member: sessionId})
+		pipe.Expire(ctx, sessionsKey, time.Duration(timeout)*time.Second)
+		return nil
+	})
+	log.Debugf("SaveJxsUserSession params, userId:%s, sessionId:%s, err:%v", userId, sessionId, err)
+	return err
+}
+
+// 获取jxs用户登录会话, 会话不存在时返回空
+func GetJxsUserSession(sessionId string) (map[string]string, error) {
+	return uredis.GetHashAll(uredis.RedisCon, GetJxsUserSessionKey(sessionId))
+}
+
+// 更新jxs用户登录会话最近访问时间及ip
+func TouchJxsUserSession(sessionId string, ip string) error {
+	key := GetJxsUserSessionKey(sessionId)
+	return uredis.RedisCon.HSet(context.Background(), key, "ip", ip, "last_seen", time.Now().Unix()).Err()
+}
+
+// 轮换刷新凭证: 与当前凭证一致时替换为新凭证并延长会话有效时长, 与已轮换的凭证一致时视为重复使用
+var rotateUserSessionScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+if redis.call("HGET", KEYS[1], "refresh_hash") == ARGV[1] then
+	redis.call("HSET", KEYS[1], "refresh_hash", ARGV[2], "ip", ARGV[3], "last_seen", ARGV[4])
+	redis.call("SADD", KEYS[2], ARGV[1])
+	redis.call("EXPIRE", KEYS[1], ARGV[5])
+	redis.call("EXPIRE", KEYS[2], ARGV[5])
+	redis.call("EXPIRE", KEYS[3], ARGV[5])
+	return 1
+end
+if redis.call("SISMEMBER", KEYS[2], ARGV[1]) == 1 then
+	return -1
+end
+return 0
+`)
+
+// 轮换jxs用户登录会话刷新凭证
+// @return JxsUserSessionRotateOk/JxsUserSessionRotateInvalid/JxsUserSessionRotateReused
+func RotateJxsUserSession(userId string, sessionId string, refreshHash string, newRefreshHash string, ip string, timeout int64) (int64, error) {
+	keys := []string{GetJxsUserSessionKey(sessionId), GetJxsUserSessionRotatedKey(sessionId), GetJxsUserSessionsKey(userId)}
+	result, err := rotateUserSessionScript.Run(context.Background(), uredis.RedisCon, keys, refreshHash, newRefreshHash, ip, time.Now().Unix(), timeout).Int64()
+	log.Debugf("RotateJxsUserSession params, userId:%s, sessionId:%s, result:%d, err:%v", userId, sessionId, result, err)
+	return result, err
+}
+
+// 获取jxs用户登录会话id列表, 按创建时间升序
+func GetJxsUserSessionIds(userId string) ([]string, error) {
+	return uredis.ZRange(uredis.RedisCon, GetJxsUserSessionsKey(userId), 0, -1)
+}
+
+// 删除jxs用户登录会话
+func DelJxsUserSession(userId string, sessionId string) error {
+	ctx := context.Background()
+	_, err := uredis.RedisCon.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, GetJxsUserSessionKey(sessionId), GetJxsUserSessionRotatedKey(sessionId))
+		pipe.ZRem(ctx, GetJxsUserSessionsKey(userId), sessionId)
+		return nil
+	})
+	log.Debugf("DelJxsUserSession params, userId:%s, sessionId:%s, err:%v", userId, sessionId, err)
+	return err
+}
+
+// 删除jxs用户所有登录会话
+func DelJxsUserSessions(userId string) error {
+	sessionIds, err := GetJxsUserSessionIds(userId)
+	if err != nil {
+		return err
+	}
+	keys := []string{GetJxsUserSessionsKey(userId)}
+	for _, sessionId := range sessionIds {
+		keys = append(keys, GetJxsUserSessionKey(sessionId), GetJxsUserSessionRotatedKey(sessionId))
+	}
+	err = uredis.DelKey(uredis.RedisCon, keys...)
+	log.Debugf("DelJxsUserSessions params, userId:%s, sessions:%d, err:%v", userId, len(sessionIds), err)
+	return err
+}
@@ -1,41 +1,15 @@
 package cache
 
 import (
+	"context"
+	"crypto/subtle"
 	"eshop_server/src/utils/log"
 	"eshop_server/src/utils/uredis"
-)
-
-// 获取jxs用户登录态
-func GetJxsUserToken(userId string) (bool, string) {
-	key := GetJxsUserTokenKey(userId)
-	b, err := uredis.GetString(uredis.RedisCon, key)
-	if err != nil {
-		//log.Errorf("GetJxsUserToken redis错误", zap.Any("data", b), zap.Error(err))
-		return false, ""
-	} else {
-		if b == nil {
-			//log.Errorf("GetJxsUserToken redis为空", zap.Any("data", b), zap.Error(err))
-			return false, ""
-		}
-		return true, string(b)
-	}
-}
-
-// 保存jxs用户登录态
-func SaveJxsUserToken(userId string, token string) error {
-	key := GetJxsUserTokenKey(userId)
-	err := uredis.SetString(uredis.RedisCon, key, token, KeyJxsUserTokenTimeout)
-	log.Debugf("SaveJxsUserToken params, userId:%s, err:%v", userId, err)
-	return err
-}
+	"strconv"
+	"time"
 
-// 删除jxs用户登录态
-func DelJxsUserToken(userId string) bool {
-	key := GetJxsUserTokenKey(userId)
-	err := uredis.DelKey(uredis.RedisCon, key)
-	log.Debugf("DelJxsUserToken params, userId:%s, err:%v", userId, err)
-	return err == nil
-}
+	"github.com/go-redis/redis/v8"
+)
 
 // 获取jxs邮箱验证
 func GetJxsVerifyMailCode(ip string, toEmail string) (bool, string) {
@@ -68,3 +42,108 @@ func DelJxsVerifyMailCode(ip string, toEmail string) bool {
 	log.Debugf("DelJxsVerifyMailCode params, ip:%s, toEmail:%s, err:%v", ip, toEmail, err)
 	return err == nil
 }
+
+// 保存jxs找回密码验证码, 重置尝试次数
+func SaveJxsResetPasswordCode(toEmail string, code string) error {
+	ctx := context.Background()
+	key := GetJxsResetPasswordCodeKey(toEmail)
+	_, err := uredis.RedisCon.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, "code", code, "attempts", 0)
+		pipe.Expire(ctx, key, KeyJxsResetPasswordCodeTimeout*time.Second)
+		return nil
+	})
+	log.Debugf("SaveJxsResetPasswordCode params, toEmail:%s, err:%v", toEmail, err)
+	return err
+}
+
+// 校验找回密码验证码时计入尝试次数, 超过上限时删除验证码并返回空
+var checkResetPasswordCodeScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+local attempts = redis.call("HINCRBY", KEYS[1], "attempts", 1)
+if attempts > tonumber(ARGV[1]) then
+	redis.call("DEL", KEYS[1])
+	return false
+end
+return redis.call("HGET", KEYS[1], "code")
+`)
+
+// 校验jxs找回密码验证码, 每次校验计入尝试次数, 超过最大尝试次数后验证码失效
+func CheckJxsResetPasswordCode(toEmail string, code string) bool {
+	key := GetJxsResetPasswordCodeKey(toEmail)
+	cachedCode, err := checkResetPasswordCodeScript.Run(context.Background(), uredis.RedisCon, []string{key}, KeyJxsResetPasswordMaxAttempts).Text()
+	if err != nil {
+		if err != redis.Nil {
+			log.Errorf("CheckJxsResetPasswordCode redis错误, toEmail:%s, err:%v", toEmail, err)
+		}
+		return false
+	}
+	return cachedCode != "" && subtle.ConstantTimeCompare([]byte(cachedCode), []byte(code)) == 1
+}
+
+// 删除jxs找回密码验证码
+func DelJxsResetPasswordCode(toEmail string) bool {
+	key := GetJxsResetPasswordCodeKey(toEmail)
+	err := uredis.DelKey(uredis.RedisCon, key)
+	log.Debugf("DelJxsResetPasswordCode params, toEmail:%s, err:%v", toEmail, err)
+	return err == nil
+}
+
+// 占用jxs找回密码邮件发送间隔, 间隔内重复发送时返回false
+func LockJxsResetPasswordSend(toEmail string) (bool, error) {
+	key := GetJxsResetPasswordSendKey(toEmail)
+	return uredis.SetNx(uredis.RedisCon, key, 1, KeyJxsResetPasswordSendTimeout)
+}
+
+// 登录失败计数加1, 首次失败时开始计数窗口
+func incrJxsLoginFail(key string, window int64) (int64, error) {
+	count, err := uredis.IncrKey(uredis.RedisCon, key)
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		err = uredis.Expire(uredis.RedisCon, key, window)
+	}
+	return count, err
+}
+
+// 获取登录失败计数
+func getJxsLoginFail(key string) int64 {
+	b, err := uredis.GetString(uredis.RedisCon, key)
+	if err != nil || b == nil {
+		return 0
+	}
+	count, _ := strconv.ParseInt(string(b), 10, 64)
+	return count
+}
+
+// jxs账号登录失败计数加1
+// @param account 账号标识, 用户ID或规范化的邮箱
+func IncrJxsLoginFailAccount(account string, window int64) (int64, error) {
+	return incrJxsLoginFail(GetJxsLoginFailAccountKey(account), window)
+}
+
+// 删除jxs账号登录失败计数
+func DelJxsLoginFailAccount(account string) bool {
+	key := GetJxsLoginFailAccountKey(account)
+	err := uredis.DelKey(uredis.RedisCon, key)
+	log.Debugf("DelJxsLoginFailAccount params, account:%s, err:%v", account, err)
+	return err == nil
+}
+
+// jxs ip登录失败计数加1
+func IncrJxsLoginFailIp(ip string, window int64) (int64, error) {
+	return incrJxsLoginFail(GetJxsLoginFailIpKey(ip), window)
+}
+
+// 获取jxs ip登录失败计数
+func GetJxsLoginFailIp(ip string) int64 {
+	return getJxsLoginFail(GetJxsLoginFailIpKey(ip))
+}
+
+// 延长jxs ip登录失败计数有效时长, 用于临时限制ip登录
+func ExpireJxsLoginFailIp(ip string, timeout int64) error {
+	return uredis.Expire(uredis.RedisCon, GetJxsLoginFailIpKey(ip), timeout)
+}
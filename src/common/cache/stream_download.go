@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uredis"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 保存流媒体购买下载凭证
+func SaveStreamDownload(ticketId string, ticket []byte, timeout int64) error {
+	key := GetStreamDownloadKey(ticketId)
+	err := uredis.SetString(uredis.RedisCon, key, ticket, timeout)
+	log.Debugf("SaveStreamDownload params, ticketId:%s, err:%v", ticketId, err)
+	return err
+}
+
+// 取出并删除流媒体购买下载凭证, 保证凭证只能使用一次
+func TakeStreamDownload(ticketId string) (bool, []byte) {
+	ctx := context.Background()
+	key := GetStreamDownloadKey(ticketId)
+	var get *redis.StringCmd
+	_, err := uredis.RedisCon.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		if err != redis.Nil {
+			log.Errorf("TakeStreamDownload redis错误, ticketId:%s, err:%v", ticketId, err)
+		}
+		return false, nil
+	}
+	b, err := get.Bytes()
+	if err != nil || b == nil {
+		return false, nil
+	}
+	return true, b
+}
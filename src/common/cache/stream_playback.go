@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uredis"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 开始播放会话, 请求m3u8时记录
+// @param fields 会话信息 user_id/player_id/product_id/duration/client_ip
+func StartStreamPlayback(sessionId string, fields map[string]interface{}) error {
+	ctx := context.Background()
+	key := GetStreamPlaybackKey(sessionId)
+	now := time.Now().Unix()
+	_, err := uredis.RedisCon.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, key, "started_at", now)
+		pipe.HSet(ctx, key, fields)
+		pipe.HSet(ctx, key, "last_at", now)
+		pipe.Expire(ctx, key, KeyStreamPlaybackTimeout*time.Second)
+		pipe.SAdd(ctx, KeyStreamPlaybackDirty, sessionId)
+		return nil
+	})
+	log.Debugf("StartStreamPlayback params, sessionId:%s, fields:%+v, err:%v", sessionId, fields, err)
+	return err
+}
+
+// 记录播放会话请求的分片, 不同码率的同一序号分片只计一次
+func RecordStreamPlaybackSegment(sessionId string, userId string, playerId string, index int64) error {
+	ctx := context.Background()
+	key := GetStreamPlaybackKey(sessionId)
+	segmentsKey := GetStreamPlaybackSegmentsKey(sessionId)
+	now := time.Now().Unix()
+	_, err := uredis.RedisCon.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, key, "started_at", now)
+		pipe.HSet(ctx, key, "user_id", userId, "player_id", playerId, "last_at", now)
+		pipe.HIncrBy(ctx, key, "requests", 1)
+		pipe.SAdd(ctx, segmentsKey, index)
+		pipe.Expire(ctx, key, KeyStreamPlaybackTimeout*time.Second)
+		pipe.Expire(ctx, segmentsKey, KeyStreamPlaybackTimeout*time.Second)
+		pipe.SAdd(ctx, KeyStreamPlaybackDirty, sessionId)
+		return nil
+	})
+	return err
+}
+
+// 获取播放会话信息及已请求的不重复分片数
+func GetStreamPlayback(sessionId string) (map[string]string, int64, error) {
+	fields, err := uredis.GetHashAll(uredis.RedisCon, GetStreamPlaybackKey(sessionId))
+	if err != nil {
+		return nil, 0, err
+	}
+	segments, err := uredis.RedisCon.SCard(context.Background(), GetStreamPlaybackSegmentsKey(sessionId)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	return fields, segments, nil
+}
+
+// 取出待落库的播放会话
+func PopStreamPlaybackDirty(count int64) ([]string, error) {
+	sessionIds, err := uredis.RedisCon.SPopN(context.Background(), KeyStreamPlaybackDirty, count).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return sessionIds, err
+}
+
+// 落库失败时将播放会话放回待落库集合
+func PushStreamPlaybackDirty(sessionIds ...string) error {
+	members := make([]interface{}, 0, len(sessionIds))
+	for _, sessionId := range sessionIds {
+		members = append(members, sessionId)
+	}
+	return uredis.RedisCon.SAdd(context.Background(), KeyStreamPlaybackDirty, members...).Err()
+}
+
+// 获取有序集合中score不早于expireBefore的成员, 同时清理过期成员
+func getActiveSortSetMembers(key string, expireBefore int64) ([]string, error) {
+	members, err := uredis.ZRangeWithScores(uredis.RedisCon, key, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]string, 0, len(members))
+	expired := make([]interface{}, 0)
+	for _, z := range members {
+		member, _ := z.Member.(string)
+		if int64(z.Score) < expireBefore {
+			expired = append(expired, member)
+			continue
+		}
+		active = append(active, member)
+	}
+	if len(expired) > 0 {
+		if err = uredis.ZRem(uredis.RedisCon, key, expired...); err != nil {
+			log.Errorf("getActiveSortSetMembers 清理过期成员失败, key:%s, err:%v", key, err)
+		}
+	}
+	return active, nil
+}
+
+// 获取用户活跃的播放会话, 最后请求时间早于expireBefore的会话视为已停止播放
+func GetStreamActiveSessions(userId string, expireBefore int64) ([]string, error) {
+	return getActiveSortSetMembers(GetStreamActiveSessionsKey(userId), expireBefore)
+}
+
+// 刷新用户播放会话的最后请求时间
+func SaveStreamActiveSession(userId string, sessionId string, timeout int64) error {
+	key := GetStreamActiveSessionsKey(userId)
+	if err := uredis.ZAdd(uredis.RedisCon, key, float64(time.Now().Unix()), sessionId); err != nil {
+		return err
+	}
+	return uredis.Expire(uredis.RedisCon, key, timeout)
+}
+
+// 获取用户统计窗口内的播放ip
+func GetStreamActiveIps(userId string, expireBefore int64) ([]string, error) {
+	return getActiveSortSetMembers(GetStreamActiveIpsKey(userId), expireBefore)
+}
+
+// 记录用户播放ip
+func SaveStreamActiveIp(userId string, ip string, timeout int64) error {
+	key := GetStreamActiveIpsKey(userId)
+	if err := uredis.ZAdd(uredis.RedisCon, key, float64(time.Now().Unix()), ip); err != nil {
+		return err
+	}
+	return uredis.Expire(uredis.RedisCon, key, timeout)
+}
+
+// 获取账号共享告警锁, 统计窗口内同一用户只告警一次
+func LockStreamSharingAlert(userId string, timeout int64) bool {
+	ok, err := uredis.SetNx(uredis.RedisCon, GetStreamSharingAlertKey(userId), 1, timeout)
+	if err != nil {
+		log.Errorf("LockStreamSharingAlert redis错误, userId:%s, err:%v", userId, err)
+		return false
+	}
+	return ok
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uredis"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 获取流媒体转码任务
+func GetStreamTranscodeJob(playerId string) (bool, []byte) {
+	key := GetStreamTranscodeJobKey(playerId)
+	b, err := uredis.GetString(uredis.RedisCon, key)
+	if err != nil || b == nil {
+		return false, nil
+	}
+	return true, b
+}
+
+// 保存流媒体转码任务
+func SaveStreamTranscodeJob(playerId string, job []byte) error {
+	key := GetStreamTranscodeJobKey(playerId)
+	err := uredis.SetString(uredis.RedisCon, key, job, KeyStreamTranscodeJobTimeout)
+	log.Debugf("SaveStreamTranscodeJob params, playerId:%s, err:%v", playerId, err)
+	return err
+}
+
+// 转码任务入队
+func PushStreamTranscodeQueue(playerId string) error {
+	err := uredis.LPush(uredis.RedisCon, KeyStreamTranscodeQueue, playerId)
+	log.Debugf("PushStreamTranscodeQueue params, playerId:%s, err:%v", playerId, err)
+	return err
+}
+
+// 阻塞获取转码任务, 同时移入本节点处理中队列, 超时返回空
+func PopStreamTranscodeQueue(nodeId string, timeout time.Duration) (string, error) {
+	playerId, err := uredis.BRPopLPush(uredis.RedisCon, KeyStreamTranscodeQueue, GetStreamTranscodeProcessingKey(nodeId), timeout)
+	if err == redis.Nil {
+		return "", nil
+	}
+	return playerId, err
+}
+
+// 转码任务处理完成, 移出本节点处理中队列
+func AckStreamTranscodeQueue(nodeId string, playerId string) error {
+	err := uredis.LRem(uredis.RedisCon, GetStreamTranscodeProcessingKey(nodeId), 0, playerId)
+	log.Debugf("AckStreamTranscodeQueue params, nodeId:%s, playerId:%s, err:%v", nodeId, playerId, err)
+	return err
+}
+
+// 续期转码节点租约, 节点需在获取任务前及处理期间定期续期
+func RenewStreamTranscodeLease(nodeId string) error {
+	if err := uredis.SetString(uredis.RedisCon, GetStreamTranscodeLeaseKey(nodeId), 1, KeyStreamTranscodeLeaseTimeout); err != nil {
+		return err
+	}
+	return uredis.SAdd(uredis.RedisCon, KeyStreamTranscodeNodes, nodeId)
+}
+
+// 将租约已过期节点的处理中任务重新放回待处理队列, 用于恢复宕机或重启节点中断的任务
+// 租约有效的节点仍在处理其任务, 不做回收
+func RequeueExpiredStreamTranscodeLeases() (count int, err error) {
+	nodeIds, err := uredis.SMembers(uredis.RedisCon, KeyStreamTranscodeNodes)
+	if err != nil {
+		return 0, err
+	}
+	for _, nodeId := range nodeIds {
+		alive, err := uredis.RedisCon.Exists(context.Background(), GetStreamTranscodeLeaseKey(nodeId)).Result()
+		if err != nil {
+			return count, err
+		}
+		if alive > 0 {
+			continue
+		}
+		processingKey := GetStreamTranscodeProcessingKey(nodeId)
+		for {
+			playerId, popErr := uredis.RPopLPush(uredis.RedisCon, processingKey, KeyStreamTranscodeQueue)
+			if popErr == redis.Nil {
+				break
+			}
+			if popErr != nil {
+				return count, popErr
+			}
+			log.Infof("RequeueExpiredStreamTranscodeLeases 恢复转码任务, nodeId:%s, playerId:%s", nodeId, playerId)
+			count++
+		}
+		if _, err = uredis.SRem(uredis.RedisCon, KeyStreamTranscodeNodes, nodeId); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uredis"
+)
+
+// 获取流媒体分片上传任务
+func GetStreamUpload(uploadId string) (bool, []byte) {
+	key := GetStreamUploadKey(uploadId)
+	b, err := uredis.GetString(uredis.RedisCon, key)
+	if err != nil || b == nil {
+		return false, nil
+	}
+	return true, b
+}
+
+// 保存流媒体分片上传任务
+func SaveStreamUpload(uploadId string, session []byte) error {
+	key := GetStreamUploadKey(uploadId)
+	err := uredis.SetString(uredis.RedisCon, key, session, KeyStreamUploadTimeout)
+	log.Debugf("SaveStreamUpload params, uploadId:%s, err:%v", uploadId, err)
+	return err
+}
+
+// 删除流媒体分片上传任务及已接收分片记录
+func DelStreamUpload(uploadId string) bool {
+	err := uredis.DelKey(uredis.RedisCon, GetStreamUploadKey(uploadId), GetStreamUploadChunksKey(uploadId), GetStreamUploadCompleteKey(uploadId))
+	log.Debugf("DelStreamUpload params, uploadId:%s, err:%v", uploadId, err)
+	return err == nil
+}
+
+// 记录已接收分片
+func SaveStreamUploadChunk(uploadId string, index string, checksum string) error {
+	key := GetStreamUploadChunksKey(uploadId)
+	if err := uredis.SetHash(uredis.RedisCon, key, index, []byte(checksum)); err != nil {
+		return err
+	}
+	return uredis.Expire(uredis.RedisCon, key, KeyStreamUploadTimeout)
+}
+
+// 获取已接收分片, index -> checksum
+func GetStreamUploadChunks(uploadId string) (map[string]string, error) {
+	return uredis.GetHashAll(uredis.RedisCon, GetStreamUploadChunksKey(uploadId))
+}
+
+// 获取合并分片锁, 防止重复合并
+func LockStreamUploadComplete(uploadId string) bool {
+	ok, err := uredis.SetNx(uredis.RedisCon, GetStreamUploadCompleteKey(uploadId), 1, KeyStreamUploadCompleteTimeout)
+	if err != nil {
+		log.Errorf("LockStreamUploadComplete redis错误, uploadId:%s, err:%v", uploadId, err)
+		return false
+	}
+	return ok
+}
+
+// 释放合并分片锁
+func UnlockStreamUploadComplete(uploadId string) {
+	uredis.DelKey(uredis.RedisCon, GetStreamUploadCompleteKey(uploadId))
+}
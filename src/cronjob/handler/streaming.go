@@ -3,9 +3,14 @@ package handler
 import (
 	router_dao "eshop_server/src/router/dao"
 	router_model "eshop_server/src/router/model"
+	stream_handler "eshop_server/src/stream/handler"
 	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/alarm"
+	"eshop_server/src/utils/config"
 	"eshop_server/src/utils/log"
-	"os"
+	"eshop_server/src/utils/storage"
+	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -36,20 +41,70 @@ func CleanStreamingLostFiles() {
 		return
 	}
 
+	// 获取密钥目录下所有密钥文件
+	keyFiles, err := getDirectorySteamingFiles(model.StreamFileKeyPath)
+	if err != nil {
+		log.Errorf("获取密钥目录下所有密钥文件失败: %v", err)
+		return
+	}
+	previewFiles, err := getDirectorySteamingFiles(model.StreamFilePreviewPath)
+	if err != nil {
+		log.Errorf("获取试听目录下所有试听文件失败: %v", err)
+		return
+	}
+	coverFiles, err := getDirectorySteamingFiles(model.StreamFileCoverPath)
+	if err != nil {
+		log.Errorf("获取封面目录下所有封面文件失败: %v", err)
+		return
+	}
+	waveformFiles, err := getDirectorySteamingFiles(model.StreamFileWaveformPath)
+	if err != nil {
+		log.Errorf("获取波形目录下所有波形文件失败: %v", err)
+		return
+	}
+
 	// 聚合所有文件, 按player_id分组
 	fileMap := make(map[string][]string)
 
 	// 处理上传文件
 	for _, filename := range uploadFiles {
 		playerID := extractPlayerIDFromFilename(filename)
-		fullPath := filepath.Join(model.StreamFileUploadPath, filename)
+		fullPath := storage.Key(model.StreamFileUploadPath, filename)
 		fileMap[playerID] = append(fileMap[playerID], fullPath)
 	}
 
 	// 处理切片文件
 	for _, filename := range segmentFiles {
 		playerID := extractPlayerIDFromFilename(filename)
-		fullPath := filepath.Join(model.StreamFileSegmentPath, filename)
+		fullPath := storage.Key(model.StreamFileSegmentPath, filename)
+		fileMap[playerID] = append(fileMap[playerID], fullPath)
+	}
+
+	// 处理密钥文件
+	for _, filename := range keyFiles {
+		playerID := extractPlayerIDFromFilename(filename)
+		fullPath := storage.Key(model.StreamFileKeyPath, filename)
+		fileMap[playerID] = append(fileMap[playerID], fullPath)
+	}
+
+	// 处理试听文件
+	for _, filename := range previewFiles {
+		playerID := extractPlayerIDFromFilename(filename)
+		fullPath := storage.Key(model.StreamFilePreviewPath, filename)
+		fileMap[playerID] = append(fileMap[playerID], fullPath)
+	}
+
+	// 处理封面文件
+	for _, filename := range coverFiles {
+		playerID := extractPlayerIDFromFilename(filename)
+		fullPath := storage.Key(model.StreamFileCoverPath, filename)
+		fileMap[playerID] = append(fileMap[playerID], fullPath)
+	}
+
+	// 处理波形文件
+	for _, filename := range waveformFiles {
+		playerID := extractPlayerIDFromFilename(filename)
+		fullPath := storage.Key(model.StreamFileWaveformPath, filename)
 		fileMap[playerID] = append(fileMap[playerID], fullPath)
 	}
 
@@ -73,8 +128,8 @@ func CleanStreamingLostFiles() {
 			log.Infof("检索到迷路文件, player_id: %s, 文件数量: %d, 文件路径: %v", playerID, len(files), files)
 			for _, file := range files {
 				// err := os.Remove(file)
-				recyclePath := filepath.Join(model.StreamFileRecyclePath, filepath.Base(file))
-				if err := os.Rename(file, recyclePath); err != nil {
+				recyclePath := storage.Key(model.StreamFileRecyclePath, path.Base(file))
+				if err := storage.Move(storage.Default, file, recyclePath); err != nil {
 					log.Errorf("迁移迷路文件失败: %v, 文件路径: %s", err, file)
 				} else {
 					log.Infof("文件已迁移到回收站: %s -> %s", file, recyclePath)
@@ -85,10 +140,128 @@ func CleanStreamingLostFiles() {
 	}
 }
 
+// @Title		定时任务清理回收站
+// @Description	删除回收站中超过保留天数的文件, 回收时间以文件移入回收站的修改时间为准
+func PurgeStreamingRecycleFiles() {
+	var totalDeleted int
+	startTime := time.Now()
+	retention := config.StreamConfig.RecycleRetention
+	if retention <= 0 {
+		retention = model.StreamFileRecycleDefaultRetention
+	}
+	expireBefore := startTime.AddDate(0, 0, -int(retention))
+	log.Infof("=-=-=-= PurgeStreamingRecycleFiles 开始执行定时任务清理回收站, 回收站目录: %s, 保留天数: %d =-=-=-=", model.StreamFileRecyclePath, retention)
+	defer func() {
+		log.Infof("=-=-=-= PurgeStreamingRecycleFiles 定时任务清理回收站执行完成，清理了%d个文件, 耗时: %v秒 =-=-=-=", totalDeleted, time.Since(startTime).Seconds())
+	}()
+
+	objects, err := storage.Default.List(model.StreamFileRecyclePath + "/")
+	if err != nil {
+		log.Errorf("获取回收站目录下所有文件失败: %v", err)
+		return
+	}
+	for _, object := range objects {
+		if !object.ModTime.Before(expireBefore) {
+			continue
+		}
+		if err = storage.Default.Delete(object.Key); err != nil {
+			log.Errorf("删除回收站文件失败: %v, 文件路径: %s", err, object.Key)
+			continue
+		}
+		totalDeleted++
+	}
+}
+
+// @Title		定时任务HLS完整性巡检
+// @Description	校验所有就绪player的m3u8及分片, 异常的player标记为异常状态并使用保留的源文件重新切片, 巡检结果飞书通知
+func AuditStreamingPlayers() {
+	var total, broken, repairing, skipped int
+	startTime := time.Now()
+	log.Infof("=-=-=-= AuditStreamingPlayers 开始执行定时任务HLS完整性巡检 =-=-=-=")
+	defer func() {
+		log.Infof("=-=-=-= AuditStreamingPlayers 定时任务HLS完整性巡检执行完成，巡检%d个, 异常%d个, 重新切片%d个, 跳过%d个, 耗时: %v秒 =-=-=-=", total, broken, repairing, skipped, time.Since(startTime).Seconds())
+	}()
+
+	players, err := router_dao.GetAllProductsPlayer()
+	if err != nil {
+		log.Errorf("查询数据库所有products_player记录失败: %v", err)
+		return
+	}
+
+	details := make([]string, 0)
+	skippedDetails := make([]string, 0)
+	for _, player := range players {
+		if player.Status != router_model.ProductsPlayerStatusOk {
+			continue
+		}
+		total++
+		issues, auditErr := stream_handler.AuditHlsPlayer(player)
+		if auditErr != nil {
+			// 存储临时故障无法判定是否损坏, 跳过本轮并在通知中报告
+			skipped++
+			log.Errorf("巡检player读取存储失败, 跳过: %v, player_id: %s", auditErr, player.Id)
+			if len(skippedDetails) < model.StreamAuditReportMaxItems {
+				skippedDetails = append(skippedDetails, fmt.Sprintf("\t\t%s(%s): %v", player.Id, player.ProductId, auditErr))
+			}
+			continue
+		}
+		if len(issues) == 0 {
+			continue
+		}
+		broken++
+		log.Errorf("检索到异常player, player_id: %s, 问题数量: %d, 问题: %v", player.Id, len(issues), issues)
+
+		// 标记为异常, 停止播放
+		player.Status = router_model.ProductsPlayerStatusError
+		if _, err = router_dao.UpdateProductsPlayerByField(player, []string{"status"}); err != nil {
+			log.Errorf("更新player状态失败: %v, player_id: %s", err, player.Id)
+		}
+
+		// 源文件仍保留时重新切片, 转码成功后恢复为就绪
+		result := "无法修复, 源文件缺失"
+		srcKey := storage.Key(model.StreamFileUploadPath, player.Id+player.FileType)
+		if _, statErr := storage.Default.Stat(srcKey); statErr == nil {
+			if _, err = stream_handler.EnqueueTranscodeJob(player.Id, srcKey, false, ""); err != nil {
+				log.Errorf("重新切片任务入队失败: %v, player_id: %s", err, player.Id)
+				result = "重新切片任务入队失败"
+			} else {
+				repairing++
+				result = "已重新切片"
+			}
+		}
+		if len(details) < model.StreamAuditReportMaxItems {
+			details = append(details, fmt.Sprintf("\t\t%s(%s): %s, %s", player.Id, player.ProductId, issues[0], result))
+		}
+	}
+
+	// 飞书通知
+	level, webhook := alarm.AlarmLevelInfo, config.CommonConfig.LarkAlarm.InfoBotWebhook
+	if broken > 0 || skipped > 0 {
+		level, webhook = alarm.AlarmLevelWarn, config.CommonConfig.LarkAlarm.ErrorBotWebhook
+	}
+	text := fmt.Sprintf("[JXS流媒体] HLS完整性巡检完成 \n\t环境:%s \n\t巡检:%d, 正常:%d, 异常:%d, 重新切片:%d, 无法修复:%d, 存储读取失败跳过:%d \n\t耗时:%.1f秒",
+		config.CommonConfig.Env, total, total-broken-skipped, broken, repairing, broken-repairing, skipped, time.Since(startTime).Seconds())
+	if len(details) > 0 {
+		text += "\n\t异常详情:\n" + strings.Join(details, "\n")
+		if broken > len(details) {
+			text += fmt.Sprintf("\n\t\t...等%d个", broken)
+		}
+	}
+	if len(skippedDetails) > 0 {
+		text += "\n\t跳过详情:\n" + strings.Join(skippedDetails, "\n")
+		if skipped > len(skippedDetails) {
+			text += fmt.Sprintf("\n\t\t...等%d个", skipped)
+		}
+	}
+	if err = alarm.PostFeiShu(level, webhook, text); err != nil {
+		log.Errorf("HLS完整性巡检飞书通知失败: %v", err)
+	}
+}
+
 // 判断文件是否为流媒体文件
 func isStreamingFile(filename string) bool {
-	// 文件以mp3/wav/m3u8/ts结尾
-	format_list := append(router_model.ProductPlayerSupportFileTypeList, ".m3u8", ".ts")
+	// 文件以mp3/wav/m3u8/ts/m4s/mp4/key/keyinfo/jpg/png/json结尾
+	format_list := append(router_model.ProductPlayerSupportFileTypeList, ".m3u8", ".ts", ".m4s", ".mp4", ".key", ".keyinfo", ".jpg", ".png", ".json")
 	for _, format := range format_list {
 		if strings.HasSuffix(filename, format) {
 			return true
@@ -103,21 +276,27 @@ func extractPlayerIDFromFilename(filename string) (playerID string) {
 	subStr := strings.TrimSuffix(filename, filepath.Ext(filename))
 	subStr = strings.TrimPrefix(subStr, ".")
 	playerID = filepath.Base(subStr)
+	// 去除附加后缀, 如{player_id}_preview_0
+	if idx := strings.Index(playerID, "_"); idx > 0 {
+		playerID = playerID[:idx]
+	}
 	return playerID
 }
 
-// 获取目录下所有音频文件
+// 获取存储目录下所有音频文件
 func getDirectorySteamingFiles(dir string) (files []string, err error) {
-	dirEntries, err := os.ReadDir(dir)
+	objects, err := storage.Default.List(dir + "/")
 	if err != nil {
 		return nil, err
 	}
-	for _, entry := range dirEntries {
-		if entry.IsDir() {
+	for _, object := range objects {
+		// 仅处理目录下的直接文件
+		filename := strings.TrimPrefix(object.Key, dir+"/")
+		if strings.Contains(filename, "/") {
 			continue
 		}
-		if isStreamingFile(entry.Name()) {
-			files = append(files, entry.Name())
+		if isStreamingFile(filename) {
+			files = append(files, filename)
 		}
 	}
 	return files, nil
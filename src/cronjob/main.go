@@ -5,6 +5,7 @@ import (
 	"eshop_server/src/utils/config"
 	"eshop_server/src/utils/db"
 	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
 	"eshop_server/src/utils/uredis"
 	"fmt"
 )
@@ -30,6 +31,12 @@ func main() {
 	uredis.InitRedis(config.DbConfig.Redis.Host, config.DbConfig.Redis.Password, config.DbConfig.Redis.Db)
 	log.Info("初始化Redis缓存成功")
 
+	// 初始化对象存储
+	if err = storage.InitStorage(); err != nil {
+		log.Errorf("初始化对象存储失败: %v", err)
+		return
+	}
+
 	// 初始化定时器
 	scheduler.InitScheduler()
 
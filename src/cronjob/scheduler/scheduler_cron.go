@@ -23,6 +23,8 @@ func InitScheduler() {
 
 	// 定时任务
 	Schedu.AddJob("0 0 4 * * *", handler.CleanStreamingLostFiles) // 每天凌晨4点执行
+	Schedu.AddJob("0 30 4 * * *", handler.PurgeStreamingRecycleFiles) // 每天凌晨4点30分执行
+	Schedu.AddJob("0 0 5 * * *", handler.AuditStreamingPlayers) // 每天凌晨5点执行
 	Schedu.Start()
 }
 
@@ -0,0 +1,59 @@
+package dao
+
+import (
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/db"
+	"eshop_server/src/utils/log"
+
+	"go.uber.org/zap"
+)
+
+// @Title   写入审计日志
+// @Description 审计日志仅追加写入, 不提供更新及删除
+// @Author  AInoriex  (2026/10/18)
+func CreateAdminAuditLog(m *model.AdminAuditLog) (err error) {
+	err = db.MysqlCon.Omit("created_at").Create(m).Error
+	if err != nil {
+		log.Error("CreateAdminAuditLog fail", zap.Any("m", m), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// @Title   分页查询审计日志
+// @Description 按条件过滤, 按id倒序, 返回当前页数据及总条数
+// @Author  AInoriex  (2026/10/18)
+func GetAdminAuditLogs(filter *model.AdminAuditLogFilter, pageNum, pageSize int) (res []*model.AdminAuditLog, total int64, err error) {
+	query := db.MysqlCon.Model(&model.AdminAuditLog{})
+	if filter.ActorId != "" {
+		query = query.Where("actor_id = ?", filter.ActorId)
+	}
+	if filter.Action != "" {
+		query = query.Where("action LIKE ?", "%"+filter.Action+"%")
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetId != "" {
+		query = query.Where("target_id = ?", filter.TargetId)
+	}
+	if filter.RequestId != "" {
+		query = query.Where("request_id = ?", filter.RequestId)
+	}
+	if !filter.StartTime.IsZero() {
+		query = query.Where("created_at >= ?", filter.StartTime)
+	}
+	if !filter.EndTime.IsZero() {
+		query = query.Where("created_at < ?", filter.EndTime)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		log.Error("GetAdminAuditLogs count fail", zap.Any("filter", filter), zap.Error(err))
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&res).Error
+	if err != nil {
+		log.Error("GetAdminAuditLogs fail", zap.Any("filter", filter), zap.Error(err))
+		return nil, 0, err
+	}
+	return res, total, nil
+}
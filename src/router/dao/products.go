@@ -20,6 +20,22 @@ func GetProductById(id string) (res *model.Products, err error) {
 	return
 }
 
+// @Title   批量获取数据记录
+// @Description 商品id列表
+// @Author  AInoriex  (2026/10/18)
+func GetProductsByIds(ids []string) (res []*model.Products, err error) {
+	if len(ids) == 0 {
+		return res, nil
+	}
+	err = db.MysqlCon.Where("id in ?", ids).Find(&res).Error
+	if err != nil {
+		log.Errorf("GetProductsByIds fail, ids:%v, err:%v", ids, err)
+		return nil, err
+	}
+
+	return
+}
+
 // @Title   检查商品是否有效
 // @Description 商品id
 // @Author  AInoriex  (2025/07/22 18:05)
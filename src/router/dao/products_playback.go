@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/db"
+	"eshop_server/src/utils/log"
+	"time"
+)
+
+// @Title   replace数据记录
+// @Description 根据会话id写入播放统计, 已存在时整条覆盖
+// @Author  AInoriex  (2026/10/18)
+func ReplaceProductsPlaybackSession(m *model.ProductsPlaybackSession) (res *model.ProductsPlaybackSession, err error) {
+	m.UpdateAt = time.Now()
+	// Save 主键存在时更新除创建时间外的所有字段, 否则插入
+	err = db.MysqlCon.Omit("created_at").Save(m).Error
+	if err != nil {
+		log.Errorf("ReplaceProductsPlaybackSession fail, m:%+v, err:%+v", m, err)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// @Title   按商品汇总播放统计
+// @Description 统计时间范围内开始播放且请求过分片的会话, productId为空时统计全部商品
+// @Author  AInoriex  (2026/10/18)
+func GetProductsPlaybackStats(startTime time.Time, endTime time.Time, productId string) (res []*model.AdminPlaybackProductStat, err error) {
+	query := db.MysqlCon.Model(&model.ProductsPlaybackSession{}).
+		Select("product_id, COUNT(*) AS plays, COUNT(DISTINCT user_id) AS unique_listeners, "+
+			"SUM(CASE WHEN duration > 0 AND listened_seconds >= duration * ? THEN 1 ELSE 0 END) AS completions, "+
+			"SUM(listened_seconds) AS listened_seconds", model.ProductsPlaybackCompleteRatio).
+		Where("started_at >= ? AND started_at < ? AND segments > 0", startTime, endTime)
+	if productId != "" {
+		query = query.Where("product_id = ?", productId)
+	}
+	err = query.Group("product_id").Order("plays desc").Scan(&res).Error
+	if err != nil {
+		log.Errorf("GetProductsPlaybackStats fail, startTime:%v, endTime:%v, productId:%s, err:%+v", startTime, endTime, productId, err)
+		return nil, err
+	}
+
+	return
+}
+
+// @Title   统计独立收听用户数
+// @Description 统计时间范围内请求过分片的不重复用户, productId为空时统计全部商品
+// @Author  AInoriex  (2026/10/18)
+func CountProductsPlaybackListeners(startTime time.Time, endTime time.Time, productId string) (count int64, err error) {
+	query := db.MysqlCon.Model(&model.ProductsPlaybackSession{}).
+		Where("started_at >= ? AND started_at < ? AND segments > 0", startTime, endTime)
+	if productId != "" {
+		query = query.Where("product_id = ?", productId)
+	}
+	err = query.Distinct("user_id").Count(&count).Error
+	if err != nil {
+		log.Errorf("CountProductsPlaybackListeners fail, startTime:%v, endTime:%v, productId:%s, err:%+v", startTime, endTime, productId, err)
+		return 0, err
+	}
+
+	return
+}
@@ -7,6 +7,8 @@ import (
 	"eshop_server/src/utils/log"
 	"eshop_server/src/utils/uuid"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // @Title   获取数据记录
@@ -62,6 +64,76 @@ func GetSelectedProductsPlayerByProductId(product_id string, status int32, order
 	return
 }
 
+// @Title   获取商品曲目列表
+// @Description 商品id, 状态status列表, 按碟号、曲目序号排序
+// @Author  AInoriex  (2026/10/18)
+func GetProductsPlayerTracklist(product_id string, status []int32) (res []*model.ProductsPlayer, err error) {
+	err = db.MysqlCon.Where("product_id = ? and status in ?", product_id, status).
+		Order("disc_no asc, track_no asc, created_at asc").Find(&res).Error
+	if err != nil {
+		log.Errorf("GetProductsPlayerTracklist fail, product_id:%s, status:%v, err:%+v", product_id, status, err)
+		return nil, err
+	}
+
+	return
+}
+
+// @Title   批量获取商品试听曲目列表
+// @Description 商品id列表, 状态status列表, 仅返回有试听地址的记录, 按商品、碟号、曲目序号排序
+// @Author  AInoriex  (2026/10/18)
+func GetProductsPreviewTracklist(product_ids []string, status []int32) (res []*model.ProductsPlayer, err error) {
+	if len(product_ids) == 0 {
+		return res, nil
+	}
+	err = db.MysqlCon.Where("product_id in ? and status in ? and preview_url != ''", product_ids, status).
+		Order("product_id asc, disc_no asc, track_no asc, created_at asc").Find(&res).Error
+	if err != nil {
+		log.Errorf("GetProductsPreviewTracklist fail, product_ids:%v, status:%v, err:%+v", product_ids, status, err)
+		return nil, err
+	}
+
+	return
+}
+
+// @Title   获取商品最大曲目序号
+// @Description 商品id, 碟号disc_no, 不统计已下架的播放记录
+// @Author  AInoriex  (2026/10/18)
+func GetProductsPlayerMaxTrackNo(product_id string, disc_no int32) (res int32, err error) {
+	err = db.MysqlCon.Model(&model.ProductsPlayer{}).
+		Where("product_id = ? and disc_no = ? and status != ?", product_id, disc_no, model.ProductsPlayerStatusInvalid).
+		Select("coalesce(max(track_no), 0)").Scan(&res).Error
+	if err != nil {
+		log.Errorf("GetProductsPlayerMaxTrackNo fail, product_id:%s, disc_no:%d, err:%+v", product_id, disc_no, err)
+		return 0, err
+	}
+
+	return
+}
+
+// @Title   更新商品曲目排序
+// @Description 同一事务中更新各播放记录的碟号及曲目序号
+// @Author  AInoriex  (2026/10/18)
+func UpdateProductsPlayerTracklist(list []*model.ProductsPlayer) (err error) {
+	now := time.Now()
+	log.Infof("UpdateProductsPlayerTracklist params, len:%d", len(list))
+	_, err = db.InTransaction(db.MysqlCon, func(tx *gorm.DB) (interface{}, error) {
+		for _, m := range list {
+			m.UpdateAt = now
+			if err := tx.Model(&model.ProductsPlayer{}).Where("id = ? and product_id = ?", m.Id, m.ProductId).
+				Updates(map[string]interface{}{"disc_no": m.DiscNo, "track_no": m.TrackNo, "updated_at": now}).Error; err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		log.Errorf("UpdateProductsPlayerTracklist fail, err:%+v", err)
+		return err
+	}
+
+	return nil
+}
+
 // @Title   创建数据记录
 // @Description desc
 // @Author  AInoriex  (2025/08/12 11:04)
@@ -148,4 +220,29 @@ func ReplaceProductsPlayerByProductId(m *model.ProductsPlayer, field []string) (
 	}
 
 	return ReplaceProductsPlayer(m, field)
-}
\ No newline at end of file
+}
+// @Title   替换数据记录
+// @Description 同一事务中更新新播放记录的特定字段并下架旧播放记录, 用于替换商品音频
+// @Author  AInoriex  (2026/10/18)
+// @Param   m *model.ProductsPlayer 新播放记录
+// @Param   field []string 新播放记录更新字段
+// @Param   oldId string 旧播放记录id
+// @Return  *model.ProductsPlayer, error
+func SwapProductsPlayer(m *model.ProductsPlayer, field []string, oldId string) (res *model.ProductsPlayer, err error) {
+	m.UpdateAt = time.Now()
+	log.Infof("SwapProductsPlayer params, m:%+v, field:%+v, oldId:%s", m, field, oldId)
+	_, err = db.InTransaction(db.MysqlCon, func(tx *gorm.DB) (interface{}, error) {
+		if err := tx.Model(&model.ProductsPlayer{}).Select(field).Omit("id").
+			Where("id = ?", m.Id).Updates(m).Error; err != nil {
+			return nil, err
+		}
+		return nil, tx.Model(&model.ProductsPlayer{}).Where("id = ? and id != ?", oldId, m.Id).
+			Updates(map[string]interface{}{"status": model.ProductsPlayerStatusInvalid, "updated_at": m.UpdateAt}).Error
+	})
+	if err != nil {
+		log.Errorf("SwapProductsPlayer fail, m:%+v, oldId:%s, err:%+v", m, oldId, err)
+		return nil, err
+	}
+
+	return m, nil
+}
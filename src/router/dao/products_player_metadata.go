@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/db"
+	"eshop_server/src/utils/log"
+	"time"
+)
+
+// @Title   获取数据记录
+// @Description 播放器id
+// @Author  AInoriex  (2026/10/18)
+func GetProductsPlayerMetadataByPlayerId(playerId string) (res *model.ProductsPlayerMetadata, err error) {
+	err = db.MysqlCon.Where("player_id = ?", playerId).First(&res).Error
+	if err != nil {
+		log.Errorf("GetProductsPlayerMetadataByPlayerId fail, player_id:%s, err:%+v", playerId, err)
+		return nil, err
+	}
+
+	return
+}
+
+// @Title   replace数据记录
+// @Description 根据player_id写入元数据, 已存在时整条覆盖
+// @Author  AInoriex  (2026/10/18)
+func ReplaceProductsPlayerMetadata(m *model.ProductsPlayerMetadata) (res *model.ProductsPlayerMetadata, err error) {
+	log.Infof("ReplaceProductsPlayerMetadata params, m:%+v", m)
+	m.UpdateAt = time.Now()
+	if old, getErr := GetProductsPlayerMetadataByPlayerId(m.PlayerId); getErr == nil && old != nil {
+		m.CreateAt = old.CreateAt
+	} else {
+		m.CreateAt = time.Now()
+	}
+	// Save 主键存在时更新所有字段, 否则插入
+	err = db.MysqlCon.Save(m).Error
+	if err != nil {
+		log.Errorf("ReplaceProductsPlayerMetadata fail, m:%+v, err:%+v", m, err)
+		return nil, err
+	}
+
+	return m, nil
+}
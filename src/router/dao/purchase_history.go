@@ -5,6 +5,7 @@ import (
 	"eshop_server/src/utils/db"
 	"eshop_server/src/utils/log"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // @Title   获取用户所有支付记录
@@ -35,6 +36,65 @@ func GetPurchaseHistoryByUserIdAndProductIdAndPaymentId(userId string, productId
 	return
 }
 
+// @Title   校验用户是否已购买商品
+// @Description 用户id，商品id
+// @Author  AInoriex  (2026/10/18)
+func CheckUserPurchasedProduct(userId string, productId string) (bool, error) {
+	purchaseList, err := GetPurchaseHistorysByUserId(userId)
+	if err != nil {
+		return false, err
+	}
+	for _, purchase := range purchaseList {
+		if purchase.ProductId == productId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// @Title   获取用户购买指定商品的所有记录
+// @Description 用户id，商品id，按购买时间排序
+// @Author  AInoriex  (2026/10/18)
+func GetPurchaseHistorysByUserIdAndProductId(userId string, productId string) (res []*model.PurchaseHistory, err error) {
+	log.Infof("GetPurchaseHistorysByUserIdAndProductId params, userId:%s, productId:%s", userId, productId)
+	err = db.MysqlCon.Where("user_id = ? and product_id = ?", userId, productId).Order("purchased_at asc, id asc").Find(&res).Error
+	if err != nil {
+		log.Error("GetPurchaseHistorysByUserIdAndProductId fail", zap.Error(err))
+		return nil, err
+	}
+
+	return
+}
+
+// @Title   增加下载次数
+// @Description 购买记录id，最大下载次数limit（小于0时不限制），已达上限时返回false
+// @Author  AInoriex  (2026/10/18)
+func IncrPurchaseHistoryDownloadCount(id int64, limit int64) (bool, error) {
+	query := db.MysqlCon.Model(&model.PurchaseHistory{}).Where("id = ?", id)
+	if limit >= 0 {
+		query = query.Where("download_count < ?", limit)
+	}
+	result := query.UpdateColumn("download_count", gorm.Expr("download_count + ?", 1))
+	if result.Error != nil {
+		log.Error("IncrPurchaseHistoryDownloadCount fail", zap.Int64("id", id), zap.Error(result.Error))
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// @Title   回退下载次数
+// @Description 购买记录id，下载文件生成失败时调用
+// @Author  AInoriex  (2026/10/18)
+func DecrPurchaseHistoryDownloadCount(id int64) error {
+	err := db.MysqlCon.Model(&model.PurchaseHistory{}).Where("id = ? and download_count > 0", id).
+		UpdateColumn("download_count", gorm.Expr("download_count - ?", 1)).Error
+	if err != nil {
+		log.Error("DecrPurchaseHistoryDownloadCount fail", zap.Int64("id", id), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 // @Title   创建数据记录
 // @Description desc
 // @Author  AInoriex  (2025/05/12 17:16)
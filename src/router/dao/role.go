@@ -0,0 +1,80 @@
+package dao
+
+import (
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/db"
+	"eshop_server/src/utils/log"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// @Title   获取全部运营角色
+// @Description 按角色名称排序
+// @Author  AInoriex  (2026/10/18)
+func GetRoleList() (res []*model.Role, err error) {
+	err = db.MysqlCon.Order("name asc").Find(&res).Error
+	if err != nil {
+		log.Error("GetRoleList fail", zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// @Title   根据名称获取运营角色
+// @Description 角色名称列表, 返回已存在的角色
+// @Author  AInoriex  (2026/10/18)
+func GetRolesByNames(names []string) (res []*model.Role, err error) {
+	err = db.MysqlCon.Where("name IN ?", names).Find(&res).Error
+	if err != nil {
+		log.Error("GetRolesByNames fail", zap.Strings("names", names), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// @Title   获取角色权限
+// @Description 角色名称列表, 为空时返回全部角色的权限
+// @Author  AInoriex  (2026/10/18)
+func GetRolePermissions(roles []string) (res []*model.RolePermission, err error) {
+	query := db.MysqlCon.Model(&model.RolePermission{})
+	if len(roles) > 0 {
+		query = query.Where("role IN ?", roles)
+	}
+	err = query.Order("id asc").Find(&res).Error
+	if err != nil {
+		log.Error("GetRolePermissions fail", zap.Strings("roles", roles), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// @Title   创建或更新运营角色
+// @Description 事务内写入角色并以permissions覆盖原有权限
+// @Author  AInoriex  (2026/10/18)
+func SaveRole(m *model.Role, permissions []string) (err error) {
+	m.UpdatedAt = time.Now()
+	err = db.MysqlCon.Transaction(func(tx *gorm.DB) error {
+		// Save 主键存在时更新除创建时间外的所有字段, 否则插入
+		if err := tx.Omit("created_at").Save(m).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role = ?", m.Name).Delete(&model.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+		list := make([]*model.RolePermission, 0, len(permissions))
+		for _, permission := range permissions {
+			list = append(list, &model.RolePermission{Role: m.Name, Permission: permission})
+		}
+		return tx.Omit("created_at").Create(&list).Error
+	})
+	if err != nil {
+		log.Error("SaveRole fail", zap.Any("m", m), zap.Strings("permissions", permissions), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package dao
+
+import (
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/db"
+	"eshop_server/src/utils/log"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// @Title   启用用户两步验证
+// @Description 保存TOTP密钥并替换全部恢复码
+// @Author  AInoriex  (2026/10/18)
+func EnableUserTotp(userId string, secret string, codeHashes []string) (err error) {
+	now := time.Now()
+	err = db.MysqlCon.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{}).Where("id = ?", userId).
+			Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled_at": now, "updated_at": now}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userId).Delete(&model.UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		list := make([]*model.UserRecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			list = append(list, &model.UserRecoveryCode{UserId: userId, CodeHash: hash})
+		}
+		return tx.Omit("used_at", "created_at").Create(&list).Error
+	})
+	if err != nil {
+		log.Error("EnableUserTotp fail", zap.String("user_id", userId), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// @Title   重置用户两步验证
+// @Description 清除TOTP密钥及全部恢复码, 用户下次登录时重新绑定
+// @Author  AInoriex  (2026/10/18)
+func ResetUserTotp(userId string) (err error) {
+	err = db.MysqlCon.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{}).Where("id = ?", userId).
+			Updates(map[string]interface{}{"totp_secret": nil, "totp_enabled_at": nil, "updated_at": time.Now()}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userId).Delete(&model.UserRecoveryCode{}).Error
+	})
+	if err != nil {
+		log.Error("ResetUserTotp fail", zap.String("user_id", userId), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// @Title   使用恢复码
+// @Description 恢复码未使用时标记为已使用, 返回是否使用成功
+// @Author  AInoriex  (2026/10/18)
+func UseUserRecoveryCode(userId string, codeHash string) (ok bool, err error) {
+	result := db.MysqlCon.Model(&model.UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Error("UseUserRecoveryCode fail", zap.String("user_id", userId), zap.Error(result.Error))
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// @Title   查询剩余恢复码数量
+// @Description 用户id
+// @Author  AInoriex  (2026/10/18)
+func CountUserRecoveryCodes(userId string) (count int64, err error) {
+	err = db.MysqlCon.Model(&model.UserRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userId).Count(&count).Error
+	if err != nil {
+		log.Error("CountUserRecoveryCodes fail", zap.String("user_id", userId), zap.Error(err))
+		return 0, err
+	}
+	return count, nil
+}
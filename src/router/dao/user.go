@@ -107,3 +107,41 @@ func UpdateUserByField(m *model.User, field []string) (res *model.User, err erro
 
 	return m, nil
 }
+
+// @Title   临时锁定用户账号
+// @Description 用户id, 锁定前连续登录失败次数, 锁定截止时间
+// @Author  AInoriex  (2026/10/18)
+func LockUser(id string, attempts int64, lockedUntil time.Time) (err error) {
+	err = db.MysqlCon.Model(&model.User{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"login_attempts": attempts, "locked_until": lockedUntil, "updated_at": time.Now()}).Error
+	if err != nil {
+		log.Error("LockUser fail", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// @Title   解除用户账号临时锁定
+// @Description 用户id
+// @Author  AInoriex  (2026/10/18)
+func UnlockUser(id string) (err error) {
+	err = db.MysqlCon.Model(&model.User{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"login_attempts": 0, "locked_until": nil, "updated_at": time.Now()}).Error
+	if err != nil {
+		log.Error("UnlockUser fail", zap.String("id", id), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// @Title   获取临时锁定中的用户
+// @Description 按锁定截止时间倒序
+// @Author  AInoriex  (2026/10/18)
+func GetLockedUsers() (res []*model.User, err error) {
+	err = db.MysqlCon.Where("locked_until > ?", time.Now()).Order("locked_until desc").Find(&res).Error
+	if err != nil {
+		log.Error("GetLockedUsers fail", zap.Error(err))
+		return res, err
+	}
+	return res, nil
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"eshop_server/src/common/api"
+	"eshop_server/src/router/dao"
+	"eshop_server/src/router/model"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/utime"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Title		 查询操作审计日志
+// @Description  管理后台按条件分页查询后台写操作的审计日志
+// @Param        actor_id 操作人用户id
+// @Param        action 操作, 模糊匹配请求方法及路由
+// @Param        target_type 目标实体类型 user/product/player/role
+// @Param        target_id 目标实体id
+// @Param        request_id 请求id
+// @Param        start_time 开始时间 2006-01-02 15:04:05
+// @Param        end_time 结束时间 2006-01-02 15:04:05
+// @Param        page 页码, 默认1
+// @Param        page_size 每页条数, 默认50
+// @Response     json
+// @Router       /v1/eshop_api/admin/audit/list [get]
+func AdminGetAuditLogList(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	// 请求参数校验
+	filter := &model.AdminAuditLogFilter{
+		ActorId:    c.Query("actor_id"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetId:   c.Query("target_id"),
+		RequestId:  c.Query("request_id"),
+	}
+	for name, t := range map[string]*time.Time{"start_time": &filter.StartTime, "end_time": &filter.EndTime} {
+		s := c.Query(name)
+		if s == "" {
+			continue
+		}
+		parsed, err := time.ParseInLocation(utime.TIME_LAYOUT, s, time.Local)
+		if err != nil {
+			log.Errorf("AdminGetAuditLogList 请求参数错误, %s:%s, error:%v", name, s, err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":"+name)
+			return
+		}
+		*t = parsed
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page <= 0 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if pageSize <= 0 {
+		pageSize = model.AdminAuditDefaultPageSize
+	}
+	if pageSize > model.AdminAuditMaxPageSize {
+		pageSize = model.AdminAuditMaxPageSize
+	}
+
+	resList, total, err := dao.GetAdminAuditLogs(filter, page, pageSize)
+	if err != nil {
+		log.Errorf("AdminGetAuditLogList GetAdminAuditLogs fail, filter:%+v, err:%v", filter, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+
+	dataMap["result"] = resList
+	dataMap["len"] = len(resList)
+	dataMap["total"] = total
+	dataMap["page"] = page
+	dataMap["page_size"] = pageSize
+	api.Success(c, dataMap)
+}
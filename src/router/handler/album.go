@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"encoding/json"
+	"eshop_server/src/common/api"
+	"eshop_server/src/router/dao"
+	"eshop_server/src/router/middleware"
+	"eshop_server/src/router/model"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	trackTitleMaxLength = 255 // products_player.title 字段长度
+)
+
+// @Title			获取专辑曲目列表
+// @Description		按曲目顺序返回商品曲目列表, 已购买时附带播放凭证的播放地址, 未购买时仅返回试听地址
+// @Param			product_id 商品id
+// @Router			/v1/eshop_api/user/album?product_id= [get]
+// @Response		json
+func GetProductAlbum(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	// JWT用户查询&鉴权
+	user, err := isValidUser(c)
+	if err != nil {
+		log.Error("GetProductAlbum 非法用户请求", zap.Error(err))
+		api.FailWithAuthorization(c)
+		return
+	}
+
+	// 参数解析
+	productId := c.Query("product_id")
+	if productId == "" {
+		log.Errorf("GetProductAlbum 请求参数错误, product_id为空, user_id: %s", user.Id)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":商品ID为空")
+		return
+	}
+	log.Infof("GetProductAlbum 请求参数, user_id: %s, product_id: %s", user.Id, productId)
+
+	// 校验用户购买权限, 未购买的用户仅可查看上架商品
+	entitled, err := dao.CheckUserPurchasedProduct(user.Id, productId)
+	if err != nil {
+		log.Errorf("GetProductAlbum 查询购买历史记录失败, user_id: %s, error: %s", user.Id, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	product, err := dao.GetProductById(productId)
+	if err != nil || product == nil || (!entitled && product.Status != model.ProductStatusOn) {
+		log.Errorf("GetProductAlbum 商品不存在或已下架, product_id: %s, entitled: %v, error: %v", productId, entitled, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":商品不存在或已下架")
+		return
+	}
+
+	// 查询商品曲目列表
+	playerList, err := dao.GetProductsPlayerTracklist(productId, []int32{model.ProductsPlayerStatusOk})
+	if err != nil {
+		log.Errorf("GetProductAlbum 查询商品曲目列表失败, product_id: %s, error: %s", productId, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+
+	album := &model.AlbumView{
+		ProductId:   product.Id,
+		Title:       product.Title,
+		Description: product.Description,
+		ImageUrl:    product.ImageUrl,
+		Entitled:    entitled,
+		Tracks:      make([]*model.AlbumTrackView, 0, len(playerList)),
+	}
+	for _, player := range playerList {
+		track := player.TrackViewFormat()
+		// 已购买时为每个曲目签发播放凭证
+		if entitled && player.PlayUrl != "" {
+			playUrl, hifiUrl, expiresAt, signErr := signPlaybackUrl(user.Id, c.GetString("sessionId"), player)
+			if signErr != nil {
+				log.Errorf("GetProductAlbum 生成播放凭证失败, user_id: %s, player_id: %s, error: %s", user.Id, player.Id, signErr.Error())
+				api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+				return
+			}
+			track.PlayType = player.PlayType
+			track.PlayUrl = playUrl
+			track.HifiUrl = hifiUrl
+			track.ExpiresAt = &expiresAt
+		}
+		album.Tracks = append(album.Tracks, track)
+		album.Duration += player.Duration
+	}
+	album.TrackCount = len(album.Tracks)
+
+	dataMap["result"] = album
+	api.Success(c, dataMap)
+}
+
+// @Title		 获取商品曲目列表(后台)
+// @Description  按曲目顺序返回商品所有未下架的播放资源
+// @Param        product_id 商品id
+// @Response     json
+// @Router       /v1/eshop_api/admin/player/tracklist?product_id= [get]
+func AdminGetProductTracklist(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	productId := c.Query("product_id")
+	if productId == "" {
+		log.Errorf("AdminGetProductTracklist 请求参数错误, product_id为空")
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":商品ID为空")
+		return
+	}
+
+	playerList, err := dao.GetProductsPlayerTracklist(productId, model.ProductsPlayerTracklistStatusList)
+	if err != nil {
+		log.Errorf("AdminGetProductTracklist 查询商品曲目列表失败, product_id: %s, error: %v", productId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+
+	dataMap["result"] = playerList
+	dataMap["len"] = len(playerList)
+	api.Success(c, dataMap)
+}
+
+// @Title		 商品曲目排序
+// @Description  按请求顺序重排商品曲目, 须包含商品所有未下架的播放资源
+// @Accept       json model.ReorderTracklistReq
+// @Response     json
+// @Router       /v1/eshop_api/admin/player/tracklist/reorder [put]
+func AdminReorderProductTracklist(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+	log.Infof("AdminReorderProductTracklist 请求参数, req:%s", string(req))
+
+	// JSON解析
+	var reqbody model.ReorderTracklistReq
+	if err = json.Unmarshal(req, &reqbody); err != nil {
+		log.Errorf("AdminReorderProductTracklist json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+	if reqbody.ProductId == "" || len(reqbody.Tracks) == 0 {
+		log.Errorf("AdminReorderProductTracklist 请求参数错误, reqbody:%+v", reqbody)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":缺失必要参数")
+		return
+	}
+
+	// 查询当前曲目列表
+	playerList, err := dao.GetProductsPlayerTracklist(reqbody.ProductId, model.ProductsPlayerTracklistStatusList)
+	if err != nil {
+		log.Errorf("AdminReorderProductTracklist 查询商品曲目列表失败, product_id:%s, error:%v", reqbody.ProductId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	playerMap := make(map[string]*model.ProductsPlayer, len(playerList))
+	before := make(map[string]interface{}, len(playerList))
+	for _, player := range playerList {
+		playerMap[player.Id] = player
+		before[player.Id] = map[string]int32{"disc_no": player.DiscNo, "track_no": player.TrackNo}
+	}
+	if len(reqbody.Tracks) != len(playerList) {
+		log.Errorf("AdminReorderProductTracklist 曲目列表不完整, product_id:%s, tracks:%d, players:%d", reqbody.ProductId, len(reqbody.Tracks), len(playerList))
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":曲目列表不完整")
+		return
+	}
+
+	// 按请求顺序分配曲目序号, 各碟从1开始
+	trackNoMap := make(map[int32]int32)
+	resultList := make([]*model.ProductsPlayer, 0, len(reqbody.Tracks))
+	for _, item := range reqbody.Tracks {
+		player, ok := playerMap[item.PlayerId]
+		if !ok {
+			log.Errorf("AdminReorderProductTracklist 播放资源不属于该商品或重复, product_id:%s, player_id:%s", reqbody.ProductId, item.PlayerId)
+			api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":播放资源无效或重复")
+			return
+		}
+		delete(playerMap, item.PlayerId)
+		if item.DiscNo <= 0 {
+			item.DiscNo = model.ProductsPlayerDefaultDiscNo
+		}
+		trackNoMap[item.DiscNo]++
+		player.DiscNo = item.DiscNo
+		player.TrackNo = trackNoMap[item.DiscNo]
+		resultList = append(resultList, player)
+	}
+
+	if err = dao.UpdateProductsPlayerTracklist(resultList); err != nil {
+		log.Errorf("AdminReorderProductTracklist 更新曲目排序失败, product_id:%s, error:%v", reqbody.ProductId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+	after := make(map[string]interface{}, len(resultList))
+	for _, player := range resultList {
+		after[player.Id] = map[string]int32{"disc_no": player.DiscNo, "track_no": player.TrackNo}
+	}
+	middleware.SetAdminAudit(c, model.AdminAuditTargetProduct, reqbody.ProductId, before, after)
+
+	dataMap["result"] = resultList
+	dataMap["len"] = len(resultList)
+	api.Success(c, dataMap)
+}
+
+// @Title		 更新曲目信息
+// @Description  修改曲目标题
+// @Accept       json model.UpdateTrackReq
+// @Response     json
+// @Router       /v1/eshop_api/admin/player/track/:id [put]
+func AdminUpdateProductTrack(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+	playerId := c.Param("id")
+	log.Infof("AdminUpdateProductTrack 请求参数, player_id:%s, req:%s", playerId, string(req))
+
+	// JSON解析
+	var reqbody model.UpdateTrackReq
+	if err = json.Unmarshal(req, &reqbody); err != nil {
+		log.Errorf("AdminUpdateProductTrack json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+	reqbody.Title = strings.TrimSpace(reqbody.Title)
+	if playerId == "" || reqbody.Title == "" || utf8.RuneCountInString(reqbody.Title) > trackTitleMaxLength {
+		log.Errorf("AdminUpdateProductTrack 请求参数错误, player_id:%s, reqbody:%+v", playerId, reqbody)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":曲目标题无效")
+		return
+	}
+
+	player, err := dao.GetProductsPlayerById(playerId)
+	if err != nil {
+		log.Errorf("AdminUpdateProductTrack 查询播放资源失败, player_id:%s, error:%v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail+":查询播放资源失败")
+		return
+	}
+	before := *player
+	player.Title = reqbody.Title
+	if player, err = dao.UpdateProductsPlayerByField(player, []string{"title"}); err != nil {
+		log.Errorf("AdminUpdateProductTrack 更新曲目标题失败, player_id:%s, error:%v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+	middleware.SetAdminAudit(c, model.AdminAuditTargetPlayer, player.Id, &before, player)
+
+	dataMap["result"] = player
+	api.Success(c, dataMap)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"eshop_server/src/common/api"
+	"eshop_server/src/router/dao"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/utime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	playbackStatsDefaultDays = 7   // 播放统计默认查询最近7天
+	playbackStatsMaxDays     = 366 // 播放统计最大查询范围
+)
+
+// @Title		 播放统计
+// @Description  按商品统计时间范围内的播放次数、完播率及独立收听用户数
+// @Param        start_time 开始日期 2006-01-02, 默认7天前
+// @Param        end_time 结束日期 2006-01-02（包含当天）, 默认今天
+// @Param        product_id 商品id, 为空时统计全部商品
+// @Response     json
+// @Router       /v1/eshop_api/admin/analytics/playback [get]
+func AdminGetPlaybackStats(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	// 请求参数校验
+	today, _ := time.ParseInLocation(utime.TIME_LAYOUT_DATE, time.Now().Format(utime.TIME_LAYOUT_DATE), time.Local)
+	endTime := today.AddDate(0, 0, 1)
+	if s := c.Query("end_time"); s != "" {
+		t, err := time.ParseInLocation(utime.TIME_LAYOUT_DATE, s, time.Local)
+		if err != nil {
+			log.Errorf("AdminGetPlaybackStats 请求参数错误, end_time:%s, error:%v", s, err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":end_time")
+			return
+		}
+		endTime = t.AddDate(0, 0, 1)
+	}
+	startTime := endTime.AddDate(0, 0, -playbackStatsDefaultDays)
+	if s := c.Query("start_time"); s != "" {
+		t, err := time.ParseInLocation(utime.TIME_LAYOUT_DATE, s, time.Local)
+		if err != nil {
+			log.Errorf("AdminGetPlaybackStats 请求参数错误, start_time:%s, error:%v", s, err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":start_time")
+			return
+		}
+		startTime = t
+	}
+	if !startTime.Before(endTime) || endTime.Sub(startTime) > playbackStatsMaxDays*24*time.Hour {
+		log.Errorf("AdminGetPlaybackStats 请求参数错误, 时间范围无效, start_time:%v, end_time:%v", startTime, endTime)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":时间范围无效")
+		return
+	}
+	productId := c.Query("product_id")
+
+	// 按商品汇总
+	stats, err := dao.GetProductsPlaybackStats(startTime, endTime, productId)
+	if err != nil {
+		log.Errorf("AdminGetPlaybackStats 查询播放统计失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	listeners, err := dao.CountProductsPlaybackListeners(startTime, endTime, productId)
+	if err != nil {
+		log.Errorf("AdminGetPlaybackStats 查询独立收听用户失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+
+	// 批量查询商品名称
+	productIds := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		productIds = append(productIds, stat.ProductId)
+	}
+	products, err := dao.GetProductsByIds(productIds)
+	if err != nil {
+		log.Errorf("AdminGetPlaybackStats 查询商品失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	productNames := make(map[string]string, len(products))
+	for _, product := range products {
+		productNames[product.Id] = product.Title
+	}
+
+	var plays, completions, listenedSeconds int64
+	for _, stat := range stats {
+		stat.ProductName = productNames[stat.ProductId]
+		if stat.Plays > 0 {
+			stat.CompletionRate = float64(stat.Completions) / float64(stat.Plays)
+		}
+		plays += stat.Plays
+		completions += stat.Completions
+		listenedSeconds += stat.ListenedSeconds
+	}
+	var completionRate float64
+	if plays > 0 {
+		completionRate = float64(completions) / float64(plays)
+	}
+
+	dataMap["start_time"] = startTime.Format(utime.TIME_LAYOUT_DATE)
+	dataMap["end_time"] = endTime.AddDate(0, 0, -1).Format(utime.TIME_LAYOUT_DATE)
+	dataMap["plays"] = plays
+	dataMap["completions"] = completions
+	dataMap["completion_rate"] = completionRate
+	dataMap["unique_listeners"] = listeners
+	dataMap["listened_seconds"] = listenedSeconds
+	dataMap["products"] = stats
+	api.Success(c, dataMap)
+}
@@ -11,6 +11,7 @@ import (
 	uerrors "eshop_server/src/utils/errors"
 	"eshop_server/src/utils/log"
 	"eshop_server/src/utils/mail"
+	"eshop_server/src/utils/password"
 	"eshop_server/src/utils/uuid"
 	"fmt"
 	"strings"
@@ -29,9 +30,14 @@ func UserLogin(c *gin.Context) {
 	var err error
 	req := api.GetGinBody(c)
 	dataMap := make(map[string]interface{})
-	log.Info("UserLogin 请求参数", zap.String("body", string(req)))
 
-	// TODO 添加IP风控
+	// ip登录风控
+	clientIp := c.ClientIP()
+	if isLoginIpLimited(clientIp) {
+		log.Error("UserLogin ip登录失败次数过多, 临时限制登录", zap.String("ip", clientIp))
+		api.Fail(c, uerrors.Parse(uerrors.ErrorLoginIpLimited.Error()).Code, uerrors.Parse(uerrors.ErrorLoginIpLimited.Error()).Detail)
+		return
+	}
 
 	// JSON解析
 	var reqbody model.UserLoginReq
@@ -41,6 +47,8 @@ func UserLogin(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
 		return
 	}
+	// 请求体含密码, 仅记录邮箱及ip
+	log.Infof("UserLogin 请求参数, clientIp:%s, email:%s", c.ClientIP(), reqbody.Email)
 
 	// 参数有效性判断
 	if !isValidEmail(reqbody.Email) {
@@ -49,7 +57,7 @@ func UserLogin(c *gin.Context) {
 		return
 	}
 	if !isValidPassword(reqbody.HashedPassword) {
-		log.Error("UserLogin 密码格式无效", zap.String("password", reqbody.HashedPassword))
+		log.Error("UserLogin 密码格式无效", zap.String("email", reqbody.Email))
 		api.Fail(c, uerrors.Parse(uerrors.ErrorUserLoginFail.Error()).Code, uerrors.Parse(uerrors.ErrorUserLoginFail.Error()).Detail)
 		return
 	}
@@ -58,6 +66,7 @@ func UserLogin(c *gin.Context) {
 	user, err := dao.GetUserByEmail(reqbody.Email)
 	if err != nil {
 		log.Error("UserLogin 查询用户失败", zap.Error(err))
+		recordLoginFail(clientIp, reqbody.Email, nil)
 		api.Fail(c, uerrors.Parse(uerrors.ErrorUserNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorUserNotFound.Error()).Detail)
 		return
 	}
@@ -69,29 +78,43 @@ func UserLogin(c *gin.Context) {
 		return
 	}
 
-	// 验证哈希密码是否一致
-	if reqbody.HashedPassword != user.Password {
-		log.Error("UserLogin 密码不一致", zap.String("请求哈希密码", reqbody.HashedPassword), zap.String("目标哈希密码", user.Password))
+	// 验证账户是否临时锁定, 到期自动解锁
+	if user.IsLocked() {
+		log.Error("UserLogin 用户已被临时锁定", zap.String("user_id", user.Id), zap.Time("locked_until", user.LockedUntil))
+		api.Fail(c, uerrors.Parse(uerrors.ErrorUserLocked.Error()).Code, uerrors.Parse(uerrors.ErrorUserLocked.Error()).Detail)
+		return
+	}
+
+	// 验证密码是否一致
+	if !verifyUserPassword(user, reqbody.HashedPassword) {
+		log.Error("UserLogin 密码不一致", zap.String("user_id", user.Id))
+		recordLoginFail(clientIp, reqbody.Email, user)
 		api.Fail(c, uerrors.Parse(uerrors.ErrorUserLoginFail.Error()).Code, uerrors.Parse(uerrors.ErrorUserLoginFail.Error()).Detail)
 		return
 	}
+	clearLoginFail(user)
 
-	// 生成 JWT Token
-	// TODO 新增权限区分生成不同权限token(user/admin)
-	tokenString, err := middleware.GenerateToken(user.Id, user.Roles)
+	// 拥有后台权限的账号须经两步验证登录, 不允许通过用户登录获取后台角色凭证
+	permissions, err := middleware.GetRolesPermissions(user.Roles)
 	if err != nil {
-		log.Error("UserLogin 生成jwt token失败", zap.Error(err))
-		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		log.Error("UserLogin 获取角色权限失败", zap.String("user_id", user.Id), zap.Error(err))
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	if len(permissions) > 0 {
+		log.Error("UserLogin 后台账号请使用后台登录", zap.String("user_id", user.Id), zap.Strings("roles", user.Roles))
+		api.Fail(c, uerrors.Parse(uerrors.ErrorAdminLoginRequired.Error()).Code, uerrors.Parse(uerrors.ErrorAdminLoginRequired.Error()).Detail)
 		return
 	}
-	middleware.LogAuthInfof(c, "UserLogin generate new user token, userId:%s, roles:%v, token:%s", user.Id, user.Roles, tokenString)
 
-	// 缓存保存token
-	if err = cache.SaveJxsUserToken(user.Id, tokenString); err != nil {
-		log.Errorf("UserLogin 缓存保存JxsUserToken失败, user_id:%v, token:%s, err:%v", user.Id, tokenString, err)
+	// 创建登录会话, 签发访问凭证及刷新凭证
+	tokenString, refreshToken, err := createUserSession(c, user, false)
+	if err != nil {
+		log.Error("UserLogin 创建登录会话失败", zap.Error(err))
 		api.Fail(c, uerrors.Parse(uerrors.ErrRedis.Error()).Code, uerrors.Parse(uerrors.ErrRedis.Error()).Detail)
 		return
 	}
+	middleware.LogAuthInfof(c, "UserLogin generate new user session, userId:%s, roles:%v, token:%s", user.Id, user.Roles, tokenString)
 
 	// 更新用户最后登录时间
 	user.LastLogin = time.Now()
@@ -100,6 +123,7 @@ func UserLogin(c *gin.Context) {
 	// 返回token
 	dataMap["token_type"] = middleware.TokenType
 	dataMap["access_token"] = tokenString
+	dataMap["refresh_token"] = refreshToken
 	api.Success(c, dataMap)
 }
 
@@ -112,9 +136,14 @@ func AdminLogin(c *gin.Context) {
 	var err error
 	req := api.GetGinBody(c)
 	dataMap := make(map[string]interface{})
-	log.Info("AdminLogin 请求参数", zap.String("body", string(req)))
 
-	// TODO 添加IP风控
+	// ip登录风控
+	clientIp := c.ClientIP()
+	if isLoginIpLimited(clientIp) {
+		log.Error("AdminLogin ip登录失败次数过多, 临时限制登录", zap.String("ip", clientIp))
+		api.Fail(c, uerrors.Parse(uerrors.ErrorLoginIpLimited.Error()).Code, uerrors.Parse(uerrors.ErrorLoginIpLimited.Error()).Detail)
+		return
+	}
 
 	// JSON解析
 	var reqbody model.UserLoginReq
@@ -124,6 +153,8 @@ func AdminLogin(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
 		return
 	}
+	// 请求体含密码, 仅记录邮箱及ip
+	log.Infof("AdminLogin 请求参数, clientIp:%s, email:%s", c.ClientIP(), reqbody.Email)
 
 	// 参数有效性判断
 	if !isValidEmail(reqbody.Email) {
@@ -132,7 +163,7 @@ func AdminLogin(c *gin.Context) {
 		return
 	}
 	if !isValidPassword(reqbody.HashedPassword) {
-		log.Error("AdminLogin 密码格式无效", zap.String("password", reqbody.HashedPassword))
+		log.Error("AdminLogin 密码格式无效", zap.String("email", reqbody.Email))
 		api.Fail(c, uerrors.Parse(uerrors.ErrorUserLoginFail.Error()).Code, uerrors.Parse(uerrors.ErrorUserLoginFail.Error()).Detail)
 		return
 	}
@@ -141,6 +172,7 @@ func AdminLogin(c *gin.Context) {
 	user, err := dao.GetUserByEmail(reqbody.Email)
 	if err != nil {
 		log.Error("AdminLogin 查询用户失败", zap.Error(err))
+		recordLoginFail(clientIp, reqbody.Email, nil)
 		api.Fail(c, uerrors.Parse(uerrors.ErrorUserNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorUserNotFound.Error()).Detail)
 		return
 	}
@@ -152,51 +184,48 @@ func AdminLogin(c *gin.Context) {
 		return
 	}
 
-	// 验证哈希密码是否一致
-	if reqbody.HashedPassword != user.Password {
-		log.Error("AdminLogin 密码不一致", zap.String("请求哈希密码", reqbody.HashedPassword), zap.String("目标哈希密码", user.Password))
+	// 验证账户是否临时锁定, 到期自动解锁
+	if user.IsLocked() {
+		log.Error("AdminLogin 用户已被临时锁定", zap.String("user_id", user.Id), zap.Time("locked_until", user.LockedUntil))
+		api.Fail(c, uerrors.Parse(uerrors.ErrorUserLocked.Error()).Code, uerrors.Parse(uerrors.ErrorUserLocked.Error()).Detail)
+		return
+	}
+
+	// 验证密码是否一致
+	if !verifyUserPassword(user, reqbody.HashedPassword) {
+		log.Error("AdminLogin 密码不一致", zap.String("user_id", user.Id))
+		recordLoginFail(clientIp, reqbody.Email, user)
 		api.Fail(c, uerrors.Parse(uerrors.ErrorUserLoginFail.Error()).Code, uerrors.Parse(uerrors.ErrorUserLoginFail.Error()).Detail)
 		return
 	}
 
-	// 验证管理员权限
-	_roleCheckPass := false
-	for _, role := range user.Roles {
-		if role == model.UserRoleAdmin {
-			_roleCheckPass = true
-			break
-		}
+	// 验证后台权限, 管理员及拥有权限的运营角色可登录
+	permissions, err := middleware.GetRolesPermissions(user.Roles)
+	if err != nil {
+		log.Error("AdminLogin 获取角色权限失败", zap.String("user_id", user.Id), zap.Error(err))
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
 	}
-	if !_roleCheckPass {
+	if len(permissions) == 0 {
 		log.Errorf("AdminLogin 用户权限不足, user_id:%s, roles:%v", user.Id, user.Roles)
 		api.Fail(c, uerrors.Parse(uerrors.ErrorShopUserUnAuthorization.Error()).Code, uerrors.Parse(uerrors.ErrorShopUserUnAuthorization.Error()).Detail)
 		return
 	}
 
-	// 生成 JWT Token
-	// TODO 新增权限区分生成不同权限token(user/admin)
-	tokenString, err := middleware.GenerateToken(user.Id, user.Roles)
+	// 签发两步验证凭证, 完成两步验证后才签发登录会话, 失败计数在两步验证通过后清除
+	mfaToken, err := createAdminMfaChallenge(user)
 	if err != nil {
-		log.Error("AdminLogin 生成jwt token失败", zap.Error(err))
-		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
-		return
-	}
-	middleware.LogAuthInfof(c, "AdminLogin  generate new admin token, userId:%s, roles:%v, token:%s", user.Id, user.Roles, tokenString)
-
-	// 缓存保存token
-	if err = cache.SaveJxsUserToken(user.Id, tokenString); err != nil {
-		log.Errorf("AdminLogin 缓存保存JxsUserToken失败, user_id:%v, token:%s, err:%v", user.Id, tokenString, err)
+		log.Error("AdminLogin 签发两步验证凭证失败", zap.Error(err))
 		api.Fail(c, uerrors.Parse(uerrors.ErrRedis.Error()).Code, uerrors.Parse(uerrors.ErrRedis.Error()).Detail)
 		return
 	}
+	log.Infof("AdminLogin 密码验证通过, 等待两步验证, user_id:%s, totp_enabled:%v", user.Id, user.IsTotpEnabled())
 
-	// 更新用户最后登录时间
-	user.LastLogin = time.Now()
-	dao.UpdateUserByField(user, []string{"last_login"})
-
-	// 返回token
-	dataMap["token_type"] = middleware.TokenType
-	dataMap["access_token"] = tokenString
+	// 未绑定验证器时需先绑定
+	dataMap["mfa_required"] = true
+	dataMap["mfa_enroll_required"] = !user.IsTotpEnabled()
+	dataMap["mfa_token"] = mfaToken
+	dataMap["mfa_token_expires_in"] = cache.KeyJxsMfaChallengeTimeout
 	api.Success(c, dataMap)
 }
 
@@ -222,20 +251,19 @@ func UserLogout(c *gin.Context) {
 		return
 	}
 
-	// 校验Token
+	// 校验Token, 已过期的token同样可以注销会话
 	requestToken := parts[1]
-	claims, err := middleware.ValidateToken(requestToken)
+	claims, err := middleware.GetTokenClaims(requestToken)
 	if err != nil {
 		log.Warn("UserLogout 解析token失败", zap.Error(err))
 		api.Success(c, nil)
 		return
 	}
 
-	// 从Redis中删除token
-	if claims.UserId != "" {
-		isDel := cache.DelJxsUserToken(claims.UserId)
-		if !isDel {
-			log.Error("UserLogout 删除缓存JxsUserToken失败", zap.String("user_id", claims.UserId))
+	// 注销当前登录会话
+	if claims.UserId != "" && claims.SessionId != "" {
+		if err = cache.DelJxsUserSession(claims.UserId, claims.SessionId); err != nil {
+			log.Error("UserLogout 注销登录会话失败", zap.String("user_id", claims.UserId), zap.String("session_id", claims.SessionId), zap.Error(err))
 		}
 	}
 	api.Success(c, nil)
@@ -250,7 +278,6 @@ func UserRegister(c *gin.Context) {
 	var err error
 	req := api.GetGinBody(c)
 	dataMap := make(map[string]interface{})
-	log.Info("UserRegister 请求参数", zap.String("body", string(req)))
 
 	// TODO 添加IP风控
 
@@ -262,6 +289,8 @@ func UserRegister(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
 		return
 	}
+	// 请求体含密码, 仅记录邮箱及ip
+	log.Infof("UserRegister 请求参数, clientIp:%s, email:%s", c.ClientIP(), reqbody.Email)
 
 	// 查询用户是否存在
 	user, err := dao.GetUserByEmail(reqbody.Email)
@@ -271,12 +300,25 @@ func UserRegister(c *gin.Context) {
 		return
 	}
 
+	// 生成密码哈希
+	if !isValidPassword(reqbody.HashedPassword) {
+		log.Error("UserRegister 密码格式无效", zap.String("email", reqbody.Email))
+		api.Fail(c, uerrors.Parse(uerrors.ErrorPasswordInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorPasswordInvalid.Error()).Detail)
+		return
+	}
+	hashedPassword, err := password.Hash(reqbody.HashedPassword)
+	if err != nil {
+		log.Error("UserRegister 生成密码哈希失败", zap.Error(err))
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+
 	// 创建新用户
 	new_user := &model.User{
 		Id:        uuid.GetUuid(), // 随机生成用户ID字符串
 		Name:      reqbody.Name,
 		Email:     reqbody.Email,
-		Password:  reqbody.HashedPassword,
+		Password:  hashedPassword,
 		AvatarUrl: "",                           // 默认头像URL为空
 		Roles:     []string{model.UserRoleUser}, // 默认普通用户角色
 		Status:    model.UserStatusNormal,
@@ -302,7 +344,6 @@ func UserRegisterWithVerifyCode(c *gin.Context) {
 	var err error
 	req := api.GetGinBody(c)
 	dataMap := make(map[string]interface{})
-	log.Infof("UserRegisterWithVerifyCode 请求参数, req:%s", string(req))
 
 	// JSON解析
 	var reqbody model.UserRegisterReq
@@ -312,6 +353,8 @@ func UserRegisterWithVerifyCode(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
 		return
 	}
+	// 请求体含密码, 仅记录邮箱及ip
+	log.Infof("UserRegisterWithVerifyCode 请求参数, clientIp:%s, email:%s", c.ClientIP(), reqbody.Email)
 
 	// 验证码校验
 	if reqbody.VerifyCode == "" {
@@ -341,12 +384,25 @@ func UserRegisterWithVerifyCode(c *gin.Context) {
 		return
 	}
 
+	// 生成密码哈希
+	if !isValidPassword(reqbody.HashedPassword) {
+		log.Error("UserRegisterWithVerifyCode 密码格式无效", zap.String("email", reqbody.Email))
+		api.Fail(c, uerrors.Parse(uerrors.ErrorPasswordInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorPasswordInvalid.Error()).Detail)
+		return
+	}
+	hashedPassword, err := password.Hash(reqbody.HashedPassword)
+	if err != nil {
+		log.Error("UserRegisterWithVerifyCode 生成密码哈希失败", zap.Error(err))
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+
 	// 创建新用户
 	new_user := &model.User{
 		Id:       uuid.GetUuid(), // 随机生成用户ID字符串
 		Name:     reqbody.Name,
 		Email:    reqbody.Email,
-		Password: reqbody.HashedPassword,
+		Password: hashedPassword,
 	}
 	_, err = dao.CreateUser(new_user)
 	if err != nil {
@@ -361,7 +417,7 @@ func UserRegisterWithVerifyCode(c *gin.Context) {
 }
 
 // @Title        用户刷新token
-// @Description  传入旧token用于获取新token
+// @Description  使用刷新凭证换取新的访问凭证, 刷新凭证每次使用后轮换, 重复使用已轮换的刷新凭证时注销该会话
 // @Param        json
 // @Produce      json
 // @Router       /v1/eshop_api/auth/refresh_token [post]
@@ -369,9 +425,6 @@ func RefreshToken(c *gin.Context) {
 	var err error
 	req := api.GetGinBody(c)
 	dataMap := make(map[string]interface{})
-	log.Info("RefreshToken 请求参数", zap.String("body", string(req)))
-
-	// TODO 添加IP风控
 
 	// JSON解析
 	var reqbody model.RefreshTokenReq
@@ -381,55 +434,72 @@ func RefreshToken(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
 		return
 	}
-	// 校验token是否为空
-	if reqbody.OldToken == "" {
-		log.Error("RefreshToken token为空", zap.String("token", reqbody.OldToken))
+	// 解析刷新凭证
+	sessionId, refreshHash, ok := parseRefreshToken(reqbody.RefreshToken)
+	if !ok {
+		log.Error("RefreshToken 刷新凭证格式错误")
 		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail)
 		return
 	}
 
-	// 校验旧token
-	claims, err := middleware.GetTokenClaims(reqbody.OldToken)
-	if err != nil {
-		log.Error("RefreshToken 解析token失败", zap.Error(err))
-		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+	// 校验登录会话
+	session, err := cache.GetJxsUserSession(sessionId)
+	if err != nil || session["user_id"] == "" {
+		middleware.LogAuthErrorf(c, "RefreshToken 登录会话不存在或已注销, sessionId:%s, err:%v", sessionId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorRelogin.Error()).Code, uerrors.Parse(uerrors.ErrorRelogin.Error()).Detail)
 		return
 	}
 
 	// 校验user_id是否有效
-	user, err := dao.GetValidUserById(claims.UserId)
+	user, err := dao.GetValidUserById(session["user_id"])
 	if err != nil {
-		log.Errorf("RefreshToken 查询用户失败, userId:%s, error:%v", claims.UserId, err)
-		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		log.Errorf("RefreshToken 查询用户失败, userId:%s, error:%v", session["user_id"], err)
+		cache.DelJxsUserSession(session["user_id"], sessionId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorRelogin.Error()).Code, uerrors.Parse(uerrors.ErrorRelogin.Error()).Detail)
 		return
 	}
 
-	// 校验token是否过期
-	if claims.ExpiresAt < time.Now().Unix() { // token已过期，生成新token
-		log.Warnf("RefreshToken token已过期，生成新token, old_token:%s", reqbody.OldToken)
-		newToken, err := middleware.GenerateToken(user.Id, user.Roles)
-		if err != nil {
-			middleware.LogAuthErrorf(c, "RefreshToken 过期token刷新失败, userId:%s, roles:%v, err:%v", user.Id, user.Roles, err)
-			api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
-			return
-		}
-		middleware.LogAuthInfof(c, "RefreshToken 过期token刷新成功, userId:%s, roles:%v, new_token:%s", user.Id, user.Roles, newToken)
-		dataMap["access_token"] = newToken
-	} else if claims.ExpiresAt-time.Now().Unix() < int64(middleware.TokenRefreshWindow.Seconds()) { // token临界过期，在动态刷新token窗口内，生成新token
-		log.Warnf("RefreshToken token达刷新临界时间，生成新token, old_token:%s", reqbody.OldToken)
-		newToken, err := middleware.GenerateToken(user.Id, user.Roles)
-		if err != nil {
-			middleware.LogAuthErrorf(c, "RefreshToken 临期token刷新失败, userId:%s, roles:%v, err:%v", user.Id, user.Roles, err)
-			api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
-			return
+	// 轮换刷新凭证
+	newRefreshToken, newRefreshHash, err := generateRefreshToken(sessionId)
+	if err != nil {
+		log.Errorf("RefreshToken 生成刷新凭证失败, userId:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+	result, err := cache.RotateJxsUserSession(user.Id, sessionId, refreshHash, newRefreshHash, c.ClientIP(), getSessionConf().RefreshTokenTimeout)
+	if err != nil {
+		log.Errorf("RefreshToken 轮换刷新凭证失败, userId:%s, sessionId:%s, error:%v", user.Id, sessionId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrRedis.Error()).Code, uerrors.Parse(uerrors.ErrRedis.Error()).Detail)
+		return
+	}
+	switch result {
+	case cache.JxsUserSessionRotateOk:
+	case cache.JxsUserSessionRotateReused:
+		// 已轮换的刷新凭证被重复使用, 凭证可能已泄露, 注销该会话
+		middleware.LogAuthErrorf(c, "RefreshToken 刷新凭证被重复使用, 注销会话, userId:%s, sessionId:%s", user.Id, sessionId)
+		if err = cache.DelJxsUserSession(user.Id, sessionId); err != nil {
+			log.Errorf("RefreshToken 注销会话失败, userId:%s, sessionId:%s, error:%v", user.Id, sessionId, err)
 		}
-		middleware.LogAuthInfof(c, "RefreshToken 临期token刷新成功, userId:%s, roles:%v, new_token:%s", user.Id, user.Roles, newToken)
-		dataMap["access_token"] = newToken
-	} else { // token未过期，直接返回旧token
-		middleware.LogAuthInfof(c, "RefreshToken 未过期返回旧token, userId:%s, roles:%v, old_token:%s", user.Id, user.Roles, reqbody.OldToken)
-		dataMap["access_token"] = reqbody.OldToken
+		api.Fail(c, uerrors.Parse(uerrors.ErrorRelogin.Error()).Code, uerrors.Parse(uerrors.ErrorRelogin.Error()).Detail)
+		return
+	default:
+		middleware.LogAuthErrorf(c, "RefreshToken 刷新凭证无效, userId:%s, sessionId:%s", user.Id, sessionId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorRelogin.Error()).Code, uerrors.Parse(uerrors.ErrorRelogin.Error()).Detail)
+		return
 	}
+
+	// 签发新的访问凭证
+	newToken, err := middleware.GenerateToken(user.Id, user.Roles, sessionId)
+	if err != nil {
+		middleware.LogAuthErrorf(c, "RefreshToken 生成token失败, userId:%s, roles:%v, err:%v", user.Id, user.Roles, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+	middleware.LogAuthInfof(c, "RefreshToken 刷新token成功, userId:%s, roles:%v, sessionId:%s, new_token:%s", user.Id, user.Roles, sessionId, newToken)
+
 	dataMap["token_type"] = middleware.TokenType
+	dataMap["access_token"] = newToken
+	dataMap["refresh_token"] = newRefreshToken
 	api.Success(c, dataMap)
 }
 
@@ -491,6 +561,142 @@ func VerifyEmail(c *gin.Context) {
 	api.Success(c, dataMap)
 }
 
+// @Title		找回密码
+// @Description	往已注册邮箱发送找回密码验证码, 邮箱未注册时同样返回成功
+// @Produce      json
+// @Router       /v1/eshop_api/auth/forgot_password [post]
+func ForgotPassword(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+	log.Infof("ForgotPassword 请求参数, reqbody:%s", string(req))
+
+	// JSON解析
+	var reqbody model.UserForgotPasswordReq
+	err = json.Unmarshal(req, &reqbody)
+	if err != nil {
+		log.Errorf("ForgotPassword json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+	// 邮箱查询不区分大小写, 规范化后再作为缓存key, 避免大小写变体绕过发送频率及尝试次数限制
+	reqbody.Email = normalizeEmail(reqbody.Email)
+	// 校验邮箱
+	if !isValidEmail(reqbody.Email) {
+		log.Errorf("ForgotPassword 邮箱格式错误, reqbody.email:%s", reqbody.Email)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorEmailInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorEmailInvalid.Error()).Detail)
+		return
+	}
+
+	// 限制发送频率
+	ok, err := cache.LockJxsResetPasswordSend(reqbody.Email)
+	if err != nil {
+		log.Errorf("ForgotPassword 缓存发送间隔失败, toEmail:%s, error:%v", reqbody.Email, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrRedis.Error()).Code, uerrors.Parse(uerrors.ErrRedis.Error()).Detail)
+		return
+	}
+	if !ok {
+		log.Errorf("ForgotPassword 发送太频繁, clientIp:%s, toEmail:%s", c.ClientIP(), reqbody.Email)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorSendMailFastFail.Error()).Code, uerrors.Parse(uerrors.ErrorSendMailFastFail.Error()).Detail)
+		return
+	}
+
+	// 邮箱未注册或用户已禁用时不发送邮件, 避免暴露注册信息
+	user, err := dao.GetUserByEmail(reqbody.Email)
+	if err != nil || user.Id == "" || user.Status == model.UserStatusBanned {
+		log.Warnf("ForgotPassword 邮箱未注册或用户不可用, clientIp:%s, toEmail:%s, error:%v", c.ClientIP(), reqbody.Email, err)
+		api.Success(c, dataMap)
+		return
+	}
+
+	// 缓存验证码
+	code := mail.GenerateRandomEmailCode()
+	if err = cache.SaveJxsResetPasswordCode(reqbody.Email, code); err != nil {
+		log.Errorf("ForgotPassword 缓存验证码失败, toEmail:%s, error:%v", reqbody.Email, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+
+	// 发送验证码
+	if err = SendEshopResetPasswordCodeToEmail(user.Email, code); err != nil {
+		log.Errorf("ForgotPassword 发送验证码失败, toEmail:%s, error:%v", reqbody.Email, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+
+	log.Infof("ForgotPassword 发送找回密码邮件成功, user_id:%s, toEmail:%s", user.Id, reqbody.Email)
+	api.Success(c, dataMap)
+}
+
+// @Title		通过验证码重置密码
+// @Description	校验找回密码验证码并重置密码, 成功后注销用户所有登录态
+// @Produce      json
+// @Router       /v1/eshop_api/auth/reset_password_by_code [post]
+func ResetPasswordByCode(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+
+	// JSON解析
+	var reqbody model.UserResetPasswordByCodeReq
+	err = json.Unmarshal(req, &reqbody)
+	if err != nil {
+		log.Errorf("ResetPasswordByCode json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+	reqbody.Email = normalizeEmail(reqbody.Email)
+	log.Infof("ResetPasswordByCode 请求参数, clientIp:%s, email:%s", c.ClientIP(), reqbody.Email)
+	if !isValidEmail(reqbody.Email) || reqbody.Code == "" {
+		log.Errorf("ResetPasswordByCode 请求参数错误, email:%s", reqbody.Email)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail)
+		return
+	}
+	if !isValidPassword(reqbody.NewPassword) {
+		log.Errorf("ResetPasswordByCode 新密码格式无效, email:%s", reqbody.Email)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorPasswordInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorPasswordInvalid.Error()).Detail)
+		return
+	}
+
+	// 校验验证码, 超过最大尝试次数后验证码失效
+	if !cache.CheckJxsResetPasswordCode(reqbody.Email, reqbody.Code) {
+		log.Errorf("ResetPasswordByCode 验证码错误或已失效, clientIp:%s, email:%s", c.ClientIP(), reqbody.Email)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorResetCodeInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorResetCodeInvalid.Error()).Detail)
+		return
+	}
+
+	// 查询用户
+	user, err := dao.GetUserByEmail(reqbody.Email)
+	if err != nil || user.Id == "" || user.Status == model.UserStatusBanned {
+		log.Errorf("ResetPasswordByCode 用户不存在或已禁用, email:%s, error:%v", reqbody.Email, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorResetCodeInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorResetCodeInvalid.Error()).Detail)
+		return
+	}
+
+	// 更新密码
+	user.Password, err = password.Hash(reqbody.NewPassword)
+	if err != nil {
+		log.Errorf("ResetPasswordByCode 生成密码哈希失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+	if _, err = dao.UpdateUserByField(user, []string{"password"}); err != nil {
+		log.Errorf("ResetPasswordByCode 更新用户密码失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+
+	// 验证码仅可使用一次, 解除账号锁定并注销用户所有登录会话
+	cache.DelJxsResetPasswordCode(reqbody.Email)
+	clearLoginFail(user)
+	if err = cache.DelJxsUserSessions(user.Id); err != nil {
+		log.Errorf("ResetPasswordByCode 注销用户登录会话失败, user_id:%s, error:%v", user.Id, err)
+	}
+
+	log.Infof("ResetPasswordByCode 用户重置密码成功, user_id:%s, email:%s", user.Id, reqbody.Email)
+	api.Success(c, dataMap)
+}
+
 // 邮箱有效性判断
 func isValidEmail(email string) bool {
 	if email == "" {
@@ -502,14 +708,41 @@ func isValidEmail(email string) bool {
 	return true
 }
 
+// 邮箱规范化, 去除首尾空白并转小写, 与数据库不区分大小写的邮箱匹配保持一致
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // 密码有效性判断
-func isValidPassword(password string) bool {
-	if password == "" {
+func isValidPassword(pwd string) bool {
+	if pwd == "" {
+		return false
+	}
+	if len(pwd) < 6 || len(pwd) > password.MaxLength {
 		return false
 	}
-	if len(password) < 6 {
+	return true
+}
+
+// 校验用户密码, 旧数据或哈希参数与当前配置不一致时重新生成哈希保存
+func verifyUserPassword(user *model.User, pwd string) bool {
+	ok, needRehash := password.Verify(pwd, user.Password)
+	if !ok {
 		return false
 	}
+	if needRehash {
+		hashedPassword, err := password.Hash(pwd)
+		if err != nil {
+			log.Errorf("verifyUserPassword 生成密码哈希失败, user_id:%s, error:%v", user.Id, err)
+			return true
+		}
+		user.Password = hashedPassword
+		if _, err = dao.UpdateUserByField(user, []string{"password"}); err != nil {
+			log.Errorf("verifyUserPassword 更新用户密码哈希失败, user_id:%s, error:%v", user.Id, err)
+			return true
+		}
+		log.Infof("verifyUserPassword 用户密码哈希已更新, user_id:%s", user.Id)
+	}
 	return true
 }
 
@@ -539,3 +772,16 @@ func SendEshopVerifyCodeToEmail(toemail string, code string) (err error) {
 	}
 	return nil
 }
+
+// 发送Eshop的找回密码验证码
+// 有效时间为cache.KeyJxsResetPasswordCodeMinsLimit分钟
+func SendEshopResetPasswordCodeToEmail(toemail string, code string) (err error) {
+	title := "【江心上客栈】找回您的账户密码"
+	text := fmt.Sprintf("您正在找回江心上客栈账户密码。您的验证码为：%s，有效时间%v分钟。如非本人操作，请忽略本邮件。", code, cache.KeyJxsResetPasswordCodeMinsLimit)
+	err = mail.SendEmail(toemail, title, text)
+	if err != nil {
+		log.Errorf("SendEshopResetPasswordCodeToEmail 发送验证码邮件失败, to:%s, title:%s, error:%v", toemail, title, err)
+		return err
+	}
+	return nil
+}
@@ -1,11 +1,19 @@
 package handler
 
 import (
+	"encoding/json"
 	"eshop_server/src/common/api"
+	"eshop_server/src/common/cache"
 	"eshop_server/src/router/dao"
+	"eshop_server/src/router/middleware"
 	"eshop_server/src/router/model"
+	"eshop_server/src/utils/config"
 	uerrors "eshop_server/src/utils/errors"
 	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uuid"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -65,3 +73,197 @@ func GetInventoryList(c *gin.Context) {
 	log.Infof("GetInventoryList user_id: %s 查询作品成功，返回数据: %+v", user.Id, dataMap)
 	api.Success(c, dataMap)
 }
+
+// @Title			获取藏品播放地址
+// @Description		校验用户购买权限，签发带时效播放凭证的播放地址
+// @Router			/v1/eshop_api/user/inventory/player?product_id= [get]
+// @Response		json
+func GetInventoryPlayer(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	// JWT用户查询&鉴权
+	user, err := isValidUser(c)
+	if err != nil {
+		log.Error("GetInventoryPlayer 非法用户请求", zap.Error(err))
+		api.FailWithAuthorization(c)
+		return
+	}
+
+	// 参数解析
+	productId := c.Query("product_id")
+	if productId == "" {
+		log.Errorf("GetInventoryPlayer 请求参数错误, product_id为空, user_id: %s", user.Id)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":商品ID为空")
+		return
+	}
+	log.Infof("GetInventoryPlayer 请求参数, user_id: %s, product_id: %s", user.Id, productId)
+
+	// 校验用户购买权限
+	purchased, err := dao.CheckUserPurchasedProduct(user.Id, productId)
+	if err != nil {
+		log.Errorf("GetInventoryPlayer 查询购买历史记录失败, user_id: %s, error: %s", user.Id, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	if !purchased {
+		log.Errorf("GetInventoryPlayer 用户未购买该商品, user_id: %s, product_id: %s", user.Id, productId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamNotPurchased.Error()).Code, uerrors.Parse(uerrors.ErrorStreamNotPurchased.Error()).Detail)
+		return
+	}
+
+	// 查询商品可播放资源, 按曲目顺序
+	playerList, err := dao.GetProductsPlayerTracklist(productId, []int32{model.ProductsPlayerStatusOk})
+	if err != nil {
+		log.Errorf("GetInventoryPlayer 查询商品播放资源失败, product_id: %s, error: %s", productId, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+
+	// 为每个播放资源签发播放凭证
+	GetInventoryPlayerResponseList := make([]model.GetInventoryPlayerResponse, 0)
+	for _, player := range playerList {
+		if player.PlayUrl == "" {
+			continue
+		}
+		playUrl, hifiUrl, expiresAt, err := signPlaybackUrl(user.Id, c.GetString("sessionId"), player)
+		if err != nil {
+			log.Errorf("GetInventoryPlayer 生成播放凭证失败, user_id: %s, player_id: %s, error: %s", user.Id, player.Id, err.Error())
+			api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+			return
+		}
+		playerResponse := model.GetInventoryPlayerResponse{
+			PlayerId:  player.Id,
+			DiscNo:    player.DiscNo,
+			TrackNo:   player.TrackNo,
+			Title:     player.TrackViewFormat().Title,
+			Filename:  player.Filename,
+			Duration:  player.Duration,
+			PlayType:  player.PlayType,
+			PlayUrl:   playUrl,
+			HifiUrl:   hifiUrl,
+			ExpiresAt: expiresAt,
+		}
+		GetInventoryPlayerResponseList = append(GetInventoryPlayerResponseList, playerResponse)
+	}
+
+	dataMap["player_list"] = GetInventoryPlayerResponseList
+	api.Success(c, dataMap)
+}
+
+// 签发播放凭证, 返回带凭证的播放地址及无损播放地址
+// deviceId 取当前登录会话id, 同一设备切换曲目时计为同一播放会话
+func signPlaybackUrl(userId string, deviceId string, player *model.ProductsPlayer) (playUrl string, hifiUrl string, expiresAt time.Time, err error) {
+	token, expiresAt, err := middleware.GeneratePlaybackToken(userId, player.Id, deviceId)
+	if err != nil {
+		return "", "", expiresAt, err
+	}
+	playUrl = player.PlayUrl + "?token=" + url.QueryEscape(token)
+	if player.HifiUrl != "" {
+		hifiUrl = player.HifiUrl + "?token=" + url.QueryEscape(token)
+	}
+	return playUrl, hifiUrl, expiresAt, nil
+}
+
+// @Title			获取藏品下载地址
+// @Description		校验用户购买权限及下载次数，签发一次性下载地址，下载文件内嵌购买用户信息
+// @Param			player_id 播放资源id
+// @Param			format 下载格式 original/mp3/wav/flac, 默认original
+// @Router			/v1/eshop_api/user/inventory/download?player_id=&format= [get]
+// @Response		json
+func GetInventoryDownload(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	// JWT用户查询&鉴权
+	user, err := isValidUser(c)
+	if err != nil {
+		log.Error("GetInventoryDownload 非法用户请求", zap.Error(err))
+		api.FailWithAuthorization(c)
+		return
+	}
+
+	// 参数解析
+	playerId := c.Query("player_id")
+	format := strings.ToLower(c.DefaultQuery("format", model.DownloadFormatOriginal))
+	if playerId == "" || !model.IsDownloadFormat(format) {
+		log.Errorf("GetInventoryDownload 请求参数错误, user_id: %s, player_id: %s, format: %s", user.Id, playerId, format)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":播放资源ID为空或不支持该下载格式")
+		return
+	}
+	log.Infof("GetInventoryDownload 请求参数, user_id: %s, player_id: %s, format: %s", user.Id, playerId, format)
+
+	// 查询播放资源
+	player, err := dao.GetProductsPlayerById(playerId)
+	if err != nil || player.Status != model.ProductsPlayerStatusOk {
+		log.Errorf("GetInventoryDownload 播放资源不存在或不可下载, player_id: %s, error: %v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":播放资源不存在或不可下载")
+		return
+	}
+
+	// 校验用户购买权限, 选取仍有剩余下载次数的购买记录
+	purchaseList, err := dao.GetPurchaseHistorysByUserIdAndProductId(user.Id, player.ProductId)
+	if err != nil {
+		log.Errorf("GetInventoryDownload 查询购买历史记录失败, user_id: %s, error: %s", user.Id, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	if len(purchaseList) == 0 {
+		log.Errorf("GetInventoryDownload 用户未购买该商品, user_id: %s, product_id: %s", user.Id, player.ProductId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamNotPurchased.Error()).Code, uerrors.Parse(uerrors.ErrorStreamNotPurchased.Error()).Detail)
+		return
+	}
+	maxDownloads := config.StreamConfig.Download.MaxDownloads
+	if maxDownloads == 0 {
+		maxDownloads = model.DownloadDefaultMaxDownloads
+	}
+	var purchase *model.PurchaseHistory
+	for _, p := range purchaseList {
+		if maxDownloads < 0 || p.DownloadCount < maxDownloads {
+			purchase = p
+			break
+		}
+	}
+	if purchase == nil {
+		log.Errorf("GetInventoryDownload 下载次数已达上限, user_id: %s, product_id: %s, max_downloads: %d", user.Id, player.ProductId, maxDownloads)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamDownloadLimit.Error()).Code, uerrors.Parse(uerrors.ErrorStreamDownloadLimit.Error()).Detail)
+		return
+	}
+
+	// 签发一次性下载凭证
+	if format == model.DownloadFormatOriginal {
+		format = strings.TrimPrefix(player.FileType, ".")
+	}
+	linkTimeout := config.StreamConfig.Download.LinkTimeout
+	if linkTimeout <= 0 {
+		linkTimeout = model.DownloadDefaultLinkTimeout
+	}
+	ticket := &model.DownloadTicket{
+		TicketId:   uuid.GetUuid(),
+		UserId:     user.Id,
+		PlayerId:   player.Id,
+		ProductId:  player.ProductId,
+		PurchaseId: purchase.Id,
+		Format:     format,
+		CreatedAt:  time.Now().Unix(),
+	}
+	b, _ := json.Marshal(ticket)
+	if err = cache.SaveStreamDownload(ticket.TicketId, b, linkTimeout); err != nil {
+		log.Errorf("GetInventoryDownload 保存下载凭证失败, ticket: %+v, error: %s", ticket, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+
+	remaining := int64(-1)
+	if maxDownloads >= 0 {
+		remaining = maxDownloads - purchase.DownloadCount - 1
+	}
+	dataMap["result"] = model.GetInventoryDownloadResponse{
+		PlayerId:          player.Id,
+		Format:            format,
+		DownloadUrl:       config.StreamConfig.DownloadHost + ticket.TicketId,
+		ExpiresAt:         time.Unix(ticket.CreatedAt+linkTimeout, 0),
+		RemainingDownload: remaining,
+	}
+	api.Success(c, dataMap)
+}
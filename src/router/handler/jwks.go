@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"eshop_server/src/router/middleware"
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/log"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Title		 获取jwt公钥
+// @Description  返回仍在校验期内的jwt公钥（JWKS格式）, 供stream、cronjob等服务仅凭公钥校验token
+// @Produce      json
+// @Router       /.well-known/jwks.json [get]
+func GetJwks(c *gin.Context) {
+	jwks, err := middleware.GetJwks()
+	if err != nil {
+		log.Errorf("GetJwks 加载jwt密钥失败, err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", model.JwksCacheMaxAge))
+	c.JSON(http.StatusOK, jwks)
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"eshop_server/src/common/cache"
+	"eshop_server/src/router/dao"
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/mail"
+	"fmt"
+	"time"
+)
+
+// 获取登录风控配置, 未配置的项使用默认值
+func getLoginGuardConf() config.LoginGuardConf {
+	conf := config.CommonConfig.LoginGuard
+	if conf.FailWindow <= 0 {
+		conf.FailWindow = model.LoginGuardDefaultFailWindow
+	}
+	if conf.AccountMaxAttempts == 0 {
+		conf.AccountMaxAttempts = model.LoginGuardDefaultAccountMaxAttempts
+	}
+	if conf.IpMaxAttempts == 0 {
+		conf.IpMaxAttempts = model.LoginGuardDefaultIpMaxAttempts
+	}
+	if conf.LockDuration <= 0 {
+		conf.LockDuration = model.LoginGuardDefaultLockDuration
+	}
+	if conf.DelayAfter == 0 {
+		conf.DelayAfter = model.LoginGuardDefaultDelayAfter
+	}
+	if conf.MaxDelay <= 0 {
+		conf.MaxDelay = model.LoginGuardDefaultMaxDelay
+	}
+	return conf
+}
+
+// 判断ip是否被临时限制登录
+func isLoginIpLimited(clientIp string) bool {
+	conf := getLoginGuardConf()
+	return conf.IpMaxAttempts > 0 && cache.GetJxsLoginFailIp(clientIp) >= conf.IpMaxAttempts
+}
+
+// 记录登录失败
+// 1. ip失败次数达到上限时临时限制该ip登录
+// 2. 账号失败次数达到上限时临时锁定账号并邮件通知用户, user为空时仅计数
+// 3. 失败次数超过延迟阈值后逐次倍增延迟响应
+func recordLoginFail(clientIp string, email string, user *model.User) {
+	conf := getLoginGuardConf()
+
+	ipFails, err := cache.IncrJxsLoginFailIp(clientIp, conf.FailWindow)
+	if err != nil {
+		log.Errorf("recordLoginFail ip失败计数失败, ip:%s, error:%v", clientIp, err)
+	}
+	if conf.IpMaxAttempts > 0 && ipFails == conf.IpMaxAttempts {
+		if err = cache.ExpireJxsLoginFailIp(clientIp, conf.LockDuration); err != nil {
+			log.Errorf("recordLoginFail 限制ip登录失败, ip:%s, error:%v", clientIp, err)
+		}
+		log.Warnf("recordLoginFail ip登录失败次数过多, 临时限制登录, ip:%s, fails:%d, duration:%ds", clientIp, ipFails, conf.LockDuration)
+	}
+
+	account := getLoginFailAccount(email, user)
+	accountFails, err := cache.IncrJxsLoginFailAccount(account, conf.FailWindow)
+	if err != nil {
+		log.Errorf("recordLoginFail 账号失败计数失败, email:%s, error:%v", email, err)
+	}
+	if user != nil && conf.AccountMaxAttempts > 0 && accountFails >= conf.AccountMaxAttempts && !user.IsLocked() {
+		lockedUntil := time.Now().Add(time.Duration(conf.LockDuration) * time.Second)
+		if err = dao.LockUser(user.Id, accountFails, lockedUntil); err != nil {
+			log.Errorf("recordLoginFail 锁定账号失败, user_id:%s, error:%v", user.Id, err)
+		} else {
+			// 解锁后重新计数
+			cache.DelJxsLoginFailAccount(account)
+			log.Warnf("recordLoginFail 账号登录失败次数过多, 临时锁定账号, user_id:%s, email:%s, ip:%s, fails:%d, locked_until:%s",
+				user.Id, email, clientIp, accountFails, lockedUntil.Format(time.DateTime))
+			go func() {
+				if mailErr := SendEshopAccountLockedToEmail(email, lockedUntil); mailErr != nil {
+					log.Errorf("recordLoginFail 发送账号锁定通知失败, user_id:%s, error:%v", user.Id, mailErr)
+				}
+			}()
+		}
+	}
+
+	// 延迟响应, 首次超过阈值时延迟1秒, 之后逐次倍增至最大延迟
+	fails := accountFails
+	if ipFails > fails {
+		fails = ipFails
+	}
+	if conf.DelayAfter > 0 && fails > conf.DelayAfter {
+		delay := conf.MaxDelay
+		if n := fails - conf.DelayAfter - 1; n < 16 && int64(1)<<n < delay {
+			delay = int64(1) << n
+		}
+		time.Sleep(time.Duration(delay) * time.Second)
+	}
+}
+
+// 账号失败计数标识, 用户存在时使用用户ID, 否则使用规范化的邮箱
+// 邮箱查询不区分大小写, 避免同一账号的邮箱大小写变体各自计数
+func getLoginFailAccount(email string, user *model.User) string {
+	if user != nil {
+		return user.Id
+	}
+	return normalizeEmail(email)
+}
+
+// 登录成功后清除账号失败计数并解除锁定
+func clearLoginFail(user *model.User) {
+	cache.DelJxsLoginFailAccount(getLoginFailAccount(user.Email, user))
+	if user.LoginAttempts == 0 && user.LockedUntil.IsZero() {
+		return
+	}
+	if err := dao.UnlockUser(user.Id); err != nil {
+		log.Errorf("clearLoginFail 解除账号锁定失败, user_id:%s, error:%v", user.Id, err)
+		return
+	}
+	user.LoginAttempts = 0
+	user.LockedUntil = time.Time{}
+}
+
+// 发送Eshop的账号锁定通知
+func SendEshopAccountLockedToEmail(toemail string, lockedUntil time.Time) (err error) {
+	title := "【江心上客栈】您的账户已被临时锁定"
+	text := fmt.Sprintf("您的江心上客栈账户因多次登录失败已被临时锁定，将于%s自动解锁。如非本人操作，建议解锁后立即通过找回密码修改密码。", lockedUntil.Format(time.DateTime))
+	err = mail.SendEmail(toemail, title, text)
+	if err != nil {
+		log.Errorf("SendEshopAccountLockedToEmail 发送账号锁定邮件失败, to:%s, title:%s, error:%v", toemail, title, err)
+		return err
+	}
+	return nil
+}
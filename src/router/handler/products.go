@@ -5,6 +5,7 @@ import (
 	"errors"
 	"eshop_server/src/common/api"
 	"eshop_server/src/router/dao"
+	"eshop_server/src/router/middleware"
 	"eshop_server/src/router/model"
 	"eshop_server/src/utils/alarm"
 	"eshop_server/src/utils/common"
@@ -44,10 +45,30 @@ func GetProductList(c *gin.Context) {
 		return
 	}
 
+	// 批量获取试听信息, 按曲目顺序
+	productIds := make([]string, 0, len(resList))
+	for _, v := range resList {
+		productIds = append(productIds, v.Id)
+	}
+	playerList, err := dao.GetProductsPreviewTracklist(productIds, []int32{model.ProductsPlayerStatusOk})
+	if err != nil {
+		log.Errorf("GetProductList GetProductsPreviewTracklist fail, product_ids:%v, err:%v", productIds, err)
+	}
+	previewMap := make(map[string][]*model.ProductPreviewView)
+	for _, p := range playerList {
+		if len(previewMap[p.ProductId]) < model.ProductPreviewListMaxLength {
+			previewMap[p.ProductId] = append(previewMap[p.ProductId], p.PreviewViewFormat())
+		}
+	}
+
 	// 格式化返回结果
 	var resUserList []*model.ProductUserView
 	for _, v := range resList {
-		resUserList = append(resUserList, v.UserViewFormat())
+		view := v.UserViewFormat()
+		if previews, ok := previewMap[v.Id]; ok {
+			view.PreviewList = previews
+		}
+		resUserList = append(resUserList, view)
 	}
 	
 	// 返回数据
@@ -76,9 +97,7 @@ func AdminGetProductList(c *gin.Context) {
 		return
 	}
 
-	// 对于每个商品获取商品播放列表
-	_selectlimit := int32(10)
-	_orderby := "updated_at desc"
+	// 对于每个商品获取商品曲目列表
 	for _, v := range productList {
 		var tmp model.CreateProductReq = model.CreateProductReq{
 			Products: *v,
@@ -86,9 +105,9 @@ func AdminGetProductList(c *gin.Context) {
 		// 获取播放信息
 		tmp.PP = make([]model.ProductsPlayer, 0)
 		// playerList, err := dao.GetProductsPlayerByProductId(v.Id)
-		playerList, err := dao.GetSelectedProductsPlayerByProductId(v.Id, model.ProductsPlayerStatusOk, _orderby, _selectlimit)
+		playerList, err := dao.GetProductsPlayerTracklist(v.Id, []int32{model.ProductsPlayerStatusOk})
 		if err != nil {
-			log.Errorf("AdminGetProductList GetSelectedProductsPlayerByProductId fail, product_id:%s, err:%v", v.Id, err)
+			log.Errorf("AdminGetProductList GetProductsPlayerTracklist fail, product_id:%s, err:%v", v.Id, err)
 			api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
 			return
 		}
@@ -172,6 +191,8 @@ func AdminCreateProduct(c *gin.Context) {
 		}
 	}
 
+	middleware.SetAdminAudit(c, model.AdminAuditTargetProduct, res.Id, nil, map[string]interface{}{"product": res, "players": reqbody.PP})
+
 	// 飞书告警
 	if err = alarm.PostFeiShu(
 		"info", config.CommonConfig.LarkAlarm.InfoBotWebhook,
@@ -208,6 +229,7 @@ func AdminRemoveProduct(c *gin.Context) {
 	}
 
 	// 更新商品状态，将 status 从 1 更新为 0
+	before := *res
 	res.Status = model.ProductStatusOff
 	res, err = dao.UpdateProductsByField(res, []string{"status"})
 	if err != nil {
@@ -215,6 +237,7 @@ func AdminRemoveProduct(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail+":更新商品状态失败")
 		return
 	}
+	middleware.SetAdminAudit(c, model.AdminAuditTargetProduct, res.Id, &before, res)
 
 	// TODO 飞书通知下架
 	if err = alarm.PostFeiShu(
@@ -1,19 +1,28 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"eshop_server/src/common/api"
 	"eshop_server/src/router/dao"
+	"eshop_server/src/router/middleware"
 	"eshop_server/src/router/model"
 	"eshop_server/src/utils/common"
 	"eshop_server/src/utils/config"
 	uerrors "eshop_server/src/utils/errors"
 	"eshop_server/src/utils/log"
 	"eshop_server/src/utils/uuid"
+	"fmt"
 	"io"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -48,86 +57,382 @@ func UploadStreamingFile(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
 		return
 	}
-	fileType := strings.ToLower(filepath.Ext(file.Filename))
 	log.Infof("UploadStreamingFile 获取到文件, filename: %s, filesize: %vMB", file.Filename, file.Size/1024/1024)
 
-	// 创建数据库记录
-	player := &model.ProductsPlayer{
-		Id:        uuid.GetUuid(),
-		ProductId: product_id,
-		Filename:  strings.TrimSuffix(file.Filename, fileType), // 只保留文件名不包含后缀
-		FileType:  fileType,
-		Status:    model.ProductsPlayerStatusParsing,
-	}
-	_, err = dao.CreateProductsPlayer(player)
-	if err != nil || player == nil {
-		log.Errorf("UploadStreamingFile 创建ProductsPlayer记录失败, filename: %s , error: %s", file.Filename, err.Error())
+	// 打开上传的文件
+	src, err := file.Open()
+	if err != nil {
+		log.Errorf("UploadStreamingFile 打开上传文件失败, filename: %s, error: %s", file.Filename, err.Error())
 		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
 		return
 	}
+	defer src.Close()
 
-	// 修改 defer 函数，通过闭包捕获 err 变量地址
-	defer func() {
-		if err != nil {
-			// 更新ProductsPlayer记录为错误状态
-			player.Status = model.ProductsPlayerStatusError
-			if _, updateErr := dao.UpdateProductsPlayerByField(player, []string{"status"}); updateErr != nil {
-				log.Errorf("UploadStreamingFile 更新ProductsPlayer记录失败, player: %+v , error: %s", player, updateErr.Error())
-			}
-			api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
-			return
-		}
-	}()
+	// 通过stream服务分片上传接口上传文件, 由stream服务创建player记录及转码任务
+	autoFill, _ := strconv.ParseBool(c.PostForm("auto_fill"))
+	streamResult, err := RequestStreamChunkedUpload(product_id, file.Filename, file.Size, src, autoFill, "")
+	if err != nil {
+		log.Errorf("UploadStreamingFile 调用stream服务分片上传失败, filename: %s, error: %s", file.Filename, err.Error())
+		failStreamUpload(c, err)
+		return
+	}
+	log.Infof("UploadStreamingFile 上传文件成功, filename: %s, player_id: %s", file.Filename, streamResult.Result.Id)
+	middleware.SetAdminAudit(c, model.AdminAuditTargetPlayer, streamResult.Result.Id, nil, streamResult.Result)
 
-	// 调用stream服务上传文件获取player信息
-	request_url := config.StreamConfig.Host + "/v1/steaming/internal_upload_streaming_file"
-	streamPlayerInfo, err := RequestInternalUploadStreamingFile(request_url, c)
+	// 返回成功响应
+	dataMap["job"] = streamResult.Job
+	dataMap["result"] = streamResult.Result
+	api.Success(c, dataMap)
+}
+
+// @Title		 根据音频文件创建商品
+// @Description  上传音频文件并创建商品, 未填写标题时以文件名作为标题
+// @Description  auto_fill为true时, 转码完成后根据音频标签(ID3/RIFF INFO)及内嵌封面填充商品标题及图片
+// @Accept       multipart/form-data file, price, id, title, description, image_url, external_id, external_link, auto_fill
+// @Response     json
+// @Router       /v1/eshop_api/admin/product/create_from_file [post]
+func AdminCreateProductFromFile(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	// 参数判断和预处理
+	price, err := strconv.ParseFloat(c.PostForm("price"), 64)
+	if err != nil || price <= 0 {
+		log.Errorf("AdminCreateProductFromFile 商品价格无效, price: %s", c.PostForm("price"))
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":基本参数有误")
+		return
+	}
+	file, err := c.FormFile("file")
 	if err != nil {
-		log.Errorf("UploadStreamingFile 调用stream服务上传文件接口失败, error: %s", err.Error())
+		log.Errorf("AdminCreateProductFromFile 请求参数错误, file upload failed, error: %s", err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":缺失文件")
 		return
 	}
+	if !common.CheckFileTypes(file.Filename, model.ProductPlayerSupportFileTypeList) {
+		log.Errorf("AdminCreateProductFromFile 请求参数错误, 仅支持mp3或wav格式, filename: %s", file.Filename)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
+		return
+	}
+	autoFill, _ := strconv.ParseBool(c.PostForm("auto_fill"))
 
-	// 更新player信息
-	// player.PlayType = model.ProductsPlayerPlayTypeHls
-	// player.PlayUrl = config.StreamConfig.Host + player.Id + ".m3u8"
-	player.Duration = streamPlayerInfo.Duration
-	player.FileSize = streamPlayerInfo.FileSize
-	player.PlayType = streamPlayerInfo.PlayType
-	player.PlayUrl = streamPlayerInfo.PlayUrl
-	player.Status = model.ProductsPlayerStatusOk
-	product_player_update_fields := []string{"duration", "file_size", "play_type", "play_url", "status"}
-	if _, err = dao.UpdateProductsPlayerByField(player, product_player_update_fields); err != nil {
-		log.Errorf("UploadStreamingFile 更新ProductsPlayer记录失败, m: %+v , error: %s", player, err.Error())
+	product := &model.Products{
+		Id:           c.PostForm("id"),
+		Title:        c.PostForm("title"),
+		Description:  c.PostForm("description"),
+		Price:        price,
+		Status:       model.ProductStatusOn,
+		ImageUrl:     c.PostForm("image_url"),
+		ExternalId:   c.PostForm("external_id"),
+		ExternalLink: c.PostForm("external_link"),
+	}
+	if product.Id == "" {
+		product.Id = uuid.GetUuid()
+	}
+	if product.Title == "" {
+		product.Title = strings.TrimSuffix(filepath.Base(file.Filename), filepath.Ext(file.Filename)) // 文件名不包含后缀
+	}
+	if product.ImageUrl == "" {
+		product.ImageUrl = model.ProductImageUrlDefault
+	}
+
+	// 打开上传的文件
+	src, err := file.Open()
+	if err != nil {
+		log.Errorf("AdminCreateProductFromFile 打开上传文件失败, filename: %s, error: %s", file.Filename, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
 		return
 	}
+	defer src.Close()
 
-	// 返回成功响应
-	dataMap["result"] = player
+	// 创建上架商品
+	res, err := dao.CreateProduct(product)
+	if err != nil {
+		log.Errorf("AdminCreateProductFromFile 创建商品失败, product: %+v, err: %v", product, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+
+	// 上传音频文件, 失败时下架商品
+	streamResult, err := RequestStreamChunkedUpload(res.Id, file.Filename, file.Size, src, autoFill, "")
+	if err != nil {
+		log.Errorf("AdminCreateProductFromFile 调用stream服务分片上传失败, product_id: %s, filename: %s, error: %s", res.Id, file.Filename, err.Error())
+		res.Status = model.ProductStatusOff
+		if _, updateErr := dao.UpdateProductsByField(res, []string{"status"}); updateErr != nil {
+			log.Errorf("AdminCreateProductFromFile 下架商品失败, product_id: %s, error: %v", res.Id, updateErr)
+		}
+		failStreamUpload(c, err)
+		return
+	}
+	log.Infof("AdminCreateProductFromFile 创建商品成功, product_id: %s, player_id: %s, auto_fill: %v", res.Id, streamResult.Result.Id, autoFill)
+
+	middleware.SetAdminAudit(c, model.AdminAuditTargetProduct, res.Id, nil, map[string]interface{}{"product": res, "player": streamResult.Result})
+
+	dataMap["result"] = res
+	dataMap["job"] = streamResult.Job
+	dataMap["player"] = streamResult.Result
 	api.Success(c, dataMap)
 }
 
-// 转发请求，请求流媒体服务器上传文件获取文件meta信息
-func RequestInternalUploadStreamingFile(url string, c *gin.Context) (m model.ProductsPlayer, err error) {
-	log.Infof("RequestInternalUploadStreamingFile request_url: %s", url)
-	// 发送POST请求
-	resp, err := http.Post(url, "multipart/form-data", c.Request.Body)
-	if err != nil {
-		log.Errorf("RequestInternalUploadStreamingFile 发送POST请求失败, error: %s", err.Error())
+// stream服务接口响应
+type streamApiResponse struct {
+	Code int32           `json:"code"`
+	Data json.RawMessage `json:"data"`
+	Msg  string          `json:"msg"`
+}
+
+// stream服务返回的业务错误
+type streamApiError struct {
+	Code int32
+	Msg  string
+}
+
+func (e *streamApiError) Error() string {
+	return fmt.Sprintf("stream服务返回错误, code: %d, msg: %s", e.Code, e.Msg)
+}
+
+// 上传失败时响应, stream服务业务错误(如文件校验失败已隔离)透传错误码及原因
+func failStreamUpload(c *gin.Context, err error) {
+	var apiErr *streamApiError
+	if errors.As(err, &apiErr) {
+		api.Fail(c, apiErr.Code, apiErr.Msg)
 		return
 	}
+	api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+}
+
+// stream服务分片上传任务
+type streamUploadSession struct {
+	UploadId    string `json:"upload_id"`
+	PlayerId    string `json:"player_id"`
+	ChunkSize   int64  `json:"chunk_size"`
+	TotalChunks int64  `json:"total_chunks"`
+}
+
+// stream服务完成分片上传结果
+type streamUploadResult struct {
+	Job    json.RawMessage      `json:"job"`
+	Result model.ProductsPlayer `json:"result"`
+}
+
+const (
+	streamUploadChunkRetry    = 3               // 单个分片最大重试次数
+	streamUploadChunkInterval = 2 * time.Second // 分片重试间隔
+	streamApiDefaultTimeout   = 120             // 调用stream服务接口默认超时时长（秒）
+)
+
+var (
+	streamApiClient     *http.Client // 调用stream服务接口的http客户端, 首次使用时按配置创建
+	streamApiClientOnce sync.Once
+)
+
+// 获取调用stream服务接口的http客户端
+func getStreamApiClient() *http.Client {
+	streamApiClientOnce.Do(func() {
+		timeout := config.StreamConfig.ApiTimeout
+		if timeout <= 0 {
+			timeout = streamApiDefaultTimeout
+		}
+		streamApiClient = &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	})
+	return streamApiClient
+}
+
+// 请求stream服务接口, 解析data字段
+func requestStreamApi(method string, url string, contentType string, header map[string]string, body io.Reader, data interface{}) (err error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(middleware.InternalTokenHeader, config.StreamConfig.InternalSecret)
+	resp, err := getStreamApiClient().Do(req)
+	if err != nil {
+		return err
+	}
 	defer resp.Body.Close()
-	// 读取响应体
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var apiResp streamApiResponse
+	if err = json.Unmarshal(respBody, &apiResp); err != nil {
+		return fmt.Errorf("解析响应体失败: %v, status: %d", err, resp.StatusCode)
+	}
+	if apiResp.Code != 0 {
+		return &streamApiError{Code: apiResp.Code, Msg: apiResp.Msg}
+	}
+	// 中间件拒绝等非业务响应不含code字段
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求失败, status: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	if data != nil {
+		return json.Unmarshal(apiResp.Data, data)
+	}
+	return nil
+}
+
+// 通过stream服务分片上传协议上传文件: 初始化 -> 逐个上传分片(失败重试) -> 完成
+// autoFill为true时, 由stream服务在转码完成后根据元数据填充商品标题及图片
+// replacePlayerId不为空时, 转码完成后替换该播放记录, 旧文件移入回收站
+func RequestStreamChunkedUpload(productId string, filename string, fileSize int64, src io.ReaderAt, autoFill bool, replacePlayerId string) (res *streamUploadResult, err error) {
+	apiHost := strings.TrimSuffix(config.StreamConfig.ApiHost, "/")
+
+	// 初始化上传任务
+	initReq, _ := json.Marshal(map[string]interface{}{
+		"product_id":        productId,
+		"filename":          filename,
+		"file_size":         fileSize,
+		"auto_fill":         autoFill,
+		"replace_player_id": replacePlayerId,
+	})
+	var initRes struct {
+		Result streamUploadSession `json:"result"`
+	}
+	if err = requestStreamApi(http.MethodPost, apiHost+"/v1/steaming/upload/init", "application/json", nil, bytes.NewReader(initReq), &initRes); err != nil {
+		return nil, fmt.Errorf("初始化分片上传失败: %w", err)
+	}
+	session := initRes.Result
+	log.Infof("RequestStreamChunkedUpload 初始化分片上传成功, session: %+v", session)
+
+	// 上传分片
+	buf := make([]byte, session.ChunkSize)
+	for index := int64(0); index < session.TotalChunks; index++ {
+		n, readErr := src.ReadAt(buf, index*session.ChunkSize)
+		if readErr != nil && readErr != io.EOF {
+			return nil, fmt.Errorf("读取分片%d失败: %v", index, readErr)
+		}
+		chunk := buf[:n]
+		sum := sha256.Sum256(chunk)
+		header := map[string]string{"X-Chunk-Sha256": hex.EncodeToString(sum[:])}
+		chunkUrl := fmt.Sprintf("%s/v1/steaming/upload/%s/chunk/%d", apiHost, session.UploadId, index)
+		for attempt := 1; ; attempt++ {
+			err = requestStreamApi(http.MethodPut, chunkUrl, "application/octet-stream", header, bytes.NewReader(chunk), nil)
+			if err == nil {
+				break
+			}
+			if attempt >= streamUploadChunkRetry {
+				return nil, fmt.Errorf("上传分片%d失败: %w", index, err)
+			}
+			log.Errorf("RequestStreamChunkedUpload 上传分片失败, upload_id: %s, index: %d, attempt: %d, error: %v", session.UploadId, index, attempt, err)
+			time.Sleep(streamUploadChunkInterval)
+		}
+	}
+
+	// 完成上传
+	res = new(streamUploadResult)
+	if err = requestStreamApi(http.MethodPost, apiHost+"/v1/steaming/upload/"+session.UploadId+"/complete", "", nil, nil, res); err != nil {
+		return nil, fmt.Errorf("完成分片上传失败: %w", err)
+	}
+	return res, nil
+}
+
+// @Title		 替换流媒体文件
+// @Description  上传新的音频替换指定播放资源, 转码完成前旧音频保持可播放, 完成后上架新播放资源并下架旧播放资源
+// @Accept       multipart/form-data player_id, file, auto_fill
+// @Response     json
+// @Router       /v1/eshop_api/admin/player/replace_streaming_file [post]
+func AdminReplaceStreamingFile(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	// 请求参数校验
+	playerId := c.PostForm("player_id")
+	if playerId == "" {
+		log.Errorf("AdminReplaceStreamingFile 请求参数错误, player_id为空")
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":缺失必要参数")
+		return
+	}
+	file, err := c.FormFile("file")
 	if err != nil {
-		log.Errorf("RequestInternalUploadStreamingFile 读取响应体失败, error: %s", err.Error())
+		log.Errorf("AdminReplaceStreamingFile 请求参数错误, file upload failed, error: %s", err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":缺失文件")
 		return
 	}
-	// 解析响应体
-	if err = json.Unmarshal(body, &m); err != nil {
-		log.Errorf("RequestInternalUploadStreamingFile 解析响应体失败, error: %s", err.Error())
+	if !common.CheckFileTypes(file.Filename, model.ProductPlayerSupportFileTypeList) {
+		log.Errorf("AdminReplaceStreamingFile 请求参数错误, 仅支持mp3或wav格式, filename: %s", file.Filename)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
 		return
 	}
-	return
+
+	// 查询被替换的播放资源
+	player, err := dao.GetProductsPlayerById(playerId)
+	if err != nil {
+		log.Errorf("AdminReplaceStreamingFile 查询播放资源失败, player_id: %s, error: %v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail+":查询播放资源失败")
+		return
+	}
+	if player.Status == model.ProductsPlayerStatusInvalid {
+		log.Errorf("AdminReplaceStreamingFile 播放资源已下架, player_id: %s", playerId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":播放资源已下架")
+		return
+	}
+
+	// 打开上传的文件
+	src, err := file.Open()
+	if err != nil {
+		log.Errorf("AdminReplaceStreamingFile 打开上传文件失败, filename: %s, error: %s", file.Filename, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
+	defer src.Close()
+
+	// 上传新音频, 由stream服务在转码完成后替换播放资源
+	autoFill, _ := strconv.ParseBool(c.PostForm("auto_fill"))
+	streamResult, err := RequestStreamChunkedUpload(player.ProductId, file.Filename, file.Size, src, autoFill, player.Id)
+	if err != nil {
+		log.Errorf("AdminReplaceStreamingFile 调用stream服务分片上传失败, player_id: %s, filename: %s, error: %s", playerId, file.Filename, err.Error())
+		failStreamUpload(c, err)
+		return
+	}
+	log.Infof("AdminReplaceStreamingFile 上传替换文件成功, player_id: %s, new_player_id: %s", playerId, streamResult.Result.Id)
+	middleware.SetAdminAudit(c, model.AdminAuditTargetPlayer, player.Id, nil, map[string]interface{}{"replace_player": streamResult.Result, "filename": file.Filename})
+
+	dataMap["job"] = streamResult.Job
+	dataMap["result"] = streamResult.Result
+	dataMap["replace_player_id"] = player.Id
+	api.Success(c, dataMap)
+}
+
+// @Title		 下架流媒体文件
+// @Description  下架播放资源, 源文件及流媒体文件移入回收站, 超过保留天数后由定时任务删除
+// @Response     json
+// @Router       /v1/eshop_api/admin/player/retire/:id [put]
+func AdminRetireStreamingFile(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	playerId := c.Param("id")
+	if playerId == "" {
+		log.Errorf("AdminRetireStreamingFile 请求参数错误, player_id为空")
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":缺失必要参数")
+		return
+	}
+
+	apiHost := strings.TrimSuffix(config.StreamConfig.ApiHost, "/")
+	var res struct {
+		Result   model.ProductsPlayer `json:"result"`
+		Recycled int64                `json:"recycled"`
+	}
+	if err := requestStreamApi(http.MethodPost, apiHost+"/v1/steaming/retire/"+playerId, "", nil, nil, &res); err != nil {
+		log.Errorf("AdminRetireStreamingFile 调用stream服务下架失败, player_id: %s, error: %v", playerId, err)
+		var apiErr *streamApiError
+		if errors.As(err, &apiErr) {
+			api.Fail(c, apiErr.Code, apiErr.Msg)
+			return
+		}
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Code, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Detail)
+		return
+	}
+	log.Infof("AdminRetireStreamingFile 下架播放资源成功, player_id: %s, recycled: %d", playerId, res.Recycled)
+	middleware.SetAdminAudit(c, model.AdminAuditTargetPlayer, playerId, nil, map[string]interface{}{"status": res.Result.Status, "recycled": res.Recycled})
+
+	dataMap["result"] = res.Result
+	dataMap["recycled"] = res.Recycled
+	api.Success(c, dataMap)
 }
 
 // @Title		 更新流媒体文件
@@ -152,6 +457,10 @@ func UpdateStreamingFile(c *gin.Context) {
 	}
 
 	// 更新player记录
+	var before *model.ProductsPlayer
+	if reqbody.Id != "" {
+		before, _ = dao.GetProductsPlayerById(reqbody.Id)
+	}
 	_update_fields := []string{"product_id","filename","file_type","file_size","duration","play_type","play_url","status"}
 	player, err := dao.ReplaceProductsPlayerByProductId(reqbody, _update_fields)
 	if err != nil {
@@ -159,6 +468,7 @@ func UpdateStreamingFile(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail+":更新player记录失败")
 		return
 	}
+	middleware.SetAdminAudit(c, model.AdminAuditTargetPlayer, player.Id, before, player)
 
 	dataMap["result"] = player
 	api.Success(c, dataMap)
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"encoding/json"
+	"eshop_server/src/common/api"
+	"eshop_server/src/common/cache"
+	"eshop_server/src/router/dao"
+	"eshop_server/src/router/middleware"
+	"eshop_server/src/router/model"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 判断当前操作人能否授予或收回该角色, 需拥有该角色的全部权限, 防止越权提权
+func canManageRole(c *gin.Context, role string) (bool, error) {
+	owned := c.GetStringSlice("permissions")
+	rolePermissions, err := middleware.GetRolesPermissions([]string{role})
+	if err != nil {
+		return false, err
+	}
+	for _, permission := range rolePermissions {
+		if !model.HasPermission(owned, permission) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// @Title        获取角色列表
+// @Description  管理后台查看内置角色及运营角色的权限
+// @Produce      json
+// @Router       /v1/eshop_api/admin/role/list [get]
+func AdminGetRoleList(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	roleList, err := dao.GetRoleList()
+	if err != nil {
+		log.Errorf("AdminGetRoleList GetRoleList fail, err:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	permissionList, err := dao.GetRolePermissions(nil)
+	if err != nil {
+		log.Errorf("AdminGetRoleList GetRolePermissions fail, err:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	permissionMap := make(map[string][]string)
+	for _, item := range permissionList {
+		permissionMap[item.Role] = append(permissionMap[item.Role], item.Permission)
+	}
+
+	resList := []*model.RoleView{
+		{Name: model.UserRoleAdmin, Description: "管理员：拥有全部权限", Permissions: []string{model.PermissionAll}, Builtin: true},
+		{Name: model.UserRoleUser, Description: "普通用户：无后台权限", Permissions: []string{}, Builtin: true},
+	}
+	for _, role := range roleList {
+		permissions := permissionMap[role.Name]
+		if permissions == nil {
+			permissions = []string{}
+		}
+		resList = append(resList, &model.RoleView{Name: role.Name, Description: role.Description, Permissions: permissions})
+	}
+
+	dataMap["result"] = resList
+	dataMap["len"] = len(resList)
+	api.Success(c, dataMap)
+}
+
+// @Title        获取权限列表
+// @Description  管理后台编辑角色时可选的全部权限
+// @Produce      json
+// @Router       /v1/eshop_api/admin/role/permissions [get]
+func AdminGetPermissionList(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+	dataMap["result"] = model.PermissionList
+	dataMap["len"] = len(model.PermissionList)
+	api.Success(c, dataMap)
+}
+
+// @Title        创建或更新角色
+// @Description  管理后台编辑运营角色及其权限, 内置角色不可编辑, 只能授予操作人自身拥有的权限
+// @Produce      json
+// @Router       /v1/eshop_api/admin/role/save [post]
+func AdminSaveRole(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+	log.Infof("AdminSaveRole 请求参数, req:%s", string(req))
+
+	// JSON解析
+	var reqbody model.SaveRoleReq
+	if err = json.Unmarshal(req, &reqbody); err != nil {
+		log.Errorf("AdminSaveRole json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+
+	// 校验参数
+	reqbody.Name = strings.TrimSpace(reqbody.Name)
+	if !model.RoleNameRegexp.MatchString(reqbody.Name) || model.IsBuiltinRole(reqbody.Name) {
+		log.Errorf("AdminSaveRole 角色名称无效, name:%s", reqbody.Name)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":角色名称无效")
+		return
+	}
+	// 已存在的角色需操作人拥有其全部权限才可编辑
+	if ok, err := canManageRole(c, reqbody.Name); err != nil || !ok {
+		log.Errorf("AdminSaveRole 权限不足, 不可编辑角色, user_id:%s, name:%s, error:%v", c.GetString("userId"), reqbody.Name, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Code, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Detail)
+		return
+	}
+	owned := c.GetStringSlice("permissions")
+	permissions := make([]string, 0, len(reqbody.Permissions))
+	seen := make(map[string]bool)
+	for _, permission := range reqbody.Permissions {
+		if !model.IsPermission(permission) {
+			log.Errorf("AdminSaveRole 权限无效, permission:%s", permission)
+			api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":权限无效")
+			return
+		}
+		if !model.HasPermission(owned, permission) {
+			log.Errorf("AdminSaveRole 不可授予自身未拥有的权限, user_id:%s, permission:%s", c.GetString("userId"), permission)
+			api.Fail(c, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Code, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Detail)
+			return
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+
+	// 保存角色并清除权限缓存
+	oldPermissions, _ := middleware.GetRolesPermissions([]string{reqbody.Name})
+	role := &model.Role{Name: reqbody.Name, Description: reqbody.Description}
+	if err = dao.SaveRole(role, permissions); err != nil {
+		log.Errorf("AdminSaveRole 保存角色失败, name:%s, error:%v", reqbody.Name, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+	if err = cache.DelJxsRolePermissions(role.Name); err != nil {
+		log.Errorf("AdminSaveRole 清除角色权限缓存失败, name:%s, error:%v", role.Name, err)
+	}
+	log.Infof("AdminSaveRole 保存角色成功, user_id:%s, name:%s, permissions:%v", c.GetString("userId"), role.Name, permissions)
+	middleware.SetAdminAudit(c, model.AdminAuditTargetRole, role.Name,
+		map[string]interface{}{"permissions": oldPermissions},
+		map[string]interface{}{"description": role.Description, "permissions": permissions})
+
+	api.Success(c, dataMap)
+}
+
+// @Title        分配用户角色
+// @Description  管理后台覆盖用户的角色, 完成后注销该用户所有登录会话使新角色立即生效
+// @Produce      json
+// @Router       /v1/eshop_api/admin/role/assign [post]
+func AdminAssignUserRoles(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+	log.Infof("AdminAssignUserRoles 请求参数, req:%s", string(req))
+
+	// JSON解析
+	var reqbody model.AssignUserRolesReq
+	if err = json.Unmarshal(req, &reqbody); err != nil {
+		log.Errorf("AdminAssignUserRoles json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+	if reqbody.UserId == "" || len(reqbody.Roles) == 0 {
+		log.Errorf("AdminAssignUserRoles 参数无效, req:%+v", reqbody)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":用户ID或角色无效")
+		return
+	}
+	// HARDNEED 不可修改本人角色
+	if reqbody.UserId == c.GetString("userId") {
+		log.Errorf("AdminAssignUserRoles 不可修改本人角色, user_id:%s", reqbody.UserId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Code, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Detail)
+		return
+	}
+
+	// 校验用户是否存在
+	user, err := dao.GetUserById(reqbody.UserId)
+	if err != nil {
+		log.Errorf("AdminAssignUserRoles GetUserById fail, err:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail+":用户不存在")
+		return
+	}
+
+	// 校验角色是否存在
+	roles := make([]string, 0, len(reqbody.Roles))
+	customRoles := make([]string, 0, len(reqbody.Roles))
+	seen := make(map[string]bool)
+	for _, role := range reqbody.Roles {
+		if seen[role] {
+			continue
+		}
+		seen[role] = true
+		roles = append(roles, role)
+		if !model.IsBuiltinRole(role) {
+			customRoles = append(customRoles, role)
+		}
+	}
+	if len(customRoles) > 0 {
+		roleList, err := dao.GetRolesByNames(customRoles)
+		if err != nil {
+			log.Errorf("AdminAssignUserRoles GetRolesByNames fail, err:%v", err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+			return
+		}
+		if len(roleList) != len(customRoles) {
+			log.Errorf("AdminAssignUserRoles 角色不存在, roles:%v", customRoles)
+			api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":角色不存在")
+			return
+		}
+	}
+
+	// 新增及移除的角色均需操作人拥有该角色的全部权限
+	oldRoles := make(map[string]bool)
+	for _, role := range user.Roles {
+		oldRoles[role] = true
+	}
+	changed := make([]string, 0)
+	for _, role := range roles {
+		if !oldRoles[role] {
+			changed = append(changed, role)
+		}
+		delete(oldRoles, role)
+	}
+	for role := range oldRoles {
+		changed = append(changed, role)
+	}
+	for _, role := range changed {
+		ok, err := canManageRole(c, role)
+		if err != nil {
+			log.Errorf("AdminAssignUserRoles 获取角色权限失败, role:%s, error:%v", role, err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+			return
+		}
+		if !ok {
+			log.Errorf("AdminAssignUserRoles 权限不足, 不可变更角色, operator:%s, role:%s", c.GetString("userId"), role)
+			api.Fail(c, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Code, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Detail)
+			return
+		}
+	}
+
+	// 更新用户角色
+	oldUserRoles := user.Roles
+	user.Roles = roles
+	if _, err = dao.UpdateUserByField(user, []string{"roles"}); err != nil {
+		log.Errorf("AdminAssignUserRoles 更新用户角色失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+	// 访问凭证中携带角色, 注销所有会话使用户重新登录获取新角色
+	if err = cache.DelJxsUserSessions(user.Id); err != nil {
+		log.Errorf("AdminAssignUserRoles 注销用户会话失败, user_id:%s, error:%v", user.Id, err)
+	}
+	log.Infof("AdminAssignUserRoles 分配用户角色成功, operator:%s, user_id:%s, roles:%v", c.GetString("userId"), user.Id, roles)
+	middleware.SetAdminAudit(c, model.AdminAuditTargetUser, user.Id, map[string]interface{}{"roles": oldUserRoles}, map[string]interface{}{"roles": roles})
+
+	api.Success(c, dataMap)
+}
@@ -19,6 +19,9 @@ func InitRouter() {
 		health_api.GET("/ping", HealthPing)
 	}
 
+	// jwt公钥路由
+	router.GET("/.well-known/jwks.json", GetJwks)
+
 	// 设置路由组
 	api := router.Group("/v1/eshop_api")
 	{
@@ -42,9 +45,14 @@ func InitRouter() {
 			auth.GET("/logout", UserLogout)
 			auth.POST("/refresh_token", RefreshToken)
 			auth.POST("/verify_email", VerifyEmail)
+			auth.POST("/forgot_password", ForgotPassword)
+			auth.POST("/reset_password_by_code", ResetPasswordByCode)
 			
 			// 管理后台
 			auth.POST("/admin_login", AdminLogin)
+			auth.POST("/admin_totp/setup", AdminTotpSetup)
+			auth.POST("/admin_totp/confirm", AdminTotpConfirm)
+			auth.POST("/admin_totp/verify", AdminTotpVerify)
 		}
 
 		// 用户权限路由
@@ -57,6 +65,11 @@ func InitRouter() {
 			user.POST("/reset_password", ResetPassword)
 			user.GET("/purchase_history", GetUserPurchaseHistory)
 
+			// 登录会话
+			user.GET("/session/list", GetUserSessionList)
+			user.POST("/session/revoke", RevokeUserSession)
+			user.POST("/session/revoke_all", RevokeAllUserSessions)
+
 			// 购物车
 			user.GET("/cart/list", GetCartList)
 			user.POST("/cart/create", CreateCart)
@@ -71,41 +84,76 @@ func InitRouter() {
 
 			// 藏品
 			user.GET("/inventory/list", GetInventoryList)
+			user.GET("/inventory/player", GetInventoryPlayer)
+			user.GET("/inventory/download", GetInventoryDownload)
+
+			// 专辑
+			user.GET("/album", GetProductAlbum)
 		}
 
 		// 管理员权限路由
 		admin := api.Group("/admin")
-		admin.Use(middleware.ParseAuthorization(), middleware.RequireRole(model.UserRoleAdmin))
+		admin.Use(middleware.ParseAuthorization(), middleware.RequireMfa(), middleware.AdminAudit())
 		{
 			// 用户操作
 			user := admin.Group("/user")
 			{
-				user.GET("/list", AdminGetUserList)
-				user.PUT("/ban/:id", AdminBanUser)
+				user.GET("/list", middleware.RequirePermission(model.PermissionUserRead), AdminGetUserList)
+				user.PUT("/ban/:id", middleware.RequirePermission(model.PermissionUserWrite), AdminBanUser)
+				user.GET("/locked", middleware.RequirePermission(model.PermissionUserRead), AdminGetLockedUserList)
+				user.PUT("/unlock/:id", middleware.RequirePermission(model.PermissionUserWrite), AdminUnlockUser)
+				user.PUT("/totp_reset/:id", middleware.RequirePermission(model.PermissionUserWrite), AdminResetUserTotp)
 			}
 
 			// 商品操作
 			product := admin.Group("/product")
 			{
-				product.GET("/list", AdminGetProductList)
-				product.POST("/create", AdminCreateProduct)
-				product.PUT("/remove/:id", AdminRemoveProduct)
+				product.GET("/list", middleware.RequirePermission(model.PermissionProductRead), AdminGetProductList)
+				product.POST("/create", middleware.RequirePermission(model.PermissionProductWrite), AdminCreateProduct)
+				product.POST("/create_from_file", middleware.RequirePermission(model.PermissionProductWrite), AdminCreateProductFromFile)
+				product.PUT("/remove/:id", middleware.RequirePermission(model.PermissionProductWrite), AdminRemoveProduct)
 				// product.DELETE("/delete/:id", DeleteProduct)
-				product.GET("/search/external_id/:external_id", AdminSearchProductsByExternalId)
+				product.GET("/search/external_id/:external_id", middleware.RequirePermission(model.PermissionProductRead), AdminSearchProductsByExternalId)
 			}
 
 			// 商品资源操作
 			product_player := admin.Group("/player")
 			{
 				// product_player.GET("/list", AdminGetProductPlayerList)
-				product_player.POST("/upload_streaming_file", UploadStreamingFile)
-				product_player.POST("/update_streaming_file", UpdateStreamingFile)
+				product_player.POST("/upload_streaming_file", middleware.RequirePermission(model.PermissionPlayerWrite), UploadStreamingFile)
+				product_player.POST("/update_streaming_file", middleware.RequirePermission(model.PermissionPlayerWrite), UpdateStreamingFile)
+				product_player.POST("/replace_streaming_file", middleware.RequirePermission(model.PermissionPlayerWrite), AdminReplaceStreamingFile)
+				product_player.PUT("/retire/:id", middleware.RequirePermission(model.PermissionPlayerWrite), AdminRetireStreamingFile)
+				product_player.GET("/tracklist", middleware.RequirePermission(model.PermissionPlayerRead), AdminGetProductTracklist)
+				product_player.PUT("/tracklist/reorder", middleware.RequirePermission(model.PermissionPlayerWrite), AdminReorderProductTracklist)
+				product_player.PUT("/track/:id", middleware.RequirePermission(model.PermissionPlayerWrite), AdminUpdateProductTrack)
 			}
 
 			// 订单操作
 			order := admin.Group("/order")
 			{
-				order.GET("/list", AdminGetUserOrderList)
+				order.GET("/list", middleware.RequirePermission(model.PermissionOrderRead), AdminGetUserOrderList)
+			}
+
+			// 数据统计
+			analytics := admin.Group("/analytics")
+			{
+				analytics.GET("/playback", middleware.RequirePermission(model.PermissionAnalyticsRead), AdminGetPlaybackStats)
+			}
+
+			// 角色权限操作
+			role := admin.Group("/role")
+			{
+				role.GET("/list", middleware.RequirePermission(model.PermissionRoleRead), AdminGetRoleList)
+				role.GET("/permissions", middleware.RequirePermission(model.PermissionRoleRead), AdminGetPermissionList)
+				role.POST("/save", middleware.RequirePermission(model.PermissionRoleWrite), AdminSaveRole)
+				role.POST("/assign", middleware.RequirePermission(model.PermissionRoleWrite), AdminAssignUserRoles)
+			}
+
+			// 操作审计
+			audit := admin.Group("/audit")
+			{
+				audit.GET("/list", middleware.RequirePermission(model.PermissionAuditRead), AdminGetAuditLogList)
 			}
 		}
 	}
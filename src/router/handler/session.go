@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"eshop_server/src/common/api"
+	"eshop_server/src/common/cache"
+	"eshop_server/src/router/middleware"
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/config"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uuid"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	refreshTokenSecretLength = 32 // 刷新凭证随机串长度（Byte字节）
+)
+
+// 获取登录会话配置, 未配置的项使用默认值
+func getSessionConf() config.SessionConf {
+	conf := config.CommonConfig.Session
+	if conf.RefreshTokenTimeout <= 0 {
+		conf.RefreshTokenTimeout = model.UserSessionDefaultRefreshTokenTimeout
+	}
+	if conf.MaxSessions == 0 {
+		conf.MaxSessions = model.UserSessionDefaultMaxSessions
+	}
+	return conf
+}
+
+// 生成刷新凭证 {sessionId}.{随机串}, redis仅保存随机串的sha256
+func generateRefreshToken(sessionId string) (token string, hash string, err error) {
+	secret := make([]byte, refreshTokenSecretLength)
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return sessionId + "." + encoded, hashRefreshSecret(encoded), nil
+}
+
+// 解析刷新凭证
+func parseRefreshToken(token string) (sessionId string, hash string, ok bool) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], hashRefreshSecret(parts[1]), true
+}
+
+// 刷新凭证随机串哈希
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// 创建登录会话, 签发访问凭证及刷新凭证
+// @param mfa 是否已通过两步验证, 后台接口仅允许已通过两步验证的会话访问
+func createUserSession(c *gin.Context, user *model.User, mfa bool) (accessToken string, refreshToken string, err error) {
+	conf := getSessionConf()
+	sessionId := uuid.GetUuid()
+	refreshToken, refreshHash, err := generateRefreshToken(sessionId)
+	if err != nil {
+		return "", "", err
+	}
+	accessToken, err = middleware.GenerateToken(user.Id, user.Roles, sessionId)
+	if err != nil {
+		return "", "", err
+	}
+
+	device := []rune(c.Request.UserAgent())
+	if len(device) > model.UserSessionDeviceMaxLength {
+		device = device[:model.UserSessionDeviceMaxLength]
+	}
+	now := time.Now().Unix()
+	fields := map[string]interface{}{
+		"user_id":      user.Id,
+		"device":       string(device),
+		"ip":           c.ClientIP(),
+		"created_at":   now,
+		"last_seen":    now,
+		"refresh_hash": refreshHash,
+		"mfa":          mfa,
+	}
+	if err = cache.SaveJxsUserSession(user.Id, sessionId, fields, conf.RefreshTokenTimeout); err != nil {
+		return "", "", err
+	}
+
+	// 超出会话数上限时注销最早的会话
+	if conf.MaxSessions > 0 {
+		sessionIds, listErr := cache.GetJxsUserSessionIds(user.Id)
+		if listErr != nil {
+			log.Errorf("createUserSession 获取用户会话列表失败, user_id:%s, error:%v", user.Id, listErr)
+		}
+		for i := 0; i < len(sessionIds)-int(conf.MaxSessions); i++ {
+			if delErr := cache.DelJxsUserSession(user.Id, sessionIds[i]); delErr != nil {
+				log.Errorf("createUserSession 注销超出上限的会话失败, user_id:%s, session_id:%s, error:%v", user.Id, sessionIds[i], delErr)
+			}
+		}
+	}
+	return accessToken, refreshToken, nil
+}
+
+// @Title        获取登录会话列表
+// @Description  获取本人所有设备的登录会话
+// @Produce      json
+// @Router       /v1/eshop_api/user/session/list [get]
+func GetUserSessionList(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	// JWT用户查询&鉴权
+	user, err := isValidUser(c)
+	if err != nil {
+		log.Errorf("GetUserSessionList 非法用户请求, error:%v", err)
+		api.FailWithAuthorization(c)
+		return
+	}
+
+	sessionIds, err := cache.GetJxsUserSessionIds(user.Id)
+	if err != nil {
+		log.Errorf("GetUserSessionList 获取用户会话列表失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrRedis.Error()).Code, uerrors.Parse(uerrors.ErrRedis.Error()).Detail)
+		return
+	}
+	currentSessionId := c.GetString("sessionId")
+	resList := make([]*model.UserSessionView, 0, len(sessionIds))
+	for _, sessionId := range sessionIds {
+		fields, getErr := cache.GetJxsUserSession(sessionId)
+		if getErr != nil {
+			log.Errorf("GetUserSessionList 获取会话失败, session_id:%s, error:%v", sessionId, getErr)
+			continue
+		}
+		// 已过期的会话从列表中移除
+		if fields["user_id"] != user.Id {
+			cache.DelJxsUserSession(user.Id, sessionId)
+			continue
+		}
+		view := model.UserSessionViewFormat(sessionId, fields)
+		view.Current = sessionId == currentSessionId
+		resList = append(resList, view)
+	}
+
+	dataMap["result"] = resList
+	dataMap["len"] = len(resList)
+	api.Success(c, dataMap)
+}
+
+// @Title        注销登录会话
+// @Description  注销本人指定设备的登录会话, 该会话的访问凭证及刷新凭证立即失效
+// @Produce      json
+// @Router       /v1/eshop_api/user/session/revoke [post]
+func RevokeUserSession(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+
+	// JWT用户查询&鉴权
+	user, err := isValidUser(c)
+	if err != nil {
+		log.Errorf("RevokeUserSession 非法用户请求, error:%v", err)
+		api.FailWithAuthorization(c)
+		return
+	}
+
+	// JSON解析
+	var reqbody model.RevokeSessionReq
+	if err = json.Unmarshal(req, &reqbody); err != nil {
+		log.Errorf("RevokeUserSession json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+	log.Infof("RevokeUserSession 请求参数, user_id:%s, session_id:%s", user.Id, reqbody.SessionId)
+
+	// 校验会话属于本人
+	fields, err := cache.GetJxsUserSession(reqbody.SessionId)
+	if err != nil || reqbody.SessionId == "" || fields["user_id"] != user.Id {
+		log.Errorf("RevokeUserSession 会话不存在或不属于该用户, user_id:%s, session_id:%s, error:%v", user.Id, reqbody.SessionId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":会话不存在")
+		return
+	}
+	if err = cache.DelJxsUserSession(user.Id, reqbody.SessionId); err != nil {
+		log.Errorf("RevokeUserSession 注销会话失败, user_id:%s, session_id:%s, error:%v", user.Id, reqbody.SessionId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrRedis.Error()).Code, uerrors.Parse(uerrors.ErrRedis.Error()).Detail)
+		return
+	}
+
+	api.Success(c, dataMap)
+}
+
+// @Title        注销所有登录会话
+// @Description  注销本人所有设备的登录会话, 可选保留当前会话
+// @Produce      json
+// @Router       /v1/eshop_api/user/session/revoke_all [post]
+func RevokeAllUserSessions(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+
+	// JWT用户查询&鉴权
+	user, err := isValidUser(c)
+	if err != nil {
+		log.Errorf("RevokeAllUserSessions 非法用户请求, error:%v", err)
+		api.FailWithAuthorization(c)
+		return
+	}
+
+	// JSON解析, 请求体为空时注销所有会话
+	var reqbody model.RevokeAllSessionsReq
+	if len(req) > 0 {
+		if err = json.Unmarshal(req, &reqbody); err != nil {
+			log.Errorf("RevokeAllUserSessions json解析失败, error:%v", err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+			return
+		}
+	}
+	log.Infof("RevokeAllUserSessions 请求参数, user_id:%s, reqbody:%+v", user.Id, reqbody)
+
+	if reqbody.ExcludeCurrent {
+		err = revokeOtherUserSessions(user.Id, c.GetString("sessionId"))
+	} else {
+		err = cache.DelJxsUserSessions(user.Id)
+	}
+	if err != nil {
+		log.Errorf("RevokeAllUserSessions 注销会话失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrRedis.Error()).Code, uerrors.Parse(uerrors.ErrRedis.Error()).Detail)
+		return
+	}
+
+	api.Success(c, dataMap)
+}
+
+// 注销用户除指定会话外的所有登录会话
+func revokeOtherUserSessions(userId string, keepSessionId string) error {
+	sessionIds, err := cache.GetJxsUserSessionIds(userId)
+	if err != nil {
+		return err
+	}
+	for _, sessionId := range sessionIds {
+		if sessionId == keepSessionId {
+			continue
+		}
+		if err = cache.DelJxsUserSession(userId, sessionId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"eshop_server/src/common/api"
+	"eshop_server/src/common/cache"
+	"eshop_server/src/router/dao"
+	"eshop_server/src/router/middleware"
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/config"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/qrcode"
+	"eshop_server/src/utils/totp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mfaTokenSecretLength = 32                                // 两步验证凭证随机串长度（Byte字节）
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789" // 恢复码字符集, 去除易混淆字符
+)
+
+// 验证器应用显示的发行方
+func getTotpIssuer() string {
+	if config.CommonConfig.AppName != "" {
+		return config.CommonConfig.AppName
+	}
+	return model.TotpDefaultIssuer
+}
+
+// 签发后台两步验证凭证, redis仅保存凭证的sha256
+func createAdminMfaChallenge(user *model.User) (token string, err error) {
+	secret := make([]byte, mfaTokenSecretLength)
+	if _, err = rand.Read(secret); err != nil {
+		return "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(secret)
+	if err = cache.SaveJxsMfaChallenge(hashRefreshSecret(token), user.Id); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// 生成恢复码, 返回明文及对应哈希, 明文仅返回给用户一次
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	buf := make([]byte, model.TotpRecoveryCodeLength)
+	for i := 0; i < model.TotpRecoveryCodeCount; i++ {
+		if _, err = rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := make([]byte, len(buf))
+		for j, b := range buf {
+			code[j] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+		}
+		half := len(code) / 2
+		codes = append(codes, string(code[:half])+"-"+string(code[half:]))
+		hashes = append(hashes, hashRecoveryCode(string(code)))
+	}
+	return codes, hashes, nil
+}
+
+// 恢复码哈希, 忽略分隔符、空格及大小写
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// 校验两步验证凭证并获取用户, 校验失败时直接返回错误响应
+// @return secret 绑定中的待确认TOTP密钥
+func takeAdminMfaChallenge(c *gin.Context, caller string, mfaToken string) (user *model.User, tokenHash string, secret string, ok bool) {
+	if isLoginIpLimited(c.ClientIP()) {
+		log.Errorf("%s ip登录失败次数过多, 临时限制登录, ip:%s", caller, c.ClientIP())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorLoginIpLimited.Error()).Code, uerrors.Parse(uerrors.ErrorLoginIpLimited.Error()).Detail)
+		return nil, "", "", false
+	}
+	if mfaToken == "" {
+		log.Errorf("%s 两步验证凭证为空", caller)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorMfaTokenInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorMfaTokenInvalid.Error()).Detail)
+		return nil, "", "", false
+	}
+	tokenHash = hashRefreshSecret(mfaToken)
+	userId, secret, ok := cache.TakeJxsMfaChallenge(tokenHash)
+	if !ok {
+		log.Errorf("%s 两步验证凭证无效或已过期", caller)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorMfaTokenInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorMfaTokenInvalid.Error()).Detail)
+		return nil, "", "", false
+	}
+
+	// 凭证有效期内用户可能已被禁用或锁定
+	user, err := dao.GetUserById(userId)
+	if err != nil {
+		log.Errorf("%s 查询用户失败, user_id:%s, error:%v", caller, userId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorUserNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorUserNotFound.Error()).Detail)
+		return nil, "", "", false
+	}
+	if user.Status == model.UserStatusBanned {
+		log.Errorf("%s 用户已被禁用, user_id:%s", caller, user.Id)
+		cache.DelJxsMfaChallenge(tokenHash)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorUserBanned.Error()).Code, uerrors.Parse(uerrors.ErrorUserBanned.Error()).Detail)
+		return nil, "", "", false
+	}
+	if user.IsLocked() {
+		log.Errorf("%s 用户已被临时锁定, user_id:%s", caller, user.Id)
+		cache.DelJxsMfaChallenge(tokenHash)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorUserLocked.Error()).Code, uerrors.Parse(uerrors.ErrorUserLocked.Error()).Detail)
+		return nil, "", "", false
+	}
+	return user, tokenHash, secret, true
+}
+
+// 校验TOTP验证码, 同一验证码只能使用一次
+func verifyTotpCode(user *model.User, secret string, code string) bool {
+	step, ok := totp.Validate(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return false
+	}
+	ok, err := cache.LockJxsTotpUsedStep(user.Id, step)
+	if err != nil {
+		log.Errorf("verifyTotpCode 记录已使用验证码失败, user_id:%s, error:%v", user.Id, err)
+		return false
+	}
+	return ok
+}
+
+// 完成后台登录, 签发访问凭证及刷新凭证
+func finishAdminLogin(c *gin.Context, caller string, user *model.User, tokenHash string, dataMap map[string]interface{}) bool {
+	cache.DelJxsMfaChallenge(tokenHash)
+	clearLoginFail(user)
+
+	// 两步验证期间角色可能已变更, 重新验证后台权限
+	permissions, err := middleware.GetRolesPermissions(user.Roles)
+	if err != nil {
+		log.Errorf("%s 获取角色权限失败, user_id:%s, error:%v", caller, user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return false
+	}
+	if len(permissions) == 0 {
+		log.Errorf("%s 用户权限不足, user_id:%s, roles:%v", caller, user.Id, user.Roles)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorShopUserUnAuthorization.Error()).Code, uerrors.Parse(uerrors.ErrorShopUserUnAuthorization.Error()).Detail)
+		return false
+	}
+
+	// 创建登录会话, 签发访问凭证及刷新凭证
+	tokenString, refreshToken, err := createUserSession(c, user, true)
+	if err != nil {
+		log.Errorf("%s 创建登录会话失败, user_id:%s, error:%v", caller, user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrRedis.Error()).Code, uerrors.Parse(uerrors.ErrRedis.Error()).Detail)
+		return false
+	}
+	middleware.LogAuthInfof(c, "%s generate new admin session, userId:%s, roles:%v, token:%s", caller, user.Id, user.Roles, tokenString)
+
+	// 更新用户最后登录时间
+	user.LastLogin = time.Now()
+	dao.UpdateUserByField(user, []string{"last_login"})
+
+	dataMap["token_type"] = middleware.TokenType
+	dataMap["access_token"] = tokenString
+	dataMap["refresh_token"] = refreshToken
+	dataMap["roles"] = user.Roles
+	dataMap["permissions"] = permissions
+	return true
+}
+
+// @Title        后台绑定两步验证
+// @Description  未绑定验证器的后台账号登录后, 凭两步验证凭证生成TOTP密钥及绑定二维码
+// @Param        json
+// @Produce      json
+// @Router       /v1/eshop_api/auth/admin_totp/setup [post]
+func AdminTotpSetup(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+
+	// JSON解析
+	var reqbody model.AdminTotpSetupReq
+	err = json.Unmarshal(req, &reqbody)
+	if err != nil {
+		log.Errorf("AdminTotpSetup json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+
+	user, tokenHash, _, ok := takeAdminMfaChallenge(c, "AdminTotpSetup", reqbody.MfaToken)
+	if !ok {
+		return
+	}
+	if user.IsTotpEnabled() {
+		log.Errorf("AdminTotpSetup 用户已绑定两步验证, user_id:%s", user.Id)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorTotpAlreadyEnabled.Error()).Code, uerrors.Parse(uerrors.ErrorTotpAlreadyEnabled.Error()).Detail)
+		return
+	}
+
+	// 生成待确认密钥, 确认验证码后才写入数据库
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Errorf("AdminTotpSetup 生成密钥失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+	if err = cache.SetJxsMfaChallengeSecret(tokenHash, secret); err != nil {
+		log.Errorf("AdminTotpSetup 保存待确认密钥失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrRedis.Error()).Code, uerrors.Parse(uerrors.ErrRedis.Error()).Detail)
+		return
+	}
+	uri := totp.KeyUri(getTotpIssuer(), user.Email, secret)
+	qrCode, err := qrcode.EncodeToBase64Png(uri, model.TotpQrCodeSize)
+	if err != nil {
+		log.Errorf("AdminTotpSetup 生成绑定二维码失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+	log.Infof("AdminTotpSetup 生成待确认密钥, user_id:%s", user.Id)
+
+	dataMap["secret"] = secret
+	dataMap["otpauth_uri"] = uri
+	dataMap["qrcode"] = qrCode
+	api.Success(c, dataMap)
+}
+
+// @Title        后台确认绑定两步验证
+// @Description  校验验证器生成的验证码后启用两步验证, 返回恢复码（仅返回一次）并完成登录
+// @Param        json
+// @Produce      json
+// @Router       /v1/eshop_api/auth/admin_totp/confirm [post]
+func AdminTotpConfirm(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+
+	// JSON解析
+	var reqbody model.AdminTotpVerifyReq
+	err = json.Unmarshal(req, &reqbody)
+	if err != nil {
+		log.Errorf("AdminTotpConfirm json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+
+	user, tokenHash, secret, ok := takeAdminMfaChallenge(c, "AdminTotpConfirm", reqbody.MfaToken)
+	if !ok {
+		return
+	}
+	if user.IsTotpEnabled() {
+		log.Errorf("AdminTotpConfirm 用户已绑定两步验证, user_id:%s", user.Id)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorTotpAlreadyEnabled.Error()).Code, uerrors.Parse(uerrors.ErrorTotpAlreadyEnabled.Error()).Detail)
+		return
+	}
+	if secret == "" {
+		log.Errorf("AdminTotpConfirm 未生成待确认密钥, user_id:%s", user.Id)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorTotpNotEnabled.Error()).Code, uerrors.Parse(uerrors.ErrorTotpNotEnabled.Error()).Detail)
+		return
+	}
+	if !verifyTotpCode(user, secret, reqbody.Code) {
+		log.Errorf("AdminTotpConfirm 验证码不正确, user_id:%s", user.Id)
+		recordLoginFail(c.ClientIP(), user.Email, user)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorTotpCodeInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorTotpCodeInvalid.Error()).Detail)
+		return
+	}
+
+	// 保存密钥及恢复码哈希
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		log.Errorf("AdminTotpConfirm 生成恢复码失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+	if err = dao.EnableUserTotp(user.Id, secret, hashes); err != nil {
+		log.Errorf("AdminTotpConfirm 启用两步验证失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+	log.Infof("AdminTotpConfirm 启用两步验证, user_id:%s", user.Id)
+
+	if !finishAdminLogin(c, "AdminTotpConfirm", user, tokenHash, dataMap) {
+		return
+	}
+	dataMap["recovery_codes"] = codes
+	api.Success(c, dataMap)
+}
+
+// @Title        后台两步验证登录
+// @Description  校验验证器生成的验证码或恢复码后完成登录, 恢复码使用后失效
+// @Param        json
+// @Produce      json
+// @Router       /v1/eshop_api/auth/admin_totp/verify [post]
+func AdminTotpVerify(c *gin.Context) {
+	var err error
+	req := api.GetGinBody(c)
+	dataMap := make(map[string]interface{})
+
+	// JSON解析
+	var reqbody model.AdminTotpVerifyReq
+	err = json.Unmarshal(req, &reqbody)
+	if err != nil {
+		log.Errorf("AdminTotpVerify json解析失败, error:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Code, uerrors.Parse(uerrors.ErrJsonUnmarshal.Error()).Detail)
+		return
+	}
+
+	user, tokenHash, _, ok := takeAdminMfaChallenge(c, "AdminTotpVerify", reqbody.MfaToken)
+	if !ok {
+		return
+	}
+	if !user.IsTotpEnabled() {
+		log.Errorf("AdminTotpVerify 用户未绑定两步验证, user_id:%s", user.Id)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorTotpNotEnabled.Error()).Code, uerrors.Parse(uerrors.ErrorTotpNotEnabled.Error()).Detail)
+		return
+	}
+
+	// 校验验证码或恢复码
+	useRecoveryCode := reqbody.Code == "" && reqbody.RecoveryCode != ""
+	if useRecoveryCode {
+		ok, err = dao.UseUserRecoveryCode(user.Id, hashRecoveryCode(reqbody.RecoveryCode))
+		if err != nil {
+			log.Errorf("AdminTotpVerify 使用恢复码失败, user_id:%s, error:%v", user.Id, err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+			return
+		}
+	} else {
+		ok = verifyTotpCode(user, user.TotpSecret, reqbody.Code)
+	}
+	if !ok {
+		log.Errorf("AdminTotpVerify 验证码或恢复码不正确, user_id:%s, recovery_code:%v", user.Id, useRecoveryCode)
+		recordLoginFail(c.ClientIP(), user.Email, user)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorTotpCodeInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorTotpCodeInvalid.Error()).Detail)
+		return
+	}
+
+	if !finishAdminLogin(c, "AdminTotpVerify", user, tokenHash, dataMap) {
+		return
+	}
+	if useRecoveryCode {
+		log.Warnf("AdminTotpVerify 使用恢复码登录, user_id:%s", user.Id)
+		remaining, _ := dao.CountUserRecoveryCodes(user.Id)
+		dataMap["recovery_codes_remaining"] = remaining
+	}
+	api.Success(c, dataMap)
+}
+
+// @Title        重置用户两步验证
+// @Description  管理后台为丢失验证器的用户清除TOTP密钥及恢复码, 并注销其全部登录会话, 用户下次登录时重新绑定
+// @Produce      json
+// @Router       /v1/eshop_api/admin/user/totp_reset/:id [put]
+func AdminResetUserTotp(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	// 获取用户ID
+	userId := c.Param("id")
+	if userId == "" {
+		log.Errorf("AdminResetUserTotp 用户ID不能为空")
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":用户ID无效")
+		return
+	}
+	log.Infof("AdminResetUserTotp 请求参数, user_id:%s", userId)
+
+	// 不可重置自己的两步验证, 避免凭窃取的会话绕过两步验证
+	if userId == c.GetString("userId") {
+		log.Errorf("AdminResetUserTotp 不可重置自己的两步验证, user_id:%s", userId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Code, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Detail)
+		return
+	}
+
+	// 校验用户是否存在
+	user, err := dao.GetUserById(userId)
+	if err != nil {
+		log.Errorf("AdminResetUserTotp GetUserById fail, err:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail+":用户不存在")
+		return
+	}
+	if !user.IsTotpEnabled() {
+		log.Errorf("AdminResetUserTotp 用户未绑定两步验证, user_id:%s", userId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorTotpNotEnabled.Error()).Code, uerrors.Parse(uerrors.ErrorTotpNotEnabled.Error()).Detail)
+		return
+	}
+
+	// 仅可重置权限不高于自己的用户
+	for _, role := range user.Roles {
+		allowed, err := canManageRole(c, role)
+		if err != nil {
+			log.Errorf("AdminResetUserTotp 获取角色权限失败, role:%s, error:%v", role, err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+			return
+		}
+		if !allowed {
+			log.Errorf("AdminResetUserTotp 权限不足, operator:%s, user_id:%s, role:%s", c.GetString("userId"), userId, role)
+			api.Fail(c, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Code, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Detail)
+			return
+		}
+	}
+
+	if err = dao.ResetUserTotp(user.Id); err != nil {
+		log.Errorf("AdminResetUserTotp 重置两步验证失败, user_id:%s, error:%v", userId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+	if err = cache.DelJxsUserSessions(user.Id); err != nil {
+		log.Errorf("AdminResetUserTotp 注销用户会话失败, user_id:%s, error:%v", userId, err)
+	}
+	middleware.SetAdminAudit(c, model.AdminAuditTargetUser, user.Id,
+		map[string]interface{}{"totp_enabled_at": user.TotpEnabledAt},
+		map[string]interface{}{"totp_enabled_at": nil})
+
+	api.Success(c, dataMap)
+}
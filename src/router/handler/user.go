@@ -3,10 +3,13 @@ package handler
 import (
 	"encoding/json"
 	"eshop_server/src/common/api"
+	"eshop_server/src/common/cache"
 	"eshop_server/src/router/dao"
+	"eshop_server/src/router/middleware"
 	"eshop_server/src/router/model"
 	uerrors "eshop_server/src/utils/errors"
 	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/password"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -106,20 +109,35 @@ func ResetPassword(c *gin.Context) {
 	}
 
 	// 密码校验
-	if reqbody.OldPassword != user.Password {
-		log.Errorf("ResetPassword 旧密码输入有误, user.Password:%v, req.Password:%v", user.Password, reqbody.OldPassword)
+	if !verifyUserPassword(user, reqbody.OldPassword) {
+		log.Errorf("ResetPassword 旧密码输入有误, user_id:%s", user.Id)
 		api.Fail(c, uerrors.Parse(uerrors.ErrorPasswordNotSame.Error()).Code, uerrors.Parse(uerrors.ErrorPasswordNotSame.Error()).Detail)
 		return
 	}
+	if !isValidPassword(reqbody.NewPassword) {
+		log.Errorf("ResetPassword 新密码格式无效, user_id:%s", user.Id)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorPasswordInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorPasswordInvalid.Error()).Detail)
+		return
+	}
 
 	// 更新旧密码
-	user.Password = reqbody.NewPassword
+	user.Password, err = password.Hash(reqbody.NewPassword)
+	if err != nil {
+		log.Errorf("ResetPassword 生成密码哈希失败, user_id:%s, error:%v", user.Id, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
 	if _, err = dao.UpdateUserByField(user, []string{"password"}); err != nil {
 		log.Errorf("ResetPassword 更新用户密码失败, error:%v", err)
 		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
 		return
 	}
 
+	// 注销其他设备的登录会话
+	if err = revokeOtherUserSessions(user.Id, c.GetString("sessionId")); err != nil {
+		log.Errorf("ResetPassword 注销其他登录会话失败, user_id:%s, error:%v", user.Id, err)
+	}
+
 	api.Success(c, dataMap)
 }
 
@@ -190,7 +208,23 @@ func AdminBanUser(c *gin.Context) {
 		}
 	}
 
+	// 仅可操作权限不高于自己的用户
+	for _, role := range user.Roles {
+		allowed, err := canManageRole(c, role)
+		if err != nil {
+			log.Errorf("AdminBanUser 获取角色权限失败, role:%s, error:%v", role, err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+			return
+		}
+		if !allowed {
+			log.Errorf("AdminBanUser 权限不足, operator:%s, user_id:%s, role:%s", c.GetString("userId"), userId, role)
+			api.Fail(c, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Code, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Detail)
+			return
+		}
+	}
+
 	// 拉黑用户
+	before := *user
 	user.Status = model.UserStatusBanned
 	user.BannedAt = time.Now()
 	if _, err = dao.UpdateUserByField(user, []string{"status", "banned_at"}); err != nil {
@@ -198,6 +232,88 @@ func AdminBanUser(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
 		return
 	}
+	// 注销用户所有登录会话, 已签发的访问凭证及刷新凭证立即失效
+	if err = cache.DelJxsUserSessions(user.Id); err != nil {
+		log.Errorf("AdminBanUser 注销用户登录会话失败, user_id:%s, error:%v", userId, err)
+	}
+	middleware.SetAdminAudit(c, model.AdminAuditTargetUser, user.Id, &before, user)
+
+	api.Success(c, dataMap)
+}
+
+// @Title 获取临时锁定用户列表
+// @Description 管理后台查看因登录失败次数过多被临时锁定的用户
+// @Produce json
+// @Router /v1/eshop_api/admin/user/locked [get]
+func AdminGetLockedUserList(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	userList, err := dao.GetLockedUsers()
+	if err != nil {
+		log.Errorf("AdminGetLockedUserList GetLockedUsers fail, err:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return
+	}
+	resList := make([]*model.LockedUserView, 0, len(userList))
+	for _, user := range userList {
+		resList = append(resList, user.LockedViewFormat())
+	}
+
+	dataMap["result"] = resList
+	dataMap["len"] = len(resList)
+	api.Success(c, dataMap)
+}
+
+// @Title 解除用户临时锁定
+// @Description 管理后台提前解除用户因登录失败次数过多的临时锁定
+// @Produce json
+// @Router /v1/eshop_api/admin/user/unlock/:id [put]
+func AdminUnlockUser(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	// 获取用户ID
+	userId := c.Param("id")
+	if userId == "" {
+		log.Errorf("AdminUnlockUser 用户ID不能为空")
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":用户ID无效")
+		return
+	}
+	log.Infof("AdminUnlockUser 请求参数, user_id:%s", userId)
+
+	// 校验用户是否存在
+	user, err := dao.GetUserById(userId)
+	if err != nil {
+		log.Errorf("AdminUnlockUser GetUserById fail, err:%v", err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail+":用户不存在")
+		return
+	}
+
+	// 仅可操作权限不高于自己的用户
+	for _, role := range user.Roles {
+		allowed, err := canManageRole(c, role)
+		if err != nil {
+			log.Errorf("AdminUnlockUser 获取角色权限失败, role:%s, error:%v", role, err)
+			api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+			return
+		}
+		if !allowed {
+			log.Errorf("AdminUnlockUser 权限不足, operator:%s, user_id:%s, role:%s", c.GetString("userId"), userId, role)
+			api.Fail(c, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Code, uerrors.Parse(uerrors.ErrorPermissionDenied.Error()).Detail)
+			return
+		}
+	}
+
+	// 解除锁定并清除失败计数
+	if err = dao.UnlockUser(user.Id); err != nil {
+		log.Errorf("AdminUnlockUser 解除用户锁定失败, user_id:%s, error:%v", userId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+	cache.DelJxsLoginFailAccount(getLoginFailAccount(user.Email, user))
+	middleware.SetAdminAudit(c, model.AdminAuditTargetUser, user.Id,
+		map[string]interface{}{"login_attempts": user.LoginAttempts, "locked_until": user.LockedUntil},
+		map[string]interface{}{"login_attempts": 0, "locked_until": nil})
 
 	api.Success(c, dataMap)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"eshop_server/src/router/handler"
+	"eshop_server/src/router/middleware"
 	"eshop_server/src/utils/config"
 	"eshop_server/src/utils/db"
 	"eshop_server/src/utils/uredis"
@@ -31,6 +32,12 @@ func main() {
 	uredis.InitRedis(config.DbConfig.Redis.Host, config.DbConfig.Redis.Password, config.DbConfig.Redis.Db)
 	log.Info("初始化Redis缓存成功")
 
+	// 初始化jwt密钥
+	if err = middleware.InitJwtKeys(); err != nil {
+		log.Errorf("初始化jwt密钥失败: %v", err)
+		return
+	}
+
 	// 初始化路由
 	handler.InitRouter()
 
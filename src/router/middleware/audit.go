@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"eshop_server/src/common/api"
+	"eshop_server/src/router/dao"
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/uuid"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	adminAuditContextKey = "adminAudit" // 处理函数设置的审计目标
+)
+
+// 审计目标及变更前后数据
+type adminAuditTarget struct {
+	targetType string
+	targetId   string
+	before     interface{}
+	after      interface{}
+}
+
+// SetAdminAudit 设置本次后台操作的目标实体及变更前后数据, 由处理函数在修改成功后调用
+// before为nil表示新建, after为nil表示删除, 审计日志仅记录两者不同的字段
+func SetAdminAudit(c *gin.Context, targetType string, targetId string, before interface{}, after interface{}) {
+	c.Set(adminAuditContextKey, &adminAuditTarget{targetType: targetType, targetId: targetId, before: before, after: after})
+}
+
+// 管理后台审计中间件, 为请求分配请求id并在写操作完成后写入审计日志
+// 处理函数未调用SetAdminAudit时, 以路由参数id作为目标并记录JSON请求体
+func AdminAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(model.AdminAuditRequestIdHeader)
+		if requestId == "" || len(requestId) > model.AdminAuditRequestIdMaxLength {
+			requestId = uuid.GetUuid()
+		}
+		c.Set("requestId", requestId)
+		c.Header(model.AdminAuditRequestIdHeader, requestId)
+
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		// 读取JSON请求体后放回, 供处理函数继续读取
+		var body []byte
+		if c.ContentType() == gin.MIMEJSON {
+			var err error
+			if body, err = c.GetRawData(); err != nil {
+				LogAuthErrorf(c, "AdminAudit 读取请求体失败, err:%v", err)
+			}
+			c.Set(gin.BodyBytesKey, body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		c.Next()
+
+		record := &model.AdminAuditLog{
+			RequestId:  requestId,
+			ActorId:    c.GetString("userId"),
+			ActorRoles: c.GetStringSlice("roles"),
+			Action:     c.Request.Method + " " + c.FullPath(),
+			Ip:         c.ClientIP(),
+			HttpStatus: int32(c.Writer.Status()),
+			ResultCode: -1,
+		}
+		if code, ok := c.Get(api.KeyEventAttrApiErrorCode); ok {
+			record.ResultCode, _ = code.(int32)
+		}
+		if value, ok := c.Get(adminAuditContextKey); ok {
+			target := value.(*adminAuditTarget)
+			record.TargetType, record.TargetId = target.targetType, target.targetId
+			record.BeforeData, record.AfterData = diffAdminAuditData(target.before, target.after)
+		} else {
+			record.TargetId = c.Param("id")
+			if len(body) > 0 && len(body) <= model.AdminAuditMaxBodySize {
+				record.AfterData = marshalAdminAuditData(toAdminAuditMap(json.RawMessage(body)))
+			}
+		}
+		if err := dao.CreateAdminAuditLog(record); err != nil {
+			LogAuthErrorf(c, "AdminAudit 写入审计日志失败, request_id:%s, err:%v", requestId, err)
+		}
+	}
+}
+
+// 计算变更前后数据, 仅保留不同的字段
+func diffAdminAuditData(before interface{}, after interface{}) (string, string) {
+	beforeMap, afterMap := toAdminAuditMap(before), toAdminAuditMap(after)
+	if beforeMap != nil && afterMap != nil {
+		for k, v := range beforeMap {
+			if av, ok := afterMap[k]; ok && reflect.DeepEqual(v, av) {
+				delete(beforeMap, k)
+				delete(afterMap, k)
+			}
+		}
+	}
+	return marshalAdminAuditData(beforeMap), marshalAdminAuditData(afterMap)
+}
+
+// 转换为JSON对象并脱敏, 非对象数据以value字段保存
+func toAdminAuditMap(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return nil
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		var value interface{}
+		if err = json.Unmarshal(b, &value); err != nil {
+			log.Errorf("toAdminAuditMap 解析审计数据失败, err:%v", err)
+			return nil
+		}
+		return map[string]interface{}{"value": value}
+	}
+	for _, field := range model.AdminAuditRedactedFields {
+		if _, ok := m[field]; ok {
+			m[field] = model.AdminAuditRedactedValue
+		}
+	}
+	return m
+}
+
+// 序列化审计数据, 为空时返回空字符串
+func marshalAdminAuditData(m map[string]interface{}) string {
+	if m == nil {
+		return ""
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
@@ -5,7 +5,9 @@ import (
 	"eshop_server/src/common/cache"
 	"eshop_server/src/router/model"
 	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/uuid"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,18 +17,28 @@ import (
 
 // 自定义Claims结构
 type CustomClaims struct {
-	UserId string   `json:"user_id"`
-	Roles  []string `json:"roles"`
+	UserId    string   `json:"user_id"`
+	Roles     []string `json:"roles"`
+	SessionId string   `json:"sid"` // 登录会话id
+	jwt.StandardClaims
+}
+
+// 播放凭证Claims结构
+type PlaybackClaims struct {
+	UserId   string `json:"user_id"`
+	PlayerId string `json:"player_id"`
+	DeviceId string `json:"device_id,omitempty"` // 播放设备标识, 取自签发时的登录会话id, 用于同时播放会话数限制
 	jwt.StandardClaims
 }
 
 var (
-	jwtSecret          = []byte(config.CommonConfig.JwtSecret)       // 32位以上安全密钥
-	userTokenDuration  = cache.KeyJxsUserTokenTimeout * time.Second  // 用户Token有效期
-	adminTokenDuration = cache.KeyJxsAdminTokenTimeout * time.Second // 管理员Token有效期
-	TokenRefreshWindow = 5 * time.Minute                             // 刷新时间窗口
-	TokenType          = "Bearer"                                    // token类型
-	TokenIssuer        = "eshop_server"                              // token签发者
+	userTokenDuration     = cache.KeyJxsUserTokenTimeout * time.Second  // 用户Token有效期
+	adminTokenDuration    = cache.KeyJxsAdminTokenTimeout * time.Second // 管理员Token有效期
+	TokenRefreshWindow    = 5 * time.Minute                             // 刷新时间窗口
+	TokenType             = "Bearer"                                    // token类型
+	TokenIssuer           = "eshop_server"                              // token签发者
+	PlaybackTokenDuration = 30 * time.Minute                            // 播放凭证默认有效期
+	PlaybackTokenSubject  = "playback"                                  // 播放凭证主题
 )
 
 // 用户接口权限校验
@@ -57,19 +69,27 @@ func ParseAuthorization() gin.HandlerFunc {
 			return
 		}
 
-		// 校验Redis中的token是否有效（与当前请求token一致）
-		flag, cachedToken := cache.GetJxsUserToken(claims.UserId)
-		if !flag {
-			LogAuthErrorf(c, "ParseAuthorization 读取缓存失败, userId:%s", claims.UserId)
+		// 校验Redis中的登录会话是否有效（未注销且属于当前用户）
+		session, err := cache.GetJxsUserSession(claims.SessionId)
+		if err != nil {
+			LogAuthErrorf(c, "ParseAuthorization 读取缓存失败, userId:%s, sessionId:%s, err:%v", claims.UserId, claims.SessionId, err)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "认证凭证无效"})
 			return
 		}
-		if cachedToken != requestToken {
-			LogAuthErrorf(c, "ParseAuthorization 缓存token与请求token不一致, userId:%s, requestToken:%s, cachedToken:%s", claims.UserId, requestToken, cachedToken)
+		if claims.SessionId == "" || session["user_id"] != claims.UserId {
+			LogAuthErrorf(c, "ParseAuthorization 登录会话不存在或已注销, userId:%s, sessionId:%s", claims.UserId, claims.SessionId)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "认证凭证已失效"})
 			return
 		}
 
+		// 更新会话最近访问时间
+		lastSeen, _ := strconv.ParseInt(session["last_seen"], 10, 64)
+		if time.Now().Unix()-lastSeen >= model.UserSessionTouchInterval {
+			if err = cache.TouchJxsUserSession(claims.SessionId, c.ClientIP()); err != nil {
+				LogAuthErrorf(c, "ParseAuthorization 更新会话最近访问时间失败, sessionId:%s, err:%v", claims.SessionId, err)
+			}
+		}
+
 		// 自动刷新机制
 		// if claims.ExpiresAt-time.Now().Unix() < int64(TokenRefreshWindow.Seconds()) {
 		// 	newToken, err := generateToken(claims.UserId, claims.Roles)
@@ -86,6 +106,21 @@ func ParseAuthorization() gin.HandlerFunc {
 		// 存储用户上下文
 		c.Set("userId", claims.UserId)
 		c.Set("roles", claims.Roles)
+		c.Set("sessionId", claims.SessionId)
+		c.Set("mfa", session["mfa"] == "1")
+		c.Next()
+	}
+}
+
+// 两步验证校验中间件, 仅允许通过后台两步验证登录的会话访问
+// 刷新凭证轮换时会话保留mfa标记, 用户登录创建的会话即使后续被授予后台角色也无法访问
+func RequireMfa() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("mfa") {
+			LogAuthErrorf(c, "RequireMfa 会话未通过两步验证, userId:%s, sessionId:%s", c.GetString("userId"), c.GetString("sessionId"))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+			return
+		}
 		c.Next()
 	}
 }
@@ -105,13 +140,7 @@ func RequireRole(requiredRole string) gin.HandlerFunc {
 // 校验token
 func ValidateToken(tokenString string) (*CustomClaims, error) {
 	// 使用`jwt.ParseWithClaims`函数解析 token 字符串并提取其声明。
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// 检查token的签名方法是否为`HMAC`（一种对称密钥加密）
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.NewValidationError("invalid sign method", jwt.ValidationErrorSignatureInvalid)
-		}
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, jwtKeyFunc)
 	if err != nil {
 		return nil, err
 	}
@@ -125,13 +154,7 @@ func ValidateToken(tokenString string) (*CustomClaims, error) {
 // 获取token中的claims
 func GetTokenClaims(tokenString string) (*CustomClaims, error) {
 	// 使用`jwt.ParseWithClaims`函数解析 token 字符串并提取其声明。
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// 检查token的签名方法是否为`HMAC`（一种对称密钥加密）
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.NewValidationError("invalid sign method", jwt.ValidationErrorSignatureInvalid)
-		}
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, jwtKeyFunc)
 	// 忽略过期错误
 	if err != nil && err.(*jwt.ValidationError).Errors != jwt.ValidationErrorExpired {
 		return nil, err
@@ -143,42 +166,82 @@ func GetTokenClaims(tokenString string) (*CustomClaims, error) {
 	return nil, jwt.NewValidationError("invalid token claims", jwt.ValidationErrorClaimsInvalid)
 }
 
-// Token生成函数（供登录成功后调用, 根据角色生成不同的token）
-func GenerateToken(userId string, roles []string) (string, error) {
-	if containsRole(roles, model.UserRoleAdmin) {
-		return generateAdminToken(userId, roles)
-	} else if containsRole(roles, model.UserRoleUser) {
-		return generateUserToken(userId, roles)
+// Token生成函数（供登录及刷新凭证时调用, 根据角色生成不同的token）
+// 拥有管理员或运营角色时生成管理员token
+func GenerateToken(userId string, roles []string, sessionId string) (string, error) {
+	for _, role := range roles {
+		if role != model.UserRoleUser {
+			return generateAdminToken(userId, roles, sessionId)
+		}
+	}
+	if containsRole(roles, model.UserRoleUser) {
+		return generateUserToken(userId, roles, sessionId)
 	}
 	return "", errors.New("invalid roles")
 }
 
 // 生成用户Token
-func generateUserToken(userId string, roles []string) (string, error) {
+func generateUserToken(userId string, roles []string, sessionId string) (string, error) {
 	claims := CustomClaims{
-		UserId: userId,
-		Roles:  roles,
+		UserId:    userId,
+		Roles:     roles,
+		SessionId: sessionId,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(userTokenDuration).Unix(),
 			Issuer:    TokenIssuer,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return signJwtToken(claims)
 }
 
 // 生成管理员Token
-func generateAdminToken(userId string, roles []string) (string, error) {
+func generateAdminToken(userId string, roles []string, sessionId string) (string, error) {
 	claims := CustomClaims{
-		UserId: userId,
-		Roles:  roles,
+		UserId:    userId,
+		Roles:     roles,
+		SessionId: sessionId,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(adminTokenDuration).Unix(),
 			Issuer:    TokenIssuer,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return signJwtToken(claims)
+}
+
+// 生成播放凭证（供已购用户获取播放地址时调用，m3u8及ts分片共用同一凭证）
+// deviceId 播放设备标识, 同一设备签发的不同曲目凭证计为同一播放会话
+func GeneratePlaybackToken(userId string, playerId string, deviceId string) (string, time.Time, error) {
+	duration := PlaybackTokenDuration
+	if config.StreamConfig.PlayTokenTimeout > 0 {
+		duration = time.Duration(config.StreamConfig.PlayTokenTimeout) * time.Second
+	}
+	expiresAt := time.Now().Add(duration)
+	claims := PlaybackClaims{
+		UserId:   userId,
+		PlayerId: playerId,
+		DeviceId: deviceId,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.GetUuid(), // 播放会话id, 用于播放统计
+			ExpiresAt: expiresAt.Unix(),
+			Issuer:    TokenIssuer,
+			Subject:   PlaybackTokenSubject,
+		},
+	}
+	tokenString, err := signJwtToken(claims)
+	return tokenString, expiresAt, err
+}
+
+// 校验播放凭证
+func ValidatePlaybackToken(tokenString string) (*PlaybackClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &PlaybackClaims{}, jwtKeyFunc)
+	if err != nil {
+		return nil, err
+	}
+	// 检查token是否有效，且为播放凭证（防止登录token被当作播放凭证使用）
+	if claims, ok := token.Claims.(*PlaybackClaims); ok && token.Valid && claims.Subject == PlaybackTokenSubject && claims.PlayerId != "" {
+		return claims, nil
+	}
+	return nil, jwt.NewValidationError("invalid playback token claims", jwt.ValidationErrorClaimsInvalid)
 }
 
 // Token错误处理
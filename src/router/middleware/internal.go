@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"eshop_server/src/utils/config"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	InternalTokenHeader = "X-Internal-Token" // 服务间内部接口鉴权请求头
+)
+
+// 内部接口鉴权中间件, 校验请求头携带的共享密钥
+// 用于stream服务的上传、转码、下架等管理接口, 仅允许router等内部服务调用
+func InternalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := config.StreamConfig.InternalSecret
+		if secret == "" {
+			LogAuthErrorf(c, "InternalAuth 未配置内部接口密钥, 拒绝请求")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "内部接口未开放"})
+			return
+		}
+		token := c.GetHeader(InternalTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			LogAuthErrorf(c, "InternalAuth 内部接口凭证无效")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "认证凭证无效"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,338 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"eshop_server/src/router/model"
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// jwt校验密钥
+type jwtVerifyKey struct {
+	method   jwt.SigningMethod
+	key      crypto.PublicKey
+	notAfter int64 // 停止校验的时间戳（秒）, 为0时不限制
+}
+
+// jwt密钥集合, 由配置文件及远程jwks加载
+type jwtKeySet struct {
+	conf          config.JwtConf
+	signingKid    string
+	signingMethod jwt.SigningMethod
+	signingKey    crypto.PrivateKey
+	localKeys     map[string]*jwtVerifyKey // 配置文件中的校验密钥
+
+	mu              sync.RWMutex
+	remoteKeys      map[string]*jwtVerifyKey // 远程jwks中的校验密钥
+	remoteFetchedAt int64                    // 最近获取远程jwks的时间戳（秒）
+}
+
+var (
+	jwtKeys   *jwtKeySet // 当前密钥集合, 配置变更后重新加载
+	jwtKeysMu sync.Mutex
+)
+
+// InitJwtKeys 加载jwt密钥, 服务启动时调用以尽早发现配置错误
+func InitJwtKeys() error {
+	_, err := getJwtKeySet()
+	return err
+}
+
+// 获取当前密钥集合, 首次调用或jwt配置变更时重新加载
+func getJwtKeySet() (*jwtKeySet, error) {
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	if jwtKeys != nil && reflect.DeepEqual(jwtKeys.conf, config.CommonConfig.Jwt) {
+		return jwtKeys, nil
+	}
+	keys, err := loadJwtKeySet(config.CommonConfig.Jwt)
+	if err != nil {
+		if jwtKeys != nil {
+			// 配置错误时继续使用已加载的密钥
+			log.Errorf("getJwtKeySet 重新加载jwt密钥失败, 继续使用旧密钥, err: %v", err)
+			return jwtKeys, nil
+		}
+		return nil, err
+	}
+	jwtKeys = keys
+	log.Infof("getJwtKeySet 加载jwt密钥成功, signing_kid: %s, keys: %d, jwks_url: %s", keys.signingKid, len(keys.localKeys), keys.conf.JwksUrl)
+	return jwtKeys, nil
+}
+
+// 按配置加载密钥集合
+func loadJwtKeySet(conf config.JwtConf) (*jwtKeySet, error) {
+	keys := &jwtKeySet{
+		conf:       conf,
+		localKeys:  make(map[string]*jwtVerifyKey),
+		remoteKeys: make(map[string]*jwtVerifyKey),
+	}
+	for _, keyConf := range conf.Keys {
+		if keyConf.Kid == "" {
+			return nil, errors.New("jwt key without kid")
+		}
+		if _, ok := keys.localKeys[keyConf.Kid]; ok {
+			return nil, fmt.Errorf("duplicate jwt kid %s", keyConf.Kid)
+		}
+
+		var privateKey crypto.PrivateKey
+		var publicKey crypto.PublicKey
+		var err error
+		if keyConf.PrivateKeyFile != "" {
+			if privateKey, err = readJwtPrivateKey(keyConf.PrivateKeyFile); err != nil {
+				return nil, fmt.Errorf("jwt kid %s: %v", keyConf.Kid, err)
+			}
+			publicKey = privateKey.(crypto.Signer).Public()
+		}
+		if keyConf.PublicKeyFile != "" {
+			if publicKey, err = readJwtPublicKey(keyConf.PublicKeyFile); err != nil {
+				return nil, fmt.Errorf("jwt kid %s: %v", keyConf.Kid, err)
+			}
+		}
+		if publicKey == nil {
+			return nil, fmt.Errorf("jwt kid %s: private_key_file or public_key_file required", keyConf.Kid)
+		}
+		method, err := getJwtSigningMethod(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("jwt kid %s: %v", keyConf.Kid, err)
+		}
+		keys.localKeys[keyConf.Kid] = &jwtVerifyKey{method: method, key: publicKey, notAfter: keyConf.NotAfter}
+
+		if keyConf.Kid == conf.SigningKid {
+			if privateKey == nil {
+				return nil, fmt.Errorf("jwt signing kid %s without private_key_file", keyConf.Kid)
+			}
+			keys.signingKid, keys.signingMethod, keys.signingKey = keyConf.Kid, method, privateKey
+		}
+	}
+	if conf.SigningKid != "" && keys.signingKey == nil {
+		return nil, fmt.Errorf("jwt signing kid %s not found", conf.SigningKid)
+	}
+	return keys, nil
+}
+
+// 读取PEM私钥, 支持RSA及Ed25519
+func readJwtPrivateKey(file string) (crypto.PrivateKey, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(b); err == nil {
+		return key, nil
+	}
+	return jwt.ParseEdPrivateKeyFromPEM(b)
+}
+
+// 读取PEM公钥, 支持RSA及Ed25519
+func readJwtPublicKey(file string) (crypto.PublicKey, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(b); err == nil {
+		return key, nil
+	}
+	return jwt.ParseEdPublicKeyFromPEM(b)
+}
+
+// 根据公钥类型获取签名算法
+func getJwtSigningMethod(publicKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+}
+
+// 签发token, 使用signing_kid对应的私钥并在header中写入kid
+// 未配置signing_kid时兼容使用jwt_secret按HS256签发
+func signJwtToken(claims jwt.Claims) (string, error) {
+	keys, err := getJwtKeySet()
+	if err != nil {
+		return "", err
+	}
+	if keys.signingKey == nil {
+		if config.CommonConfig.JwtSecret == "" {
+			return "", errors.New("jwt signing key not configured")
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.CommonConfig.JwtSecret))
+	}
+	token := jwt.NewWithClaims(keys.signingMethod, claims)
+	token.Header["kid"] = keys.signingKid
+	return token.SignedString(keys.signingKey)
+}
+
+// 按token header中的kid获取校验密钥
+// 无kid的token为迁移前按HS256签发, 仍配置jwt_secret时继续校验
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || config.CommonConfig.JwtSecret == "" {
+			return nil, jwt.NewValidationError("invalid sign method", jwt.ValidationErrorSignatureInvalid)
+		}
+		return []byte(config.CommonConfig.JwtSecret), nil
+	}
+
+	key, err := getJwtVerifyKey(kid)
+	if err != nil {
+		return nil, jwt.NewValidationError(err.Error(), jwt.ValidationErrorUnverifiable)
+	}
+	// 签名算法须与密钥一致, 防止算法混淆
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, jwt.NewValidationError("invalid sign method", jwt.ValidationErrorSignatureInvalid)
+	}
+	return key.key, nil
+}
+
+// 获取kid对应的校验密钥, 配置jwks_url时按需刷新远程jwks
+func getJwtVerifyKey(kid string) (*jwtVerifyKey, error) {
+	keys, err := getJwtKeySet()
+	if err != nil {
+		return nil, err
+	}
+	key := keys.lookup(kid)
+	if keys.conf.JwksUrl != "" && keys.startRemoteRefresh(key == nil) {
+		if err = keys.refreshRemoteJwks(); err != nil {
+			log.Errorf("getJwtVerifyKey 获取远程jwks失败, url: %s, err: %v", keys.conf.JwksUrl, err)
+		}
+		key = keys.lookup(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown jwt kid %s", kid)
+	}
+	if key.notAfter > 0 && time.Now().Unix() > key.notAfter {
+		return nil, fmt.Errorf("jwt kid %s retired", kid)
+	}
+	return key, nil
+}
+
+// 查找校验密钥, 配置文件中的密钥优先
+func (keys *jwtKeySet) lookup(kid string) *jwtVerifyKey {
+	if key, ok := keys.localKeys[kid]; ok {
+		return key
+	}
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+	return keys.remoteKeys[kid]
+}
+
+// 判断是否需要刷新远程jwks, 需要时记录刷新时间避免并发重复请求
+// @param missing kid未找到时按最小间隔刷新, 否则按刷新间隔定期刷新
+func (keys *jwtKeySet) startRemoteRefresh(missing bool) bool {
+	interval := keys.conf.JwksRefreshInterval
+	if interval == 0 {
+		interval = model.JwksDefaultRefreshInterval
+	}
+	if missing {
+		interval = model.JwksMinRefreshInterval
+	}
+	now := time.Now().Unix()
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	if now-keys.remoteFetchedAt < interval {
+		return false
+	}
+	keys.remoteFetchedAt = now
+	return true
+}
+
+// 获取远程jwks并替换远程校验密钥
+func (keys *jwtKeySet) refreshRemoteJwks() error {
+	client := &http.Client{Timeout: time.Duration(model.JwksFetchTimeout) * time.Second}
+	resp, err := client.Get(keys.conf.JwksUrl)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var jwks model.Jwks
+	if err = json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return err
+	}
+
+	remoteKeys := make(map[string]*jwtVerifyKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, err := parseJwk(&jwk)
+		if err != nil {
+			log.Warnf("refreshRemoteJwks 忽略无法解析的jwk, kid: %s, err: %v", jwk.Kid, err)
+			continue
+		}
+		remoteKeys[jwk.Kid] = key
+	}
+	keys.mu.Lock()
+	keys.remoteKeys = remoteKeys
+	keys.mu.Unlock()
+	return nil
+}
+
+// 解析jwk公钥
+func parseJwk(jwk *model.Jwk) (*jwtVerifyKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return &jwtVerifyKey{method: jwt.SigningMethodRS256, key: key}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return &jwtVerifyKey{method: jwt.SigningMethodEdDSA, key: ed25519.PublicKey(x)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported kty %s", jwk.Kty)
+	}
+}
+
+// GetJwks 获取配置文件中仍在校验期内的公钥, 供其他服务校验token
+func GetJwks() (*model.Jwks, error) {
+	keys, err := getJwtKeySet()
+	if err != nil {
+		return nil, err
+	}
+	jwks := &model.Jwks{Keys: []model.Jwk{}}
+	now := time.Now().Unix()
+	for _, keyConf := range keys.conf.Keys {
+		key := keys.localKeys[keyConf.Kid]
+		if key.notAfter > 0 && now > key.notAfter {
+			continue
+		}
+		jwk := model.Jwk{Kid: keyConf.Kid, Use: "sig", Alg: key.method.Alg()}
+		switch k := key.key.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
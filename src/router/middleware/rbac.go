@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"eshop_server/src/common/cache"
+	"eshop_server/src/router/dao"
+	"eshop_server/src/router/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 权限验证中间件, 需拥有全部所需权限
+// 权限按角色实时查询（带缓存）, 角色权限变更后无需重新签发token
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, exists := c.Get("roles")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+			return
+		}
+		owned, err := GetRolesPermissions(roles.([]string))
+		if err != nil {
+			LogAuthErrorf(c, "RequirePermission 获取角色权限失败, roles:%v, err:%v", roles, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "获取权限失败"})
+			return
+		}
+		for _, permission := range permissions {
+			if !model.HasPermission(owned, permission) {
+				LogAuthErrorf(c, "RequirePermission 权限不足, userId:%v, roles:%v, required:%s", c.GetString("userId"), roles, permission)
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+				return
+			}
+		}
+		c.Set("permissions", owned)
+		c.Next()
+	}
+}
+
+// GetRolesPermissions 获取角色拥有的全部权限
+// 内置管理员角色拥有全部权限, 内置普通用户角色无后台权限, 其余角色从角色权限表查询
+func GetRolesPermissions(roles []string) (permissions []string, err error) {
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		var rolePermissions []string
+		switch role {
+		case model.UserRoleAdmin:
+			rolePermissions = []string{model.PermissionAll}
+		case model.UserRoleUser:
+			continue
+		default:
+			if rolePermissions, err = getRolePermissions(role); err != nil {
+				return nil, err
+			}
+		}
+		for _, permission := range rolePermissions {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// 获取运营角色的权限, 优先读取缓存
+func getRolePermissions(role string) ([]string, error) {
+	if ok, permissions := cache.GetJxsRolePermissions(role); ok {
+		return permissions, nil
+	}
+	list, err := dao.GetRolePermissions([]string{role})
+	if err != nil {
+		return nil, err
+	}
+	permissions := make([]string, 0, len(list))
+	for _, item := range list {
+		permissions = append(permissions, item.Permission)
+	}
+	_ = cache.SaveJxsRolePermissions(role, permissions)
+	return permissions, nil
+}
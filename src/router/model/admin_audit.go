@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	AdminAuditTargetUser    = "user"    // 审计目标 用户
+	AdminAuditTargetProduct = "product" // 审计目标 商品
+	AdminAuditTargetPlayer  = "player"  // 审计目标 播放资源
+	AdminAuditTargetRole    = "role"    // 审计目标 角色
+
+	AdminAuditMaxBodySize        = 64 * 1024      // 未指定变更数据时记录的请求体最大长度（Byte字节）
+	AdminAuditDefaultPageSize    = 50             // 审计日志默认每页条数
+	AdminAuditMaxPageSize        = 200            // 审计日志最大每页条数
+	AdminAuditRedactedValue      = "******"       // 敏感字段脱敏后的值
+	AdminAuditRequestIdHeader    = "X-Request-Id" // 请求id请求头, 未传入时生成并写入响应头
+	AdminAuditRequestIdMaxLength = 64             // 请求id最大长度
+)
+
+var (
+	AdminAuditRedactedFields = []string{"password", "refresh_token", "access_token"} // 审计数据中脱敏的字段
+)
+
+// 管理后台操作审计日志, 仅追加写入
+type AdminAuditLog struct {
+	Id         int64     `json:"id" gorm:"column:id;primary_key;AUTO_INCREMENT;NOT NULL;comment:'自增唯一ID'"`
+	RequestId  string    `json:"request_id" gorm:"column:request_id;default:'';comment:'请求id'"`
+	ActorId    string    `json:"actor_id" gorm:"column:actor_id;default:'';comment:'操作人用户id'"`
+	ActorRoles RoleSlice `json:"actor_roles" gorm:"column:actor_roles;type:varchar(255);default:'';comment:'操作人角色, 逗号分隔'"`
+	Action     string    `json:"action" gorm:"column:action;NOT NULL;comment:'操作, 请求方法及路由'"`
+	TargetType string    `json:"target_type" gorm:"column:target_type;default:'';comment:'目标实体类型'"`
+	TargetId   string    `json:"target_id" gorm:"column:target_id;default:'';comment:'目标实体id'"`
+	BeforeData string    `json:"before_data" gorm:"column:before_data;comment:'变更前数据（JSON, 仅包含变更字段）'"`
+	AfterData  string    `json:"after_data" gorm:"column:after_data;comment:'变更后数据（JSON, 仅包含变更字段）'"`
+	Ip         string    `json:"ip" gorm:"column:ip;default:'';comment:'操作人ip'"`
+	HttpStatus int32     `json:"http_status" gorm:"column:http_status;default:0;comment:'http响应状态码'"`
+	ResultCode int32     `json:"result_code" gorm:"column:result_code;default:0;comment:'接口响应错误码, 0为成功'"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;default:CURRENT_TIMESTAMP;comment:'创建时间'"`
+}
+
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_log"
+}
+
+// 审计日志查询条件, 字段为空时不过滤
+type AdminAuditLogFilter struct {
+	ActorId    string
+	Action     string // 模糊匹配
+	TargetType string
+	TargetId   string
+	RequestId  string
+	StartTime  time.Time
+	EndTime    time.Time
+}
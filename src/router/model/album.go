@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+)
+
+var (
+	ProductsPlayerTracklistStatusList = []int32{ // 后台曲目列表包含的文件状态, 不含已下架
+		ProductsPlayerStatusInit,
+		ProductsPlayerStatusOk,
+		ProductsPlayerStatusParsing,
+		ProductsPlayerStatusError,
+		ProductsPlayerStatusQuarantine,
+	}
+)
+
+// @Title	专辑曲目信息格式化
+type AlbumTrackView struct {
+	PlayerId        string     `json:"player_id"`
+	DiscNo          int32      `json:"disc_no"`
+	TrackNo         int32      `json:"track_no"`
+	Title           string     `json:"title"`
+	Duration        int64      `json:"duration"`
+	PreviewUrl      string     `json:"preview_url"`
+	PreviewDuration int64      `json:"preview_duration"`
+	PlayType        string     `json:"play_type,omitempty"`
+	PlayUrl         string     `json:"play_url,omitempty"`   // 带播放凭证的播放地址, 未购买时为空
+	HifiUrl         string     `json:"hifi_url,omitempty"`   // 带播放凭证的无损播放地址, 未购买或无无损音轨时为空
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"` // 播放凭证过期时间
+}
+
+// @Title	专辑信息格式化
+type AlbumView struct {
+	ProductId   string            `json:"product_id"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	ImageUrl    string            `json:"image_url"`
+	Entitled    bool              `json:"entitled"`    // 用户是否已购买
+	TrackCount  int               `json:"track_count"` // 曲目数
+	Duration    int64             `json:"duration"`    // 专辑总时长
+	Tracks      []*AlbumTrackView `json:"tracks"`
+}
+
+// @Title	曲目排序请求体, tracks顺序即曲目顺序, 各碟曲目序号从1开始
+type ReorderTracklistReq struct {
+	ProductId string                 `json:"product_id"`
+	Tracks    []ReorderTracklistItem `json:"tracks"`
+}
+
+type ReorderTracklistItem struct {
+	PlayerId string `json:"player_id"`
+	DiscNo   int32  `json:"disc_no"` // 碟号, 为空时默认为1
+}
+
+// @Title	曲目信息更新请求体
+type UpdateTrackReq struct {
+	Title string `json:"title"`
+}
+
+func (m *ProductsPlayer) TrackViewFormat() (res *AlbumTrackView) {
+	res = &AlbumTrackView{
+		PlayerId:        m.Id,
+		DiscNo:          m.DiscNo,
+		TrackNo:         m.TrackNo,
+		Title:           m.Title,
+		Duration:        m.Duration,
+		PreviewUrl:      m.PreviewUrl,
+		PreviewDuration: m.PreviewDuration,
+	}
+	if res.Title == "" {
+		res.Title = m.Filename
+	}
+	return
+}
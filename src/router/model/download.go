@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	DownloadFormatOriginal = "original" // 下载格式 原始格式
+	DownloadFormatMp3      = "mp3"      // 下载格式 mp3
+	DownloadFormatWav      = "wav"      // 下载格式 wav
+	DownloadFormatFlac     = "flac"     // 下载格式 flac
+
+	DownloadDefaultLinkTimeout  int64 = 10 * 60 // 下载链接默认有效时长（秒）
+	DownloadDefaultMaxDownloads int64 = 5       // 每笔购买默认最大下载次数
+)
+
+var (
+	DownloadFormatList = []string{DownloadFormatOriginal, DownloadFormatMp3, DownloadFormatWav, DownloadFormatFlac} // 支持的下载格式列表
+)
+
+// @Title	下载凭证, 签发后保存在redis, 由stream服务一次性消费
+type DownloadTicket struct {
+	TicketId   string `json:"ticket_id"`
+	UserId     string `json:"user_id"`
+	PlayerId   string `json:"player_id"`
+	ProductId  string `json:"product_id"`
+	PurchaseId int64  `json:"purchase_id"` // 计入下载次数的购买记录
+	Format     string `json:"format"`      // 下载格式, 已将original解析为源文件格式
+	CreatedAt  int64  `json:"created_at"`
+}
+
+// @Title	获取藏品下载地址响应体
+type GetInventoryDownloadResponse struct {
+	PlayerId          string    `json:"player_id"`
+	Format            string    `json:"format"`
+	DownloadUrl       string    `json:"download_url"`       // 一次性下载地址
+	ExpiresAt         time.Time `json:"expires_at"`         // 下载地址过期时间
+	RemainingDownload int64     `json:"remaining_download"` // 本次下载后剩余下载次数, -1为不限制
+}
+
+// 判断是否为支持的下载格式
+func IsDownloadFormat(format string) bool {
+	for _, f := range DownloadFormatList {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
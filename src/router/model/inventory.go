@@ -12,3 +12,17 @@ type GetInventoryListResponse struct {
 	ImageUrl    string    `json:"image_url"`
 	PurchaseAt  time.Time `json:"purchase_at"`
 }
+
+// @Title	获取用户藏品播放地址响应体
+type GetInventoryPlayerResponse struct {
+	PlayerId  string    `json:"player_id"`
+	DiscNo    int32     `json:"disc_no"`
+	TrackNo   int32     `json:"track_no"`
+	Title     string    `json:"title"`
+	Filename  string    `json:"filename"`
+	Duration  int64     `json:"duration"`
+	PlayType  string    `json:"play_type"`
+	PlayUrl   string    `json:"play_url"`   // 带播放凭证的播放地址
+	HifiUrl   string    `json:"hifi_url"`   // 带播放凭证的无损播放地址, 无无损音轨时为空
+	ExpiresAt time.Time `json:"expires_at"` // 播放凭证过期时间
+}
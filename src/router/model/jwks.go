@@ -0,0 +1,25 @@
+package model
+
+const (
+	JwksDefaultRefreshInterval int64 = 10 * 60 // 远程jwks默认刷新间隔（秒）
+	JwksMinRefreshInterval     int64 = 30      // 遇到未知kid时远程jwks最小刷新间隔（秒）
+	JwksCacheMaxAge            int64 = 5 * 60  // jwks接口响应缓存时长（秒）
+	JwksFetchTimeout           int64 = 10      // 获取远程jwks超时时长（秒）
+)
+
+// @Title	JSON Web Key（RFC 7517）, 仅包含公钥参数
+type Jwk struct {
+	Kty string `json:"kty"`           // 密钥类型 RSA/OKP
+	Kid string `json:"kid"`           // 密钥id
+	Use string `json:"use"`           // 用途 sig
+	Alg string `json:"alg"`           // 签名算法 RS256/EdDSA
+	N   string `json:"n,omitempty"`   // RSA模数, base64url编码
+	E   string `json:"e,omitempty"`   // RSA指数, base64url编码
+	Crv string `json:"crv,omitempty"` // OKP曲线 Ed25519
+	X   string `json:"x,omitempty"`   // OKP公钥, base64url编码
+}
+
+// @Title	JSON Web Key Set
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
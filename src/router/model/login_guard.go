@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	LoginGuardDefaultFailWindow         int64 = 15 * 60 // 登录失败计数默认窗口（秒）
+	LoginGuardDefaultAccountMaxAttempts int64 = 5       // 账号默认最大失败次数
+	LoginGuardDefaultIpMaxAttempts      int64 = 30      // ip默认最大失败次数
+	LoginGuardDefaultLockDuration       int64 = 15 * 60 // 默认临时锁定时长（秒）
+	LoginGuardDefaultDelayAfter         int64 = 2       // 默认开始延迟响应的失败次数
+	LoginGuardDefaultMaxDelay           int64 = 8       // 默认最大延迟响应时长（秒）
+)
+
+// @Title	临时锁定用户视图
+type LockedUserView struct {
+	Id            string    `json:"id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	LoginAttempts int32     `json:"login_attempts"` // 锁定前连续登录失败次数
+	LockedUntil   time.Time `json:"locked_until"`   // 锁定截止时间, 到期自动解锁
+	LastLogin     time.Time `json:"last_login"`
+}
+
+// 临时锁定用户视图
+func (m *User) LockedViewFormat() *LockedUserView {
+	return &LockedUserView{
+		Id:            m.Id,
+		Name:          m.Name,
+		Email:         m.Email,
+		LoginAttempts: m.LoginAttempts,
+		LockedUntil:   m.LockedUntil,
+		LastLogin:     m.LastLogin,
+	}
+}
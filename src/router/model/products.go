@@ -13,6 +13,8 @@ const (
 	ProductSourceTypeYlt     int32 = 1 // ylt
 
 	ProductImageUrlDefault string = "https://ucarecdn.com/28285bd2-bfa6-46aa-af19-24e00ea396a9/-/preview/1000x562/" //默认商品图片链接
+
+	ProductPreviewListMaxLength = 10 // 商品列表每个商品最多返回的试听数
 )
 
 /*
@@ -66,12 +68,23 @@ type CreateProductReq struct {
 // @Title	用户查看商品列表格式化
 // @Author  AInoriex  (2025/06/26 16:30)
 type ProductUserView struct {
-	Id          string  `json:"id"`
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	ImageUrl    string  `json:"image_url"`
-	Sales       int64   `json:"sales"`
+	Id          string                `json:"id"`
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	Price       float64               `json:"price"`
+	ImageUrl    string                `json:"image_url"`
+	Sales       int64                 `json:"sales"`
+	PreviewList []*ProductPreviewView `json:"preview_list"` // 试听列表
+}
+
+// @Title	用户查看商品试听信息格式化
+type ProductPreviewView struct {
+	PlayerId        string `json:"player_id"`
+	TrackNo         int32  `json:"track_no"`
+	Title           string `json:"title"`
+	PreviewUrl      string `json:"preview_url"`
+	PreviewDuration int64  `json:"preview_duration"`
+	Duration        int64  `json:"duration"` // 完整音频时长
 }
 
 func (m *Products) UserViewFormat() (resList *ProductUserView) {
@@ -82,6 +95,22 @@ func (m *Products) UserViewFormat() (resList *ProductUserView) {
 		Price:       m.Price,
 		ImageUrl:    m.ImageUrl,
 		Sales:       m.Sales,
+		PreviewList: make([]*ProductPreviewView, 0),
+	}
+	return
+}
+
+func (m *ProductsPlayer) PreviewViewFormat() (res *ProductPreviewView) {
+	res = &ProductPreviewView{
+		PlayerId:        m.Id,
+		TrackNo:         m.TrackNo,
+		Title:           m.Title,
+		PreviewUrl:      m.PreviewUrl,
+		PreviewDuration: m.PreviewDuration,
+		Duration:        m.Duration,
+	}
+	if res.Title == "" {
+		res.Title = m.Filename
 	}
 	return
 }
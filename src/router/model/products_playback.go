@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	ProductsPlaybackCompleteRatio = 0.9 // 收听时长达到文件时长的90%视为完播
+)
+
+// 播放会话统计
+type ProductsPlaybackSession struct {
+	Id              string    `json:"id" gorm:"column:id;primary_key;NOT NULL;comment:'播放会话id'"`
+	UserId          string    `json:"user_id" gorm:"column:user_id;NOT NULL;comment:'用户id'"`
+	PlayerId        string    `json:"player_id" gorm:"column:player_id;NOT NULL;comment:'播放id'"`
+	ProductId       string    `json:"product_id" gorm:"column:product_id;default:'';comment:'商品id'"`
+	Requests        int64     `json:"requests" gorm:"column:requests;default:0;comment:'分片请求次数'"`
+	Segments        int64     `json:"segments" gorm:"column:segments;default:0;comment:'不重复分片数'"`
+	ListenedSeconds int64     `json:"listened_seconds" gorm:"column:listened_seconds;default:0;comment:'估算收听时长（秒）'"`
+	Duration        int64     `json:"duration" gorm:"column:duration;default:0;comment:'文件时长（秒）'"`
+	ClientIp        string    `json:"client_ip" gorm:"column:client_ip;default:'';comment:'客户端ip'"`
+	StartedAt       time.Time `json:"started_at" gorm:"column:started_at;NOT NULL;comment:'开始播放时间'"`
+	LastPlayedAt    time.Time `json:"last_played_at" gorm:"column:last_played_at;NOT NULL;comment:'最后请求时间'"`
+	CreateAt        time.Time `json:"created_at" gorm:"column:created_at;default:CURRENT_TIMESTAMP;comment:'创建时间'"`
+	UpdateAt        time.Time `json:"updated_at" gorm:"column:updated_at;default:CURRENT_TIMESTAMP;comment:'更新时间'"`
+}
+
+func (t *ProductsPlaybackSession) TableName() string {
+	return "products_playback_session"
+}
+
+// @Title	管理后台播放统计 按商品汇总
+// @Author  AInoriex  (2026/10/18)
+type AdminPlaybackProductStat struct {
+	ProductId       string  `json:"product_id" gorm:"column:product_id"`
+	ProductName     string  `json:"product_name" gorm:"-"`
+	Plays           int64   `json:"plays" gorm:"column:plays"`                       // 播放次数
+	UniqueListeners int64   `json:"unique_listeners" gorm:"column:unique_listeners"` // 独立收听用户数
+	Completions     int64   `json:"completions" gorm:"column:completions"`           // 完播次数
+	CompletionRate  float64 `json:"completion_rate" gorm:"-"`                        // 完播率
+	ListenedSeconds int64   `json:"listened_seconds" gorm:"column:listened_seconds"` // 累计收听时长（秒）
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+)
+
+// 商品播放文件元数据
+type ProductsPlayerMetadata struct {
+	PlayerId           string    `json:"player_id" gorm:"column:player_id;primary_key;NOT NULL;comment:'播放id'"`
+	ProductId          string    `json:"product_id" gorm:"column:product_id;NOT NULL;comment:'商品id'"`
+	FormatName         string    `json:"format_name" gorm:"column:format_name;default:'';comment:'封装格式'"`
+	Codec              string    `json:"codec" gorm:"column:codec;default:'';comment:'音频编码'"`
+	SampleRate         int64     `json:"sample_rate" gorm:"column:sample_rate;default:0;comment:'采样率（Hz）'"`
+	Channels           int64     `json:"channels" gorm:"column:channels;default:0;comment:'声道数'"`
+	ChannelLayout      string    `json:"channel_layout" gorm:"column:channel_layout;default:'';comment:'声道布局'"`
+	BitRate            int64     `json:"bit_rate" gorm:"column:bit_rate;default:0;comment:'码率（bps）'"`
+	BitsPerSample      int64     `json:"bits_per_sample" gorm:"column:bits_per_sample;default:0;comment:'采样位深'"`
+	Duration           float64   `json:"duration" gorm:"column:duration;default:0;comment:'精确时长（秒）'"`
+	IntegratedLoudness *float64  `json:"integrated_loudness" gorm:"column:integrated_loudness;default:NULL;comment:'综合响度（LUFS）'"`
+	LoudnessRange      *float64  `json:"loudness_range" gorm:"column:loudness_range;default:NULL;comment:'响度范围（LU）'"`
+	TruePeak           *float64  `json:"true_peak" gorm:"column:true_peak;default:NULL;comment:'真峰值（dBTP）'"`
+	Title              string    `json:"title" gorm:"column:title;default:'';comment:'标签 标题'"`
+	Artist             string    `json:"artist" gorm:"column:artist;default:'';comment:'标签 艺术家'"`
+	Album              string    `json:"album" gorm:"column:album;default:'';comment:'标签 专辑'"`
+	AlbumArtist        string    `json:"album_artist" gorm:"column:album_artist;default:'';comment:'标签 专辑艺术家'"`
+	Genre              string    `json:"genre" gorm:"column:genre;default:'';comment:'标签 流派'"`
+	Track              string    `json:"track" gorm:"column:track;default:'';comment:'标签 音轨号'"`
+	Date               string    `json:"date" gorm:"column:date;default:'';comment:'标签 日期'"`
+	Tags               string    `json:"tags" gorm:"column:tags;default:NULL;comment:'全部标签（json）'"`
+	CoverUrl           string    `json:"cover_url" gorm:"column:cover_url;default:'';comment:'封面地址'"`
+	CreateAt           time.Time `json:"created_at" gorm:"column:created_at;default:CURRENT_TIMESTAMP;comment:'创建时间'"`
+	UpdateAt           time.Time `json:"updated_at" gorm:"column:updated_at;default:CURRENT_TIMESTAMP;comment:'更新时间'"`
+}
+
+func (t *ProductsPlayerMetadata) TableName() string {
+	return "products_player_metadata"
+}
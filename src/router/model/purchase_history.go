@@ -32,6 +32,8 @@ type PurchaseHistory struct {
 	OrderId     string    `json:"order_id" gorm:"column:order_id;default:NULL;comment:'订单ID(关联订单表)'"`
 	PaymentId   string    `json:"payment_id" gorm:"column:payment_id;default:NULL;comment:'支付ID(关联支付表)'"`
 	PurchasedAt time.Time `json:"purchased_at" gorm:"column:purchased_at;default:NULL;comment:'支付时间'"`
+
+	DownloadCount int64 `json:"download_count" gorm:"column:download_count;default:0;comment:'已下载次数'"`
 }
 
 func (t *PurchaseHistory) TableName() string {
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"regexp"
+	"time"
+)
+
+const (
+	PermissionAll           = "*"              // 全部权限, 仅内置管理员角色拥有
+	PermissionUserRead      = "user:read"      // 查看用户
+	PermissionUserWrite     = "user:write"     // 拉黑、解锁用户
+	PermissionProductRead   = "product:read"   // 查看商品
+	PermissionProductWrite  = "product:write"  // 创建、下架商品
+	PermissionPlayerRead    = "player:read"    // 查看商品音频资源
+	PermissionPlayerWrite   = "player:write"   // 上传、替换、编辑商品音频资源
+	PermissionOrderRead     = "order:read"     // 查看订单
+	PermissionAnalyticsRead = "analytics:read" // 查看数据报表
+	PermissionRoleRead      = "role:read"      // 查看角色
+	PermissionRoleWrite     = "role:write"     // 编辑角色、分配用户角色
+	PermissionAuditRead     = "audit:read"     // 查看操作审计日志
+)
+
+var (
+	// 全部可分配的权限及说明
+	PermissionList = []*PermissionView{
+		{Permission: PermissionUserRead, Description: "查看用户"},
+		{Permission: PermissionUserWrite, Description: "拉黑、解锁用户"},
+		{Permission: PermissionProductRead, Description: "查看商品"},
+		{Permission: PermissionProductWrite, Description: "创建、下架商品"},
+		{Permission: PermissionPlayerRead, Description: "查看商品音频资源"},
+		{Permission: PermissionPlayerWrite, Description: "上传、替换、编辑商品音频资源"},
+		{Permission: PermissionOrderRead, Description: "查看订单"},
+		{Permission: PermissionAnalyticsRead, Description: "查看数据报表"},
+		{Permission: PermissionRoleRead, Description: "查看角色"},
+		{Permission: PermissionRoleWrite, Description: "编辑角色、分配用户角色"},
+		{Permission: PermissionAuditRead, Description: "查看操作审计日志"},
+	}
+
+	RoleNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`) // 角色名称格式
+)
+
+// 运营角色
+type Role struct {
+	Name        string    `json:"name" gorm:"column:name;primary_key;NOT NULL;comment:'角色名称'"`
+	Description string    `json:"description" gorm:"column:description;default:'';comment:'角色描述'"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at;default:CURRENT_TIMESTAMP;comment:'创建时间'"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"column:updated_at;default:CURRENT_TIMESTAMP;comment:'更新时间'"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// 角色权限
+type RolePermission struct {
+	Id         int64     `json:"id" gorm:"column:id;primary_key;AUTO_INCREMENT;NOT NULL;comment:'自增唯一ID'"`
+	Role       string    `json:"role" gorm:"column:role;NOT NULL;comment:'角色名称'"`
+	Permission string    `json:"permission" gorm:"column:permission;NOT NULL;comment:'权限标识'"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;default:CURRENT_TIMESTAMP;comment:'创建时间'"`
+}
+
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+// @Title	权限视图
+type PermissionView struct {
+	Permission  string `json:"permission"`
+	Description string `json:"description"`
+}
+
+// @Title	角色视图
+type RoleView struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	Builtin     bool     `json:"builtin"` // 是否为内置角色, 内置角色不可编辑
+}
+
+// 创建或更新角色请求体
+type SaveRoleReq struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"` // 角色拥有的全部权限, 覆盖原有权限
+}
+
+// 分配用户角色请求体
+type AssignUserRolesReq struct {
+	UserId string   `json:"user_id"`
+	Roles  []string `json:"roles"` // 用户拥有的全部角色, 覆盖原有角色
+}
+
+// 判断是否为内置角色
+func IsBuiltinRole(name string) bool {
+	return name == UserRoleAdmin || name == UserRoleUser
+}
+
+// 判断是否为可分配的权限
+func IsPermission(permission string) bool {
+	for _, p := range PermissionList {
+		if p.Permission == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断权限列表是否包含所需权限
+func HasPermission(permissions []string, required string) bool {
+	for _, p := range permissions {
+		if p == PermissionAll || p == required {
+			return true
+		}
+	}
+	return false
+}
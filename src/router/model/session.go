@@ -0,0 +1,46 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	UserSessionDefaultRefreshTokenTimeout int64 = 30 * 24 * 60 * 60 // 刷新凭证默认有效时长（秒）
+	UserSessionDefaultMaxSessions         int64 = 10                // 每个用户默认最多保留的登录会话数
+	UserSessionTouchInterval              int64 = 60                // 会话最近访问时间更新间隔（秒）
+	UserSessionDeviceMaxLength                  = 255               // 登录设备最大长度
+)
+
+// @Title	用户登录会话视图
+type UserSessionView struct {
+	SessionId string    `json:"session_id"`
+	Device    string    `json:"device"` // 登录设备, 取自User-Agent
+	Ip        string    `json:"ip"`     // 最近访问ip
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"` // 最近访问时间
+	Current   bool      `json:"current"`   // 是否为当前请求使用的会话
+}
+
+// 注销登录会话请求体
+type RevokeSessionReq struct {
+	SessionId string `json:"session_id"`
+}
+
+// 注销所有登录会话请求体
+type RevokeAllSessionsReq struct {
+	ExcludeCurrent bool `json:"exclude_current"` // 是否保留当前请求使用的会话
+}
+
+// 由缓存的会话信息生成会话视图
+func UserSessionViewFormat(sessionId string, fields map[string]string) *UserSessionView {
+	createdAt, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+	lastSeen, _ := strconv.ParseInt(fields["last_seen"], 10, 64)
+	return &UserSessionView{
+		SessionId: sessionId,
+		Device:    fields["device"],
+		Ip:        fields["ip"],
+		CreatedAt: time.Unix(createdAt, 0),
+		LastSeen:  time.Unix(lastSeen, 0),
+	}
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	TotpRecoveryCodeCount  = 10      // 每次生成的恢复码数量
+	TotpRecoveryCodeLength = 10      // 恢复码长度, 不含分隔符
+	TotpQrCodeSize         = 256     // 绑定二维码边长（像素）
+	TotpDefaultIssuer      = "eshop" // 验证器应用显示的默认发行方, 优先使用配置的应用名称
+)
+
+// 两步验证恢复码, 仅保存哈希
+type UserRecoveryCode struct {
+	Id        int64     `json:"id" gorm:"column:id;primary_key;AUTO_INCREMENT;NOT NULL;comment:'自增唯一ID'"`
+	UserId    string    `json:"user_id" gorm:"column:user_id;NOT NULL;comment:'用户ID'"`
+	CodeHash  string    `json:"-" gorm:"column:code_hash;NOT NULL;comment:'恢复码sha256哈希'"`
+	UsedAt    time.Time `json:"used_at" gorm:"column:used_at;default:NULL;comment:'使用时间, 为空表示未使用'"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;default:CURRENT_TIMESTAMP;comment:'创建时间'"`
+}
+
+func (UserRecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
+
+// 后台两步验证绑定请求体
+type AdminTotpSetupReq struct {
+	MfaToken string `json:"mfa_token"` // 后台登录返回的两步验证凭证
+}
+
+// 后台两步验证请求体, 验证码与恢复码二选一
+type AdminTotpVerifyReq struct {
+	MfaToken     string `json:"mfa_token"`     // 后台登录返回的两步验证凭证
+	Code         string `json:"code"`          // 验证器应用生成的6位验证码
+	RecoveryCode string `json:"recovery_code"` // 恢复码, 验证器丢失时使用
+}
@@ -48,17 +48,31 @@ const (
 )
 
 type User struct {
-	Id        string    `json:"id" gorm:"column:id;primary_key;AUTO_INCREMENT;NOT NULL;comment:'自增唯一ID'"`
-	Name      string    `json:"name" gorm:"column:name;default:NULL;comment:'用户姓名'"`
-	Email     string    `json:"email" gorm:"column:email;NOT NULL;comment:'用户邮箱'"`
-	Password  string    `json:"password" gorm:"column:password;NOT NULL;comment:'用户密码(强加密算法存储, 如bcrypt、scrypt等)'"`
-	AvatarUrl string    `json:"avatar_url" gorm:"column:avatar_url;default:NULL;comment:'用户头像URL'"`
-	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;default:CURRENT_TIMESTAMP;comment:'创建时间'"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;default:NULL ON UPDATE CURRENT_TIMESTAMP;comment:'更新时间'"`
-	Roles     RoleSlice  `json:"roles" gorm:"column:roles;type:varchar(255);default:'user';comment:'用户角色（admin:管理员, user:普通用户，逗号分隔）'"`
-	LastLogin time.Time `json:"last_login" gorm:"column:last_login;default:NULL;comment:'最后登录时间'"`
-	Status    int32     `json:"status" gorm:"column:status;default:1;comment:'用户状态（1:正常, 0:禁用）'"`
-	BannedAt  time.Time `json:"banned_at" gorm:"column:banned_at;default:NULL;comment:'账户锁定时间'"`
+	Id            string    `json:"id" gorm:"column:id;primary_key;AUTO_INCREMENT;NOT NULL;comment:'自增唯一ID'"`
+	Name          string    `json:"name" gorm:"column:name;default:NULL;comment:'用户姓名'"`
+	Email         string    `json:"email" gorm:"column:email;NOT NULL;comment:'用户邮箱'"`
+	Password      string    `json:"password" gorm:"column:password;NOT NULL;comment:'用户密码(强加密算法存储, 如bcrypt、scrypt等)'"`
+	AvatarUrl     string    `json:"avatar_url" gorm:"column:avatar_url;default:NULL;comment:'用户头像URL'"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at;default:CURRENT_TIMESTAMP;comment:'创建时间'"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"column:updated_at;default:NULL ON UPDATE CURRENT_TIMESTAMP;comment:'更新时间'"`
+	Roles         RoleSlice `json:"roles" gorm:"column:roles;type:varchar(255);default:'user';comment:'用户角色（admin:管理员, user:普通用户，逗号分隔）'"`
+	LastLogin     time.Time `json:"last_login" gorm:"column:last_login;default:NULL;comment:'最后登录时间'"`
+	Status        int32     `json:"status" gorm:"column:status;default:1;comment:'用户状态（1:正常, 0:禁用）'"`
+	BannedAt      time.Time `json:"banned_at" gorm:"column:banned_at;default:NULL;comment:'账户锁定时间'"`
+	LoginAttempts int32     `json:"login_attempts" gorm:"column:login_attempts;default:0;comment:'锁定前连续登录失败次数'"`
+	LockedUntil   time.Time `json:"locked_until" gorm:"column:locked_until;default:NULL;comment:'账户临时锁定截止时间'"`
+	TotpSecret    string    `json:"-" gorm:"column:totp_secret;default:NULL;comment:'TOTP密钥（base32）, 为空表示未绑定验证器'"`
+	TotpEnabledAt time.Time `json:"totp_enabled_at" gorm:"column:totp_enabled_at;default:NULL;comment:'TOTP启用时间'"`
+}
+
+// 是否已绑定两步验证
+func (m *User) IsTotpEnabled() bool {
+	return m.TotpSecret != ""
+}
+
+// 账户是否处于临时锁定中
+func (m *User) IsLocked() bool {
+	return m.LockedUntil.After(time.Now())
 }
 
 func (User) TableName() string {
@@ -110,7 +124,7 @@ type UserRegisterReq struct {
 
 // 用户刷新token请求体
 type RefreshTokenReq struct {
-	OldToken string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 // 校验用户邮箱请求体
@@ -130,3 +144,15 @@ type UserResetPasswordReq struct {
 	OldPassword string `json:"old_password"`
 	NewPassword string `json:"new_password"`
 }
+
+// 用户找回密码请求体
+type UserForgotPasswordReq struct {
+	Email string `json:"email"`
+}
+
+// 用户通过邮箱验证码重置密码请求体
+type UserResetPasswordByCodeReq struct {
+	Email       string `json:"email"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
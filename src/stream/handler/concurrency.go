@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"eshop_server/src/common/api"
+	"eshop_server/src/common/cache"
+	"eshop_server/src/router/middleware"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/alarm"
+	"eshop_server/src/utils/config"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/utime"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 获取并发播放限制配置, 未配置项使用默认值
+func getConcurrencyConf() config.ConcurrencyConf {
+	cfg := config.StreamConfig.Concurrency
+	if cfg.MaxStreams == 0 {
+		cfg.MaxStreams = model.ConcurrencyDefaultMaxStreams
+	}
+	if cfg.ActiveWindow <= 0 {
+		cfg.ActiveWindow = model.ConcurrencyDefaultActiveWindow
+	}
+	if cfg.IpWindow <= 0 {
+		cfg.IpWindow = model.ConcurrencyDefaultIpWindow
+	}
+	if cfg.MaxIps == 0 {
+		cfg.MaxIps = model.ConcurrencyDefaultMaxIps
+	}
+	return cfg
+}
+
+// 同时播放会话标识, 按播放设备及客户端ip区分
+// 同一设备切换曲目时签发的新凭证沿用同一会话, 同一凭证在不同ip播放时计为不同会话
+func getPlaybackDeviceSessionId(c *gin.Context, claims *middleware.PlaybackClaims, token string) string {
+	deviceId := claims.DeviceId
+	if deviceId == "" {
+		// 兼容未绑定设备的凭证
+		deviceId = getPlaybackSessionId(claims, token)
+	}
+	return deviceId + "@" + c.ClientIP()
+}
+
+// 校验用户同时播放的会话数, 超出限制时已写入响应
+// 会话在活跃时长内有m3u8或分片请求即视为正在播放, 已在播放中的会话不受限制
+func checkPlaybackConcurrency(c *gin.Context, claims *middleware.PlaybackClaims, token string) error {
+	cfg := getConcurrencyConf()
+	if cfg.MaxStreams < 0 {
+		return nil
+	}
+	sessionId := getPlaybackDeviceSessionId(c, claims, token)
+	sessions, err := cache.GetStreamActiveSessions(claims.UserId, time.Now().Unix()-cfg.ActiveWindow)
+	if err != nil {
+		// redis异常时不影响播放
+		log.Errorf("checkPlaybackConcurrency 获取活跃播放会话失败, user_id:%s, error:%v", claims.UserId, err)
+		return nil
+	}
+
+	playing := false
+	for _, v := range sessions {
+		if v == sessionId {
+			playing = true
+			break
+		}
+	}
+	if !playing && int64(len(sessions)) >= cfg.MaxStreams {
+		log.Errorf("checkPlaybackConcurrency 同时播放会话数超出限制, user_id:%s, session_id:%s, sessions:%v, max_streams:%d", claims.UserId, sessionId, sessions, cfg.MaxStreams)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamConcurrentLimit.Error()).Code, uerrors.Parse(uerrors.ErrorStreamConcurrentLimit.Error()).Detail)
+		return errors.New("同时播放会话数超出限制")
+	}
+
+	if err = cache.SaveStreamActiveSession(claims.UserId, sessionId, cfg.ActiveWindow); err != nil {
+		log.Errorf("checkPlaybackConcurrency 刷新活跃播放会话失败, user_id:%s, session_id:%s, error:%v", claims.UserId, sessionId, err)
+	}
+	return nil
+}
+
+// 账号共享检测, 统计窗口内播放ip数超出限制时飞书告警, 仅告警不拦截
+func detectPlaybackSharing(c *gin.Context, claims *middleware.PlaybackClaims) {
+	cfg := getConcurrencyConf()
+	if cfg.MaxIps < 0 {
+		return
+	}
+	clientIp := c.ClientIP()
+	if err := cache.SaveStreamActiveIp(claims.UserId, clientIp, cfg.IpWindow); err != nil {
+		log.Errorf("detectPlaybackSharing 记录播放ip失败, user_id:%s, ip:%s, error:%v", claims.UserId, clientIp, err)
+		return
+	}
+	ips, err := cache.GetStreamActiveIps(claims.UserId, time.Now().Unix()-cfg.IpWindow)
+	if err != nil {
+		log.Errorf("detectPlaybackSharing 获取播放ip失败, user_id:%s, error:%v", claims.UserId, err)
+		return
+	}
+	if int64(len(ips)) <= cfg.MaxIps || !cache.LockStreamSharingAlert(claims.UserId, cfg.IpWindow) {
+		return
+	}
+
+	log.Errorf("detectPlaybackSharing 疑似账号共享, user_id:%s, player_id:%s, ips:%v, ip_window:%ds", claims.UserId, claims.PlayerId, ips, cfg.IpWindow)
+	go func(userId string, playerId string, ips []string) {
+		if err := alarm.PostFeiShu(
+			alarm.AlarmLevelWarn, config.CommonConfig.LarkAlarm.ErrorBotWebhook,
+			fmt.Sprintf("[JXS流媒体服务] 疑似账号共享 \n\t 环境:%s \n\t用户id:%s \n\t播放id:%s \n\t%d秒内播放ip数:%d \n\tip列表:%s \n\t通知时间:%s",
+				config.CommonConfig.Env, userId, playerId, cfg.IpWindow, len(ips), strings.Join(ips, ","), utime.TimeToStr(utime.GetNow())),
+		); err != nil {
+			log.Errorf("detectPlaybackSharing 飞书通知失败, user_id:%s, err:%v", userId, err)
+		}
+	}(claims.UserId, claims.PlayerId, ips)
+}
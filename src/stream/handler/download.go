@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"eshop_server/src/common/api"
+	"eshop_server/src/common/cache"
+	router_dao "eshop_server/src/router/dao"
+	router_model "eshop_server/src/router/model"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/config"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 计算音频水印编码, 由用户id及购买记录id确定, 可据此反查泄露文件的购买记录
+func getDownloadWatermarkCode(userId string, purchaseId int64) uint32 {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", userId, purchaseId)))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// 生成音频水印滤镜
+// 在载波频率叠加低电平正弦波, 按位时长循环输出水印编码的各位, 位为1时有载波, 为0时静音
+func getDownloadWatermarkFilter(code uint32) string {
+	bit := fmt.Sprintf("mod(floor(%d/pow(2\\,mod(floor(t/%g)\\,%d)))\\,2)", code, model.DownloadWatermarkBitDuration, model.DownloadWatermarkBits)
+	return fmt.Sprintf("aeval=exprs=val(ch)+%g*sin(2*PI*%d*t)*%s:c=same", model.DownloadWatermarkAmplitude, model.DownloadWatermarkFrequency, bit)
+}
+
+// 生成下载文件, 写入购买用户信息并按配置嵌入音频水印
+// @return dstFile 本地下载文件路径, 调用方负责清理
+func generateDownloadFile(ticket *router_model.DownloadTicket, player *router_model.ProductsPlayer) (dstFile string, err error) {
+	// 源文件不在本节点时从存储下载到本次下载独占的临时文件, 避免同一曲目并发下载时互相删除
+	srcKey := storage.Key(model.StreamFileUploadPath, player.Id+player.FileType)
+	srcFile := filepath.Join(model.StreamFileUploadPath, path.Base(srcKey))
+	if !storage.IsLocal(storage.Default) {
+		srcFile = filepath.Join(model.StreamFileDownloadPath, ticket.TicketId+".src"+player.FileType)
+		defer removeLocalFiles(srcFile)
+	}
+	if err = storage.FetchFile(storage.Default, srcKey, srcFile); err != nil {
+		return "", fmt.Errorf("获取源文件失败: %w", err)
+	}
+
+	// 编码参数, 无水印且格式与源文件一致时直接复制音频流
+	audioFilter := ""
+	codecArgs := model.DownloadCodecArgsMap[ticket.Format]
+	if config.StreamConfig.Download.Watermark {
+		code := getDownloadWatermarkCode(ticket.UserId, ticket.PurchaseId)
+		audioFilter = getDownloadWatermarkFilter(code)
+		log.Infof("generateDownloadFile 嵌入音频水印, ticket_id: %s, purchase_id: %d, code: %08x", ticket.TicketId, ticket.PurchaseId, code)
+	} else if "."+ticket.Format == strings.ToLower(player.FileType) {
+		codecArgs = []string{"-c:a", "copy"}
+	}
+	if codecArgs == nil {
+		return "", fmt.Errorf("不支持的下载格式: %s", ticket.Format)
+	}
+
+	metadata := map[string]string{
+		"title":       player.TrackViewFormat().Title,
+		"comment":     fmt.Sprintf("Licensed to %s, purchase %d", ticket.UserId, ticket.PurchaseId),
+		"buyer_id":    ticket.UserId,
+		"purchase_id": fmt.Sprintf("%d", ticket.PurchaseId),
+	}
+	dstFile = filepath.Join(model.StreamFileDownloadPath, ticket.TicketId+"."+ticket.Format)
+	if err = GenerateDownloadFile(srcFile, dstFile, codecArgs, audioFilter, metadata); err != nil {
+		removeLocalFiles(dstFile)
+		return "", err
+	}
+	return dstFile, nil
+}
+
+// @Title		 购买下载
+// @Description  消费一次性下载凭证, 生成内嵌购买用户信息的下载文件, 计入购买记录的下载次数
+// @Response     file
+// @Router       /v1/steaming/download/:ticket_id [get]
+func StreamingDownload(c *gin.Context) {
+	var err error
+
+	// 下载凭证只能使用一次
+	ticketId := c.Param("ticket_id")
+	ok, b := cache.TakeStreamDownload(ticketId)
+	if !ok {
+		log.Errorf("StreamingDownload 下载凭证不存在或已使用, ticket_id: %s", ticketId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamDownloadNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamDownloadNotFound.Error()).Detail)
+		return
+	}
+	var ticket router_model.DownloadTicket
+	if err = json.Unmarshal(b, &ticket); err != nil {
+		log.Errorf("StreamingDownload 解析下载凭证失败, ticket_id: %s, error: %v", ticketId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamDownloadNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamDownloadNotFound.Error()).Detail)
+		return
+	}
+	log.Infof("StreamingDownload 请求参数, ticket: %+v", ticket)
+
+	player, err := router_dao.GetProductsPlayerById(ticket.PlayerId)
+	if err != nil || player.Status != router_model.ProductsPlayerStatusOk {
+		log.Errorf("StreamingDownload 播放资源不存在或不可下载, player_id: %s, error: %v", ticket.PlayerId, err)
+		api.FailWithFileNotFound(c)
+		return
+	}
+
+	// 计入下载次数, 并发签发的下载凭证在此处按上限拦截
+	maxDownloads := config.StreamConfig.Download.MaxDownloads
+	if maxDownloads == 0 {
+		maxDownloads = router_model.DownloadDefaultMaxDownloads
+	}
+	if ok, err = router_dao.IncrPurchaseHistoryDownloadCount(ticket.PurchaseId, maxDownloads); err != nil || !ok {
+		log.Errorf("StreamingDownload 下载次数已达上限或更新失败, purchase_id: %d, error: %v", ticket.PurchaseId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamDownloadLimit.Error()).Code, uerrors.Parse(uerrors.ErrorStreamDownloadLimit.Error()).Detail)
+		return
+	}
+
+	dstFile, err := generateDownloadFile(&ticket, player)
+	if err != nil {
+		log.Errorf("StreamingDownload 生成下载文件失败, ticket: %+v, error: %v", ticket, err)
+		// 生成失败不计入下载次数
+		if decrErr := router_dao.DecrPurchaseHistoryDownloadCount(ticket.PurchaseId); decrErr != nil {
+			log.Errorf("StreamingDownload 回退下载次数失败, purchase_id: %d, error: %v", ticket.PurchaseId, decrErr)
+		}
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileStreamingFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileStreamingFailed.Error()).Detail)
+		return
+	}
+	defer removeLocalFiles(dstFile)
+
+	log.Infof("StreamingDownload 下载文件生成成功, ticket_id: %s, user_id: %s, player_id: %s, format: %s", ticket.TicketId, ticket.UserId, ticket.PlayerId, ticket.Format)
+	c.Header("Cache-Control", "no-store")
+	c.FileAttachment(dstFile, player.TrackViewFormat().Title+"."+ticket.Format)
+}
@@ -5,35 +5,142 @@ import (
 	// "fmt"
 	"bytes"
 	"context"
+	"encoding/json"
+	"eshop_server/src/stream/model"
 	"eshop_server/src/utils/log"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// GenerateAudioM3u8 生成m3u8文件
+// GenerateAudioAacM3u8 按指定码率转码为AAC并生成加密的m3u8文件
 // @param srcFile 源文件路径
 // @param dstFile 目标文件路径
-func GenerateAudioM3u8(srcFile string, dstFile string) error {
-	log.Infof("generateM3U8 解析音频文件, srcFile: %s, dstFile: %s\n", srcFile, dstFile)
+// @param keyInfoFile AES-128密钥信息文件路径, 分片加密输出
+// @param bitrate 码率（kbps）
+// @param audioFilter 音频滤镜, 如loudnorm响度标准化, 为空时不处理
+func GenerateAudioAacM3u8(srcFile string, dstFile string, keyInfoFile string, bitrate int64, audioFilter string) error {
+	log.Infof("GenerateAudioAacM3u8 解析音频文件, srcFile: %s, dstFile: %s, keyInfoFile: %s, bitrate: %dk, audioFilter: %s\n", srcFile, dstFile, keyInfoFile, bitrate, audioFilter)
 	// 判断srcFile文件是否存在
 	if _, err := os.Stat(srcFile); os.IsNotExist(err) {
 		return err
 	}
+	// 判断keyInfoFile文件是否存在, 禁止输出未加密分片
+	if _, err := os.Stat(keyInfoFile); os.IsNotExist(err) {
+		return err
+	}
+
+	// 使用ffmpeg将音频文件转码并切分成加密的.ts文件，并生成m3u8文件
+	// 示例命令：ffmpeg -i src -vn -c:a aac -b:a 128k -start_number 0 -hls_time 10 -hls_list_size 0 -hls_key_info_file ./keys/output.keyinfo -hls_segment_filename ./segments/output_128k_%d.ts -f hls ./segments/output_128k.m3u8
+	segmentFile := strings.TrimSuffix(dstFile, filepath.Ext(dstFile)) + "_%d.ts"
+	cmd := exec.Command(
+		"ffmpeg",
+		"-i", srcFile, // 输入文件
+		"-vn",          // 忽略封面等视频流
+		"-af", withAudioFilter(audioFilter), // 音频滤镜
+		"-c:a", "aac", // aac编码
+		"-b:a", fmt.Sprintf("%dk", bitrate), // 码率
+		"-start_number", "0", // 分片文件编号从0开始
+		"-hls_time", "10", // 每个分片文件的时间长度为10秒, 便于码率切换
+		"-hls_list_size", "0", // 不限制分片文件数量
+		"-hls_key_info_file", keyInfoFile, // AES-128加密分片
+		"-hls_segment_filename", segmentFile, // 分片文件名
+		"-f", "hls", // 输出格式为m3u8
+		dstFile,
+	)
+	return cmd.Run()
+}
+
+// GenerateAudioLosslessM3u8 转码为无损音轨（fmp4分片）并生成加密的m3u8文件
+// @param srcFile 源文件路径
+// @param dstFile 目标文件路径
+// @param keyInfoFile AES-128密钥信息文件路径, 分片加密输出
+// @param codec 无损编码 flac/alac
+// @param audioFilter 音频滤镜, 如loudnorm响度标准化, 为空时不处理
+func GenerateAudioLosslessM3u8(srcFile string, dstFile string, keyInfoFile string, codec string, audioFilter string) error {
+	log.Infof("GenerateAudioLosslessM3u8 解析音频文件, srcFile: %s, dstFile: %s, keyInfoFile: %s, codec: %s, audioFilter: %s\n", srcFile, dstFile, keyInfoFile, codec, audioFilter)
+	// 判断srcFile文件是否存在
+	if _, err := os.Stat(srcFile); os.IsNotExist(err) {
+		return err
+	}
+	// 判断keyInfoFile文件是否存在, 禁止输出未加密分片
+	if _, err := os.Stat(keyInfoFile); os.IsNotExist(err) {
+		return err
+	}
 
-	// 使用ffmpeg将音频文件切分成.ts文件，并生成m3u8文件
-	// 示例命令：ffmpeg -i src -codec: copy -start_number 0 -hls_time 30 -hls_list_size 0 -f hls ./segments/output.m3u8
-	log.Infof("generateM3U8 执行ffmpeg命令: ffmpeg -i %s -codec: copy -start_number 0 -hls_time 30 -hls_list_size 0 -f hls %s\n", srcFile, dstFile)
+	// flac/alac无法封装为mpegts, 使用fmp4分片
+	// 示例命令：ffmpeg -i src -vn -c:a flac -strict -2 -start_number 0 -hls_time 10 -hls_list_size 0 -hls_segment_type fmp4 -hls_fmp4_init_filename output_lossless_init.mp4 -hls_key_info_file ./keys/output.keyinfo -hls_segment_filename ./segments/output_lossless_%d.m4s -f hls ./segments/output_lossless.m3u8
+	basename := strings.TrimSuffix(dstFile, filepath.Ext(dstFile))
 	cmd := exec.Command(
 		"ffmpeg",
 		"-i", srcFile, // 输入文件
-		"-codec:", "copy", // 使用原始编码
+		"-vn",          // 忽略封面等视频流
+		"-af", withAudioFilter(audioFilter), // 音频滤镜
+		"-c:a", codec, // 无损编码
+		"-strict", "-2", // 允许mp4封装flac
 		"-start_number", "0", // 分片文件编号从0开始
-		"-hls_time", "30", // 每个分片文件的时间长度为30秒
+		"-hls_time", "10", // 每个分片文件的时间长度为10秒
 		"-hls_list_size", "0", // 不限制分片文件数量
+		"-hls_segment_type", "fmp4", // fmp4分片
+		"-hls_fmp4_init_filename", filepath.Base(basename)+"_init.mp4", // 初始化分片, 与m3u8同目录
+		"-hls_key_info_file", keyInfoFile, // AES-128加密分片
+		"-hls_segment_filename", basename+"_%d.m4s", // 分片文件名
+		"-f", "hls", // 输出格式为m3u8
+		dstFile,
+	)
+	return cmd.Run()
+}
+
+// 音频滤镜为空时使用直通滤镜anull, 保持命令参数一致
+func withAudioFilter(audioFilter string) string {
+	if audioFilter == "" {
+		return "anull"
+	}
+	return audioFilter
+}
+
+// GenerateAudioPreviewM3u8 截取试听片段并生成m3u8文件（不加密，公开播放）
+// @param srcFile 源文件路径
+// @param dstFile 目标文件路径
+// @param offset 截取起始位置（秒）
+// @param duration 截取时长（秒）
+// @param fadeOut 淡出时长（秒）
+// @param audioFilter 淡出前的音频滤镜, 如loudnorm响度标准化, 为空时不处理
+func GenerateAudioPreviewM3u8(srcFile string, dstFile string, offset int64, duration int64, fadeOut int64, audioFilter string) error {
+	log.Infof("GenerateAudioPreviewM3u8 截取试听片段, srcFile: %s, dstFile: %s, offset: %d, duration: %d, fadeOut: %d\n", srcFile, dstFile, offset, duration, fadeOut)
+	// 判断srcFile文件是否存在
+	if _, err := os.Stat(srcFile); os.IsNotExist(err) {
+		return err
+	}
+	if fadeOut > duration {
+		fadeOut = duration
+	}
+
+	// 淡出需要重新编码，统一输出aac
+	// 示例命令：ffmpeg -ss 0 -t 30 -i src -af afade=t=out:st=27:d=3 -c:a aac -b:a 128k -start_number 0 -hls_time 10 -hls_list_size 0 -hls_segment_filename ./previews/output_%d.ts -f hls ./previews/output.m3u8
+	segmentFile := strings.TrimSuffix(dstFile, filepath.Ext(dstFile)) + "_%d.ts"
+	fadeFilter := fmt.Sprintf("afade=t=out:st=%d:d=%d", duration-fadeOut, fadeOut)
+	if audioFilter != "" {
+		fadeFilter = audioFilter + "," + fadeFilter
+	}
+	cmd := exec.Command(
+		"ffmpeg",
+		"-ss", strconv.FormatInt(offset, 10), // 起始位置
+		"-t", strconv.FormatInt(duration, 10), // 截取时长
+		"-i", srcFile, // 输入文件
+		"-af", fadeFilter, // 淡出
+		"-c:a", "aac", // aac编码
+		"-b:a", "128k", // 码率
+		"-start_number", "0", // 分片文件编号从0开始
+		"-hls_time", "10", // 每个分片文件的时间长度为10秒
+		"-hls_list_size", "0", // 不限制分片文件数量
+		"-hls_segment_filename", segmentFile, // 分片文件名
 		"-f", "hls", // 输出格式为m3u8
 		dstFile,
 	)
@@ -77,3 +184,157 @@ func GetMediaDuration(filePath string) (int64, error) {
     } 
     return int64(duration), nil 
 }
+
+var (
+	// ebur128 Summary中的响度数值, 如 "I:         -19.4 LUFS"
+	ebur128SummaryRegexp = regexp.MustCompile(`(?m)^\s*(I|LRA|Peak):\s+(-?[\d.]+|-inf)\s+(LUFS|LU|dBFS)`)
+)
+
+// GetMediaProbe 通过调用 ffprobe 获取音频文件的封装、流及标签信息
+// 标签包括mp3的ID3及wav的RIFF INFO, 由ffprobe统一转换为小写key（title/artist/album...）
+func GetMediaProbe(filePath string) (*model.MediaProbe, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 示例命令：ffprobe -v error -print_format json -show_format -show_streams src
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-print_format", "json", "-show_format", "-show_streams", filePath)
+	stdout, err := cmd.Output()
+	if err != nil {
+		if exiterr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("GetMediaProbe ffprobe execution failed: %s, stderr: %s", exiterr.Error(), string(exiterr.Stderr))
+		}
+		return nil, fmt.Errorf("GetMediaProbe failed to execute ffprobe: %v", err)
+	}
+
+	probe := new(model.MediaProbe)
+	if err = json.Unmarshal(stdout, probe); err != nil {
+		return nil, fmt.Errorf("GetMediaProbe failed to parse output: %v", err)
+	}
+	return probe, nil
+}
+
+// GetMediaLoudness 通过 ffmpeg ebur128 滤镜测量EBU R128响度, 需解码整个文件
+func GetMediaLoudness(filePath string) (*model.MediaLoudness, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	// 示例命令：ffmpeg -hide_banner -nostats -i src -vn -af ebur128=peak=true:framelog=verbose -f null -
+	// 测量结果输出在stderr的Summary部分
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-nostats", "-i", filePath, "-vn", "-af", "ebur128=peak=true:framelog=verbose", "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("GetMediaLoudness ffmpeg execution failed: %v", err)
+	}
+	output := stderr.String()
+	idx := strings.LastIndex(output, "Summary:")
+	if idx < 0 {
+		return nil, fmt.Errorf("GetMediaLoudness summary not found")
+	}
+
+	res := new(model.MediaLoudness)
+	found := 0
+	for _, m := range ebur128SummaryRegexp.FindAllStringSubmatch(output[idx:], -1) {
+		value, err := strconv.ParseFloat(m[2], 64)
+		if err != nil || math.IsInf(value, 0) {
+			continue // -inf 静音文件
+		}
+		switch m[1] {
+		case "I":
+			res.IntegratedLoudness = value
+		case "LRA":
+			res.LoudnessRange = value
+		case "Peak":
+			res.TruePeak = value
+		}
+		found++
+	}
+	if found == 0 {
+		return nil, fmt.Errorf("GetMediaLoudness failed to parse summary: %s", output[idx:])
+	}
+	return res, nil
+}
+
+// ExtractCoverArt 导出音频文件内嵌封面（attached_pic）
+// @param srcFile 源文件路径
+// @param dstFile 目标文件路径, 后缀需与封面编码一致（.jpg/.png）
+// @param streamIndex 封面流序号
+func ExtractCoverArt(srcFile string, dstFile string, streamIndex int64) error {
+	log.Infof("ExtractCoverArt 导出内嵌封面, srcFile: %s, dstFile: %s, streamIndex: %d\n", srcFile, dstFile, streamIndex)
+	// 示例命令：ffmpeg -y -i src -map 0:1 -c copy -frames:v 1 ./covers/output_cover.jpg
+	cmd := exec.Command(
+		"ffmpeg",
+		"-y",
+		"-i", srcFile, // 输入文件
+		"-map", fmt.Sprintf("0:%d", streamIndex), // 封面流
+		"-c", "copy", // 不重新编码
+		"-frames:v", "1", // 仅输出一帧
+		dstFile,
+	)
+	return cmd.Run()
+}
+
+// GetLoudnormMeasurement loudnorm第一遍测量, 返回第二遍所需的输入响度参数
+// @param filePath 源文件路径
+// @param target 目标综合响度（LUFS）
+// @param truePeak 真峰值上限（dBTP）
+// @param lra 目标响度范围（LU）
+func GetLoudnormMeasurement(filePath string, target float64, truePeak float64, lra float64) (*model.LoudnormMeasurement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	// 示例命令：ffmpeg -hide_banner -nostats -i src -vn -af loudnorm=I=-16:TP=-1.5:LRA=11:print_format=json -f null -
+	// 测量结果以json格式输出在stderr末尾
+	audioFilter := fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g:print_format=json", target, truePeak, lra)
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-nostats", "-i", filePath, "-vn", "-af", audioFilter, "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("GetLoudnormMeasurement ffmpeg execution failed: %v", err)
+	}
+	output := stderr.String()
+	start, end := strings.LastIndex(output, "{"), strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("GetLoudnormMeasurement json not found")
+	}
+
+	res := new(model.LoudnormMeasurement)
+	if err := json.Unmarshal([]byte(output[start:end+1]), res); err != nil {
+		return nil, fmt.Errorf("GetLoudnormMeasurement failed to parse output: %v", err)
+	}
+	return res, nil
+}
+
+// GenerateDownloadFile 生成购买下载文件, 写入购买用户元数据
+// @param srcFile 源文件路径
+// @param dstFile 目标文件路径, 后缀决定封装格式
+// @param codecArgs 编码参数, 如["-c:a", "copy"]
+// @param audioFilter 音频滤镜, 如音频水印, 为空时不处理; 直接复制音频流时须为空
+// @param metadata 写入的元数据, mp3为ID3v2标签, wav为RIFF INFO（仅支持title/comment等标准字段）, flac为vorbis comment
+func GenerateDownloadFile(srcFile string, dstFile string, codecArgs []string, audioFilter string, metadata map[string]string) error {
+	log.Infof("GenerateDownloadFile 生成下载文件, srcFile: %s, dstFile: %s, codecArgs: %v, audioFilter: %s\n", srcFile, dstFile, codecArgs, audioFilter)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	// 示例命令：ffmpeg -y -i src -map 0:a:0 -map_metadata 0 -af aeval=... -c:a libmp3lame -b:a 320k -id3v2_version 3 -metadata comment=... ./downloads/output.mp3
+	args := []string{
+		"-y",
+		"-i", srcFile, // 输入文件
+		"-map", "0:a:0", // 仅输出音频流, 忽略封面
+		"-map_metadata", "0", // 保留源文件标签
+	}
+	if audioFilter != "" {
+		args = append(args, "-af", audioFilter)
+	}
+	args = append(args, codecArgs...)
+	for k, v := range metadata {
+		args = append(args, "-metadata", k+"="+v)
+	}
+	args = append(args, dstFile)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("GenerateDownloadFile ffmpeg execution failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	router_model "eshop_server/src/router/model"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/storage"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	HlsTsPacketSize = 188  // mpegts包大小
+	HlsTsSyncByte   = 0x47 // mpegts包同步字节
+)
+
+var (
+	hlsFmp4BoxTypeList = []string{"styp", "moof", "sidx", "emsg", "prft"} // fmp4分片允许的首个box类型
+)
+
+// HLS音轨m3u8中的加密信息
+type hlsAuditKey struct {
+	method string // 加密方式 NONE/AES-128
+	iv     []byte // IV, 为空时使用分片序号
+}
+
+// AuditHlsPlayer 校验player的master及音轨m3u8, 确认每个分片存在且可解码
+// @return issues 发现的问题, 为空时表示完整可播放
+// @return err 存储读取失败等临时错误, 此时无法判定player是否损坏
+func AuditHlsPlayer(player *router_model.ProductsPlayer) (issues []string, err error) {
+	// 读取AES-128密钥
+	var key []byte
+	if player.KeyFile != "" {
+		key, err = storage.ReadAll(storage.Default, storage.Key(model.StreamFileKeyPath, filepath.Base(player.KeyFile)))
+		if err == storage.ErrNotExist {
+			return append(issues, fmt.Sprintf("密钥文件%s不存在", player.KeyFile)), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("密钥文件%s读取失败: %w", player.KeyFile, err)
+		}
+	}
+
+	masters := []string{player.Id + ".m3u8"}
+	if player.HifiUrl != "" {
+		masters = append(masters, path.Base(player.HifiUrl))
+	}
+	audited := make(map[string]bool)
+	for _, master := range masters {
+		content, err := storage.ReadAll(storage.Default, storage.Key(model.StreamFileSegmentPath, master))
+		if err == storage.ErrNotExist {
+			issues = append(issues, fmt.Sprintf("%s不存在", master))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s读取失败: %w", master, err)
+		}
+		variants := parseHlsPlaylistUris(content)
+		if len(variants) == 0 {
+			issues = append(issues, fmt.Sprintf("%s无音轨", master))
+			continue
+		}
+		for _, variant := range variants {
+			variant = path.Base(variant)
+			if audited[variant] {
+				continue
+			}
+			audited[variant] = true
+			variantIssues, err := auditHlsMediaPlaylist(variant, key)
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, variantIssues...)
+		}
+	}
+	return issues, nil
+}
+
+// 解析m3u8中的uri行（音轨m3u8或分片文件）, 忽略注释及标签
+func parseHlsPlaylistUris(content []byte) (uris []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		uris = append(uris, line)
+	}
+	return uris
+}
+
+// 获取标签属性值, 如#EXT-X-KEY:METHOD=AES-128,URI="...",IV=0x...
+func getHlsTagAttribute(line string, name string) string {
+	attrs := line[strings.Index(line, ":")+1:]
+	for _, attr := range strings.Split(attrs, ",") {
+		kv := strings.SplitN(attr, "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == name {
+			return strings.Trim(strings.TrimSpace(kv[1]), "\"")
+		}
+	}
+	return ""
+}
+
+// 校验音轨m3u8及其分片, 存储临时错误时返回err
+func auditHlsMediaPlaylist(playlist string, key []byte) (issues []string, err error) {
+	content, err := storage.ReadAll(storage.Default, storage.Key(model.StreamFileSegmentPath, playlist))
+	if err == storage.ErrNotExist {
+		return append(issues, fmt.Sprintf("%s不存在", playlist)), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s读取失败: %w", playlist, err)
+	}
+	if !bytes.HasPrefix(content, []byte("#EXTM3U")) {
+		return append(issues, fmt.Sprintf("%s格式错误", playlist)), nil
+	}
+	if !bytes.Contains(content, []byte("#EXT-X-ENDLIST")) {
+		issues = append(issues, fmt.Sprintf("%s不完整, 缺失#EXT-X-ENDLIST", playlist))
+	}
+
+	var sequence uint64
+	var segments int
+	auditKey := &hlsAuditKey{method: "NONE"}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, _ = strconv.ParseUint(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			auditKey = &hlsAuditKey{method: getHlsTagAttribute(line, "METHOD")}
+			if iv := getHlsTagAttribute(line, "IV"); iv != "" {
+				auditKey.iv, _ = hex.DecodeString(strings.TrimPrefix(strings.ToLower(iv), "0x"))
+			}
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			// fmp4初始化分片不加密, 仅校验存在
+			initSegment := path.Base(getHlsTagAttribute(line, "URI"))
+			info, statErr := storage.Default.Stat(storage.Key(model.StreamFileSegmentPath, initSegment))
+			if statErr != nil && statErr != storage.ErrNotExist {
+				return nil, fmt.Errorf("初始化分片%s读取失败: %w", initSegment, statErr)
+			}
+			if statErr == storage.ErrNotExist || info.Size == 0 {
+				issues = append(issues, fmt.Sprintf("初始化分片%s缺失或为空", initSegment))
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			segment := path.Base(line)
+			data, readErr := storage.ReadAll(storage.Default, storage.Key(model.StreamFileSegmentPath, segment))
+			if readErr != nil && readErr != storage.ErrNotExist {
+				return nil, fmt.Errorf("分片%s读取失败: %w", segment, readErr)
+			}
+			if readErr == storage.ErrNotExist {
+				issues = append(issues, fmt.Sprintf("分片%s: 不存在", segment))
+			} else if auditErr := auditHlsSegment(segment, data, auditKey, key, sequence); auditErr != nil {
+				issues = append(issues, fmt.Sprintf("分片%s: %v", segment, auditErr))
+			}
+			segments++
+			sequence++
+		}
+	}
+	if segments == 0 {
+		issues = append(issues, fmt.Sprintf("%s无分片", playlist))
+	}
+	return issues, nil
+}
+
+// 校验单个分片非空且可解码: 解密后mpegts分片每个包以同步字节开头, fmp4分片以合法box开头
+func auditHlsSegment(segment string, data []byte, auditKey *hlsAuditKey, key []byte, sequence uint64) (err error) {
+	if len(data) == 0 {
+		return fmt.Errorf("文件为空")
+	}
+
+	if auditKey.method == "AES-128" {
+		iv := auditKey.iv
+		if len(iv) == 0 {
+			// 未指定IV时以分片序号作为IV
+			iv = make([]byte, aes.BlockSize)
+			binary.BigEndian.PutUint64(iv[aes.BlockSize-8:], sequence)
+		}
+		if data, err = decryptHlsSegment(data, key, iv); err != nil {
+			return err
+		}
+	}
+
+	switch strings.ToLower(path.Ext(segment)) {
+	case ".ts":
+		if len(data)%HlsTsPacketSize != 0 {
+			return fmt.Errorf("mpegts长度%d不是%d的整数倍", len(data), HlsTsPacketSize)
+		}
+		for i := 0; i < len(data); i += HlsTsPacketSize {
+			if data[i] != HlsTsSyncByte {
+				return fmt.Errorf("mpegts同步字节错误, offset: %d", i)
+			}
+		}
+	case ".m4s":
+		if len(data) < 8 || binary.BigEndian.Uint32(data[:4]) < 8 {
+			return fmt.Errorf("fmp4 box长度错误")
+		}
+		boxType := string(data[4:8])
+		valid := false
+		for _, t := range hlsFmp4BoxTypeList {
+			if boxType == t {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("fmp4首个box类型错误: %q", boxType)
+		}
+	}
+	return nil
+}
+
+// AES-128-CBC解密分片并去除PKCS7填充
+func decryptHlsSegment(data []byte, key []byte, iv []byte) ([]byte, error) {
+	if len(key) != HlsKeyLength || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("密钥或IV长度错误")
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("密文长度%d不是%d的整数倍", len(data), aes.BlockSize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, fmt.Errorf("解密失败, 填充错误")
+	}
+	for _, b := range plain[len(plain)-padding:] {
+		if int(b) != padding {
+			return nil, fmt.Errorf("解密失败, 填充错误")
+		}
+	}
+	return plain[:len(plain)-padding], nil
+}
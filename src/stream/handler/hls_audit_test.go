@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+func TestDecryptHlsSegment(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, HlsKeyLength)
+	iv := bytes.Repeat([]byte{0x02}, aes.BlockSize)
+	plain := bytes.Repeat([]byte{HlsTsSyncByte}, HlsTsPacketSize*2)
+
+	// PKCS7填充后加密
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, _ := aes.NewCipher(key)
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	decrypted, err := decryptHlsSegment(encrypted, key, iv)
+	if err != nil || !bytes.Equal(decrypted, plain) {
+		t.Fatalf("unexpected decrypt result, err: %v, len: %d", err, len(decrypted))
+	}
+
+	// 密钥错误时填充校验失败
+	wrongKey := bytes.Repeat([]byte{0x03}, HlsKeyLength)
+	if _, err = decryptHlsSegment(encrypted, wrongKey, iv); err == nil {
+		t.Fatalf("expected padding error with wrong key")
+	}
+	if _, err = decryptHlsSegment(encrypted[:len(encrypted)-1], key, iv); err == nil {
+		t.Fatalf("expected error for truncated segment")
+	}
+}
+
+func TestGetHlsTagAttribute(t *testing.T) {
+	line := `#EXT-X-KEY:METHOD=AES-128,URI="../key/abc",IV=0x0123456789abcdef0123456789abcdef`
+	if got := getHlsTagAttribute(line, "METHOD"); got != "AES-128" {
+		t.Fatalf("unexpected METHOD: %s", got)
+	}
+	if got := getHlsTagAttribute(line, "URI"); got != "../key/abc" {
+		t.Fatalf("unexpected URI: %s", got)
+	}
+	if got := getHlsTagAttribute(line, "IV"); got != "0x0123456789abcdef0123456789abcdef" {
+		t.Fatalf("unexpected IV: %s", got)
+	}
+
+	uris := parseHlsPlaylistUris([]byte("#EXTM3U\n#EXTINF:10.0,\na_128k_0.ts\n\n#EXTINF:5.0,\na_128k_1.ts\n#EXT-X-ENDLIST\n"))
+	if len(uris) != 2 || uris[0] != "a_128k_0.ts" || uris[1] != "a_128k_1.ts" {
+		t.Fatalf("unexpected uris: %v", uris)
+	}
+}
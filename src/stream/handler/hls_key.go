@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"eshop_server/src/stream/model"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	HlsKeyLength  = 16         // AES-128密钥长度
+	HlsKeyFileExt = ".key"     // 密钥文件后缀
+	HlsKeyInfoExt = ".keyinfo" // ffmpeg密钥信息文件后缀
+)
+
+// GetHlsKeyFile 获取player的密钥文件路径
+func GetHlsKeyFile(playerId string) string {
+	return filepath.Join(model.StreamFileKeyPath, playerId+HlsKeyFileExt)
+}
+
+// GetHlsKeyInfoFile 获取player的ffmpeg密钥信息文件路径
+func GetHlsKeyInfoFile(playerId string) string {
+	return filepath.Join(model.StreamFileKeyPath, playerId+HlsKeyInfoExt)
+}
+
+// GetHlsKeyUri 获取写入m3u8的密钥地址
+// 相对于 /v1/steaming/player/{player_id}.m3u8 解析为 /v1/steaming/key/{player_id}
+func GetHlsKeyUri(playerId string) string {
+	return "../key/" + playerId
+}
+
+// GenerateHlsKey 生成player的AES-128密钥及ffmpeg密钥信息文件
+// @param playerId 播放id
+// @return keyFile 密钥文件名, keyInfoFile 密钥信息文件路径
+func GenerateHlsKey(playerId string) (keyFile string, keyInfoFile string, err error) {
+	key := make([]byte, HlsKeyLength)
+	if _, err = rand.Read(key); err != nil {
+		return "", "", fmt.Errorf("GenerateHlsKey 生成密钥失败: %v", err)
+	}
+	iv := make([]byte, HlsKeyLength)
+	if _, err = rand.Read(iv); err != nil {
+		return "", "", fmt.Errorf("GenerateHlsKey 生成IV失败: %v", err)
+	}
+
+	// 保存密钥文件
+	keyPath := GetHlsKeyFile(playerId)
+	if err = os.WriteFile(keyPath, key, 0600); err != nil {
+		return "", "", fmt.Errorf("GenerateHlsKey 保存密钥文件失败: %v", err)
+	}
+
+	// 保存密钥信息文件, 格式: 密钥URI / 密钥文件路径 / IV
+	// https://ffmpeg.org/ffmpeg-formats.html#hls-2
+	keyInfoFile = GetHlsKeyInfoFile(playerId)
+	keyInfo := fmt.Sprintf("%s\n%s\n%s\n", GetHlsKeyUri(playerId), keyPath, hex.EncodeToString(iv))
+	if err = os.WriteFile(keyInfoFile, []byte(keyInfo), 0600); err != nil {
+		return "", "", fmt.Errorf("GenerateHlsKey 保存密钥信息文件失败: %v", err)
+	}
+	return filepath.Base(keyPath), keyInfoFile, nil
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"eshop_server/src/utils/config"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	LoudnormDefaultTarget     float64 = -16   // 默认目标综合响度（LUFS）
+	LoudnormDefaultTruePeak   float64 = -1.5  // 默认真峰值上限（dBTP）
+	LoudnormDefaultLra        float64 = 11    // 默认目标响度范围（LU）
+	LoudnormDefaultSampleRate int64   = 48000 // 源采样率未知时的输出采样率（Hz）
+)
+
+// GetLoudnormFilter 根据配置对源文件进行loudnorm第一遍测量, 返回第二遍使用的音频滤镜
+// 未开启响度标准化时返回空; loudnorm内部会升采样至192kHz, 需重采样回源采样率
+// @param srcFile 源文件路径
+// @param sampleRate 源采样率（Hz）, 为0时使用默认值
+func GetLoudnormFilter(srcFile string, sampleRate int64) (string, error) {
+	cfg := config.StreamConfig.Loudnorm
+	if !cfg.Enable {
+		return "", nil
+	}
+	target := cfg.Target
+	if target == 0 {
+		target = LoudnormDefaultTarget
+	}
+	truePeak := cfg.TruePeak
+	if truePeak == 0 {
+		truePeak = LoudnormDefaultTruePeak
+	}
+	lra := cfg.Lra
+	if lra == 0 {
+		lra = LoudnormDefaultLra
+	}
+	if sampleRate <= 0 {
+		sampleRate = LoudnormDefaultSampleRate
+	}
+
+	measured, err := GetLoudnormMeasurement(srcFile, target, truePeak, lra)
+	if err != nil {
+		return "", err
+	}
+	// 静音等无法测量的文件不做标准化
+	for _, v := range []string{measured.InputI, measured.InputTp, measured.InputLra, measured.InputThresh, measured.TargetOffset} {
+		if f, parseErr := strconv.ParseFloat(v, 64); parseErr != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("GetLoudnormFilter 测量结果无效: %+v", measured)
+		}
+	}
+
+	// linear=true 在满足真峰值限制时仅做线性增益, 否则回退为动态标准化
+	return fmt.Sprintf(
+		"loudnorm=I=%g:TP=%g:LRA=%g:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true,aresample=%d",
+		target, truePeak, lra,
+		measured.InputI, measured.InputTp, measured.InputLra, measured.InputThresh, measured.TargetOffset,
+		sampleRate,
+	), nil
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"strings"
+)
+
+const (
+	M3u8ContentType = "application/vnd.apple.mpegurl" // m3u8响应类型
+	TsContentType   = "video/mp2t"                    // ts分片响应类型
+)
+
+// RewriteM3u8WithToken 为m3u8内容中所有分片及密钥地址追加播放凭证
+// @param content m3u8文件内容
+// @param token 播放凭证
+func RewriteM3u8WithToken(content []byte, token string) ([]byte, error) {
+	var buf bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			// 标签内的URI属性（如#EXT-X-KEY密钥地址）
+			line = rewriteTagUri(line, token)
+		} else if line != "" {
+			// 非注释行即为分片地址
+			line = appendQueryToken(line, token)
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 为标签中的URI="..."属性追加token参数
+func rewriteTagUri(line string, token string) string {
+	const attr = `URI="`
+	start := strings.Index(line, attr)
+	if start < 0 {
+		return line
+	}
+	start += len(attr)
+	end := strings.Index(line[start:], `"`)
+	if end < 0 {
+		return line
+	}
+	end += start
+	return line[:start] + appendQueryToken(line[start:end], token) + line[end:]
+}
+
+// 为地址追加token参数
+func appendQueryToken(uri string, token string) string {
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+	return uri + sep + "token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	router_dao "eshop_server/src/router/dao"
+	router_model "eshop_server/src/router/model"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	productTitleMaxLength = 100 // products.title 字段长度
+	playerTitleMaxLength  = 255 // products_player.title 字段长度
+	metaTagMaxLength      = 255 // products_player_metadata title/artist/album/album_artist 字段长度
+	metaGenreMaxLength    = 64  // products_player_metadata.genre 字段长度
+	metaTrackMaxLength    = 16  // products_player_metadata.track 字段长度
+	metaDateMaxLength     = 32  // products_player_metadata.date 字段长度
+)
+
+var (
+	coverFileExtMap = map[string]string{ // 内嵌封面编码对应的文件后缀
+		"mjpeg": ".jpg",
+		"png":   ".png",
+	}
+)
+
+// ExtractPlayerMetadata 解析源文件技术参数、EBU R128响度、ID3/RIFF INFO标签及内嵌封面
+// 封面导出到封面目录, 随publishStreamingFiles发布; 响度及封面失败不影响其余元数据
+// @param player 播放记录
+// @param srcFile 本地源文件路径
+func ExtractPlayerMetadata(player *router_model.ProductsPlayer, srcFile string) (*router_model.ProductsPlayerMetadata, error) {
+	probe, err := GetMediaProbe(srcFile)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &router_model.ProductsPlayerMetadata{
+		PlayerId:   player.Id,
+		ProductId:  player.ProductId,
+		FormatName: probe.Format.FormatName,
+	}
+	meta.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	meta.BitRate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	// 技术参数取第一条音频流, 封面取第一条attached_pic流
+	var cover *model.MediaProbeStream
+	for i := range probe.Streams {
+		stream := &probe.Streams[i]
+		if stream.CodecType == "audio" && meta.Codec == "" {
+			meta.Codec = stream.CodecName
+			meta.SampleRate, _ = strconv.ParseInt(stream.SampleRate, 10, 64)
+			meta.Channels = stream.Channels
+			meta.ChannelLayout = stream.ChannelLayout
+			if bitRate, parseErr := strconv.ParseInt(stream.BitRate, 10, 64); parseErr == nil && bitRate > 0 {
+				meta.BitRate = bitRate
+			}
+			meta.BitsPerSample = stream.BitsPerSample
+			if meta.BitsPerSample == 0 {
+				meta.BitsPerSample, _ = strconv.ParseInt(stream.BitsPerRawSample, 10, 64)
+			}
+		}
+		if stream.CodecType == "video" && stream.Disposition["attached_pic"] == 1 && cover == nil {
+			cover = stream
+		}
+	}
+
+	// 标签, key统一小写
+	tags := make(map[string]string)
+	for k, v := range probe.Format.Tags {
+		tags[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+	meta.Title = truncateRunes(tags["title"], metaTagMaxLength)
+	meta.Artist = truncateRunes(tags["artist"], metaTagMaxLength)
+	meta.Album = truncateRunes(tags["album"], metaTagMaxLength)
+	meta.AlbumArtist = truncateRunes(tags["album_artist"], metaTagMaxLength)
+	meta.Genre = truncateRunes(tags["genre"], metaGenreMaxLength)
+	meta.Track = truncateRunes(tags["track"], metaTrackMaxLength)
+	meta.Date = truncateRunes(tags["date"], metaDateMaxLength)
+	if len(tags) > 0 {
+		b, _ := json.Marshal(tags)
+		meta.Tags = string(b)
+	}
+
+	// 响度
+	if loudness, loudnessErr := GetMediaLoudness(srcFile); loudnessErr != nil {
+		log.Errorf("ExtractPlayerMetadata 测量响度失败, srcFile: %s, error: %v", srcFile, loudnessErr)
+	} else {
+		meta.IntegratedLoudness = &loudness.IntegratedLoudness
+		meta.LoudnessRange = &loudness.LoudnessRange
+		meta.TruePeak = &loudness.TruePeak
+	}
+
+	// 内嵌封面
+	if cover != nil {
+		if ext, ok := coverFileExtMap[cover.CodecName]; ok {
+			coverName := player.Id + model.CoverFileSuffix + ext // {file_id}_cover.jpg
+			if coverErr := ExtractCoverArt(srcFile, filepath.Join(model.StreamFileCoverPath, coverName), cover.Index); coverErr != nil {
+				log.Errorf("ExtractPlayerMetadata 导出内嵌封面失败, srcFile: %s, error: %v", srcFile, coverErr)
+			} else {
+				meta.CoverUrl = config.StreamConfig.CoverHost + coverName
+			}
+		} else {
+			log.Infof("ExtractPlayerMetadata 不支持的封面编码, srcFile: %s, codec: %s", srcFile, cover.CodecName)
+		}
+	}
+	return meta, nil
+}
+
+// 根据元数据自动填充商品标题及图片
+// 标题取标签title（有artist时为"artist - title"）, 图片仅在商品使用默认图片时替换为内嵌封面
+func autoFillProduct(meta *router_model.ProductsPlayerMetadata) {
+	product, err := router_dao.GetProductById(meta.ProductId)
+	if err != nil || product == nil {
+		log.Errorf("autoFillProduct 获取商品失败, product_id: %s, error: %v", meta.ProductId, err)
+		return
+	}
+
+	update_fields := make([]string, 0)
+	if meta.Title != "" {
+		title := meta.Title
+		if meta.Artist != "" {
+			title = meta.Artist + " - " + meta.Title
+		}
+		product.Title = truncateRunes(title, productTitleMaxLength)
+		update_fields = append(update_fields, "title")
+	}
+	if meta.CoverUrl != "" && (product.ImageUrl == "" || product.ImageUrl == router_model.ProductImageUrlDefault) {
+		product.ImageUrl = meta.CoverUrl
+		update_fields = append(update_fields, "image_url")
+	}
+	if len(update_fields) == 0 {
+		return
+	}
+	if _, err = router_dao.UpdateProductsByField(product, update_fields); err != nil {
+		log.Errorf("autoFillProduct 更新商品失败, product: %+v, error: %v", product, err)
+		return
+	}
+	log.Infof("autoFillProduct 自动填充商品信息成功, product_id: %s, fields: %v", product.Id, update_fields)
+}
+
+// 按字符截断字符串
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
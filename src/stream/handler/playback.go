@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"eshop_server/src/common/cache"
+	router_dao "eshop_server/src/router/dao"
+	"eshop_server/src/router/middleware"
+	router_model "eshop_server/src/router/model"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// InitPlaybackFlusher 启动播放会话定时落库协程
+func InitPlaybackFlusher() {
+	interval := config.StreamConfig.Analytics.FlushInterval
+	if interval <= 0 {
+		interval = model.PlaybackDefaultFlushInterval
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			flushPlaybackSessions()
+		}
+	}()
+	log.Infof("InitPlaybackFlusher 启动播放会话落库协程成功, interval: %ds", interval)
+}
+
+// 获取播放会话id, 即播放凭证jti; 旧凭证无jti时使用凭证摘要
+func getPlaybackSessionId(claims *middleware.PlaybackClaims, token string) string {
+	if claims.Id != "" {
+		return claims.Id
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// 解析分片文件序号, {file_id}_{name}_{index}.ts, init分片等无序号文件返回false
+func parseSegmentIndex(filename string) (int64, bool) {
+	name := strings.TrimSuffix(filename, filepath.Ext(filename))
+	pos := strings.LastIndex(name, "_")
+	if pos < 0 {
+		return 0, false
+	}
+	index, err := strconv.ParseInt(name[pos+1:], 10, 64)
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
+
+// 记录播放开始, m3u8请求通过权限校验后调用
+func recordPlaybackStart(c *gin.Context, claims *middleware.PlaybackClaims, token string, player *router_model.ProductsPlayer) {
+	sessionId := getPlaybackSessionId(claims, token)
+	fields := map[string]interface{}{
+		"user_id":    claims.UserId,
+		"player_id":  player.Id,
+		"product_id": player.ProductId,
+		"duration":   player.Duration,
+		"client_ip":  c.ClientIP(),
+	}
+	if err := cache.StartStreamPlayback(sessionId, fields); err != nil {
+		log.Errorf("recordPlaybackStart 记录播放会话失败, session_id:%s, user_id:%s, player_id:%s, error:%v", sessionId, claims.UserId, player.Id, err)
+	}
+}
+
+// 记录播放分片请求
+func recordPlaybackSegment(claims *middleware.PlaybackClaims, token string, filename string) {
+	index, ok := parseSegmentIndex(filename)
+	if !ok {
+		return
+	}
+	sessionId := getPlaybackSessionId(claims, token)
+	if err := cache.RecordStreamPlaybackSegment(sessionId, claims.UserId, claims.PlayerId, index); err != nil {
+		log.Errorf("recordPlaybackSegment 记录播放分片失败, session_id:%s, filename:%s, error:%v", sessionId, filename, err)
+	}
+}
+
+// 将待落库的播放会话写入数据库, 失败的会话放回待落库集合等待下次重试
+func flushPlaybackSessions() {
+	players := make(map[string]*router_model.ProductsPlayer)
+	for {
+		sessionIds, err := cache.PopStreamPlaybackDirty(model.PlaybackFlushBatchSize)
+		if err != nil {
+			log.Errorf("flushPlaybackSessions 获取待落库播放会话失败, error:%v", err)
+			return
+		}
+		if len(sessionIds) == 0 {
+			return
+		}
+
+		failed := make([]string, 0)
+		for _, sessionId := range sessionIds {
+			if err = flushPlaybackSession(sessionId, players); err != nil {
+				log.Errorf("flushPlaybackSessions 播放会话落库失败, session_id:%s, error:%v", sessionId, err)
+				failed = append(failed, sessionId)
+			}
+		}
+		if len(failed) > 0 {
+			if err = cache.PushStreamPlaybackDirty(failed...); err != nil {
+				log.Errorf("flushPlaybackSessions 播放会话放回待落库集合失败, session_ids:%v, error:%v", failed, err)
+			}
+			return
+		}
+		if len(sessionIds) < model.PlaybackFlushBatchSize {
+			return
+		}
+	}
+}
+
+// 单个播放会话落库, 收听时长按不重复分片数估算
+// @param players 本轮落库的player缓存, 用于补全仅请求分片的会话
+func flushPlaybackSession(sessionId string, players map[string]*router_model.ProductsPlayer) error {
+	fields, segments, err := cache.GetStreamPlayback(sessionId)
+	if err != nil {
+		return err
+	}
+	// 会话已过期
+	if len(fields) == 0 || fields["player_id"] == "" {
+		return nil
+	}
+
+	session := &router_model.ProductsPlaybackSession{
+		Id:        sessionId,
+		UserId:    fields["user_id"],
+		PlayerId:  fields["player_id"],
+		ProductId: fields["product_id"],
+		ClientIp:  fields["client_ip"],
+		Segments:  segments,
+	}
+	session.Requests, _ = strconv.ParseInt(fields["requests"], 10, 64)
+	session.Duration, _ = strconv.ParseInt(fields["duration"], 10, 64)
+	startedAt, _ := strconv.ParseInt(fields["started_at"], 10, 64)
+	lastAt, _ := strconv.ParseInt(fields["last_at"], 10, 64)
+	session.StartedAt = time.Unix(startedAt, 0)
+	session.LastPlayedAt = time.Unix(lastAt, 0)
+
+	if session.ProductId == "" {
+		player, ok := players[session.PlayerId]
+		if !ok {
+			player, err = router_dao.GetProductsPlayerById(session.PlayerId)
+			if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Errorf("flushPlaybackSession player不存在, 丢弃播放会话, session_id:%s, player_id:%s", sessionId, session.PlayerId)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			players[session.PlayerId] = player
+		}
+		session.ProductId = player.ProductId
+		session.Duration = player.Duration
+	}
+
+	session.ListenedSeconds = segments * HlsSegmentDuration
+	if session.Duration > 0 && session.ListenedSeconds > session.Duration {
+		session.ListenedSeconds = session.Duration
+	}
+	_, err = router_dao.ReplaceProductsPlaybackSession(session)
+	return err
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/config"
+	"path/filepath"
+)
+
+const (
+	PreviewDefaultDuration int64  = 30         // 默认试听时长（秒）
+	PreviewDefaultFadeOut  int64  = 3          // 默认淡出时长（秒）
+	PreviewFileSuffix      string = "_preview" // 试听文件名后缀
+)
+
+// GeneratePreview 根据配置为player截取试听片段
+// @param playerId 播放id
+// @param srcFile 源文件路径
+// @param mediaDuration 源文件时长（秒）
+// @param audioFilter 音频滤镜, 与完整音频保持一致, 为空时不处理
+// @return previewUrl 试听地址, previewDuration 试听时长（秒）
+func GeneratePreview(playerId string, srcFile string, mediaDuration int64, audioFilter string) (previewUrl string, previewDuration int64, err error) {
+	cfg := config.StreamConfig.Preview
+	offset := cfg.Offset
+	previewDuration = cfg.Duration
+	if previewDuration <= 0 {
+		previewDuration = PreviewDefaultDuration
+	}
+	fadeOut := cfg.FadeOut
+	if fadeOut <= 0 {
+		fadeOut = PreviewDefaultFadeOut
+	}
+
+	// 起始位置超出音频时长时从头截取，截取时长不超过剩余时长
+	if offset < 0 || offset >= mediaDuration {
+		offset = 0
+	}
+	if mediaDuration > 0 && offset+previewDuration > mediaDuration {
+		previewDuration = mediaDuration - offset
+	}
+
+	previewName := playerId + PreviewFileSuffix + ".m3u8" // {file_id}_preview.m3u8
+	m3u8File := filepath.Join(model.StreamFilePreviewPath, previewName)
+	if err = GenerateAudioPreviewM3u8(srcFile, m3u8File, offset, previewDuration, fadeOut, audioFilter); err != nil {
+		return "", 0, err
+	}
+	return config.StreamConfig.PreviewHost + previewName, previewDuration, nil
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"eshop_server/src/common/api"
+	router_dao "eshop_server/src/router/dao"
+	router_model "eshop_server/src/router/model"
+	"eshop_server/src/stream/model"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	playerFileDirList = []string{ // player相关文件所在目录, 文件名均以{player_id}开头
+		model.StreamFileUploadPath,
+		model.StreamFileSegmentPath,
+		model.StreamFileKeyPath,
+		model.StreamFilePreviewPath,
+		model.StreamFileCoverPath,
+		model.StreamFileWaveformPath,
+	}
+)
+
+// 将player的源文件及流媒体文件移动到回收站目录, 由定时任务超过保留时长后清理
+// @return count 已回收的文件数
+func recyclePlayerFiles(playerId string) (count int, err error) {
+	for _, dir := range playerFileDirList {
+		objects, listErr := storage.Default.List(storage.Key(dir, playerId))
+		if listErr != nil {
+			return count, listErr
+		}
+		for _, object := range objects {
+			// 仅处理目录下的直接文件, 且player_id完全匹配
+			filename := strings.TrimPrefix(object.Key, dir+"/")
+			if strings.Contains(filename, "/") || !isPlayerFile(filename, playerId) {
+				continue
+			}
+			recycleKey := storage.Key(model.StreamFileRecyclePath, filename)
+			if err = storage.Move(storage.Default, object.Key, recycleKey); err != nil {
+				log.Errorf("recyclePlayerFiles 移动文件到回收站失败, key: %s, error: %v", object.Key, err)
+				return count, err
+			}
+			count++
+		}
+	}
+	segmentCache.RemovePrefix(storage.Key(model.StreamFileSegmentPath, playerId))
+	segmentCache.RemovePrefix(storage.Key(model.StreamFilePreviewPath, playerId))
+	log.Infof("recyclePlayerFiles 回收文件成功, player_id: %s, count: %d", playerId, count)
+	return count, nil
+}
+
+// 判断文件是否属于player, {player_id}.mp3 / {player_id}_128k_0.ts
+func isPlayerFile(filename string, playerId string) bool {
+	name := strings.TrimSuffix(filename, path.Ext(filename))
+	if idx := strings.Index(name, "_"); idx > 0 {
+		name = name[:idx]
+	}
+	return name == playerId
+}
+
+// @Title		 下架播放资源
+// @Description  将player状态置为下架, 源文件及流媒体文件移动到回收站
+// @Response     json
+// @Router       /v1/steaming/retire/:player_id [post]
+func RetirePlayer(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	playerId := c.Param("player_id")
+	player, err := router_dao.GetProductsPlayerById(playerId)
+	if err != nil {
+		log.Errorf("RetirePlayer 获取ProductsPlayer记录失败, player_id: %s, error: %v", playerId, err)
+		api.FailWithFileNotFound(c)
+		return
+	}
+	// 转码中的文件仍在生成, 待转码结束后再下架
+	if player.Status == router_model.ProductsPlayerStatusParsing {
+		log.Errorf("RetirePlayer 播放资源正在转码, player_id: %s", playerId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail+":播放资源正在转码")
+		return
+	}
+
+	// 先下架再回收文件, 避免回收期间仍可播放
+	player.Status = router_model.ProductsPlayerStatusInvalid
+	if _, err = router_dao.UpdateProductsPlayerByField(player, []string{"status"}); err != nil {
+		log.Errorf("RetirePlayer 更新ProductsPlayer记录失败, player: %+v, error: %v", player, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+	count, err := recyclePlayerFiles(playerId)
+	if err != nil {
+		log.Errorf("RetirePlayer 回收文件失败, player_id: %s, error: %v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Code, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Detail+":回收文件失败")
+		return
+	}
+	log.Infof("RetirePlayer 下架播放资源成功, player_id: %s, recycled: %d", playerId, count)
+
+	dataMap["result"] = player
+	dataMap["recycled"] = count
+	api.Success(c, dataMap)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"bytes"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/common"
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	HlsMasterPlaylistVersion = 7           // master m3u8版本号
+	HlsAacCodecs             = "mp4a.40.2" // AAC-LC编码标识
+	HlsLosslessName          = "lossless"  // 无损音轨名称
+	HlsHifiName              = "hifi"      // hi-fi master名称
+	HlsLosslessCodecFlac     = "flac"      // 无损编码 flac
+	HlsLosslessCodecAlac     = "alac"      // 无损编码 alac
+	HlsLosslessBandwidth     = 1411200     // 无损音轨带宽（CD音质 44.1kHz/16bit/双声道）
+	HlsSegmentDuration       = 10          // 分片时长（秒）, 与ffmpeg -hls_time一致
+)
+
+var (
+	HlsPlayerFileTypeList     = []string{".m3u8", ".ts", ".m4s", ".mp4"} // 播放接口支持的文件类型
+	HlsDefaultBitrateLadder   = []int64{64, 128, 256}                    // 默认AAC码率阶梯（kbps）
+	HlsLosslessSourceTypeList = []string{".wav"}                         // 支持生成无损音轨的源文件类型
+	hlsLosslessCodecsMap      = map[string]string{                       // 无损编码对应的CODECS标识
+		HlsLosslessCodecFlac: "fLaC",
+		HlsLosslessCodecAlac: "alac",
+	}
+)
+
+// HLS音轨
+type HlsRendition struct {
+	Name      string // 音轨名称, 如128k
+	Bandwidth int64  // 峰值带宽（bit/s）
+	Codecs    string // 编码标识
+	Playlist  string // 音轨m3u8文件名
+}
+
+// GetHlsRenditionPlaylist 获取音轨m3u8文件名
+// {player_id}_{name}.m3u8
+func GetHlsRenditionPlaylist(playerId string, name string) string {
+	return playerId + "_" + name + ".m3u8"
+}
+
+// 获取配置的AAC码率阶梯
+func getBitrateLadder() []int64 {
+	ladder := make([]int64, 0, len(config.StreamConfig.BitrateLadder))
+	for _, bitrate := range config.StreamConfig.BitrateLadder {
+		if bitrate > 0 {
+			ladder = append(ladder, bitrate)
+		}
+	}
+	if len(ladder) == 0 {
+		return HlsDefaultBitrateLadder
+	}
+	return ladder
+}
+
+// GenerateAudioHls 生成多码率AAC音轨及master m3u8, wav源文件按配置额外生成无损音轨
+// 无损音轨写入单独的hi-fi master, 同时保留AAC音轨作为弱网回退
+// @param playerId 播放id
+// @param srcFile 源文件路径
+// @param keyInfoFile AES-128密钥信息文件路径
+// @param audioFilter 音频滤镜, 如loudnorm响度标准化, 所有音轨使用相同滤镜以保持音量一致
+// @return hifiPlaylist hi-fi master m3u8文件名, 未生成时为空
+func GenerateAudioHls(playerId string, srcFile string, keyInfoFile string, audioFilter string) (hifiPlaylist string, err error) {
+	// 生成AAC音轨
+	renditions := make([]*HlsRendition, 0)
+	for _, bitrate := range getBitrateLadder() {
+		rendition := &HlsRendition{
+			Name:      fmt.Sprintf("%dk", bitrate),
+			Bandwidth: bitrate * 1000 * 11 / 10, // 预留10%容器开销
+			Codecs:    HlsAacCodecs,
+		}
+		rendition.Playlist = GetHlsRenditionPlaylist(playerId, rendition.Name)
+		dstFile := filepath.Join(model.StreamFileSegmentPath, rendition.Playlist)
+		if err = GenerateAudioAacM3u8(srcFile, dstFile, keyInfoFile, bitrate, audioFilter); err != nil {
+			return "", fmt.Errorf("GenerateAudioHls 生成%s音轨失败: %v", rendition.Name, err)
+		}
+		renditions = append(renditions, rendition)
+	}
+
+	// 生成master m3u8
+	masterFile := filepath.Join(model.StreamFileSegmentPath, playerId+".m3u8") // {file_id}.m3u8
+	if err = WriteHlsMasterPlaylist(masterFile, renditions); err != nil {
+		return "", fmt.Errorf("GenerateAudioHls 生成master m3u8失败: %v", err)
+	}
+
+	// 生成无损音轨, 失败不影响AAC音轨
+	codec := strings.ToLower(config.StreamConfig.LosslessCodec)
+	if codec == "" || !common.CheckFileTypes(srcFile, HlsLosslessSourceTypeList) {
+		return "", nil
+	}
+	if _, ok := hlsLosslessCodecsMap[codec]; !ok {
+		log.Errorf("GenerateAudioHls 不支持的无损编码, codec: %s", codec)
+		return "", nil
+	}
+	lossless := &HlsRendition{
+		Name:      HlsLosslessName,
+		Bandwidth: HlsLosslessBandwidth,
+		Codecs:    hlsLosslessCodecsMap[codec],
+		Playlist:  GetHlsRenditionPlaylist(playerId, HlsLosslessName),
+	}
+	dstFile := filepath.Join(model.StreamFileSegmentPath, lossless.Playlist)
+	if err = GenerateAudioLosslessM3u8(srcFile, dstFile, keyInfoFile, codec, audioFilter); err != nil {
+		log.Errorf("GenerateAudioHls 生成无损音轨失败, srcFile: %s, codec: %s, error: %v", srcFile, codec, err)
+		return "", nil
+	}
+	hifiPlaylist = GetHlsRenditionPlaylist(playerId, HlsHifiName)
+	hifiFile := filepath.Join(model.StreamFileSegmentPath, hifiPlaylist)
+	if err = WriteHlsMasterPlaylist(hifiFile, append(renditions, lossless)); err != nil {
+		log.Errorf("GenerateAudioHls 生成hi-fi master m3u8失败, hifiFile: %s, error: %v", hifiFile, err)
+		return "", nil
+	}
+	return hifiPlaylist, nil
+}
+
+// WriteHlsMasterPlaylist 生成master m3u8文件
+// @param dstFile 目标文件路径
+// @param renditions 音轨列表
+func WriteHlsMasterPlaylist(dstFile string, renditions []*HlsRendition) error {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", HlsMasterPlaylistVersion))
+	for _, r := range renditions {
+		buf.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"\n", r.Bandwidth, r.Codecs))
+		buf.WriteString(r.Playlist + "\n")
+	}
+	return os.WriteFile(dstFile, buf.Bytes(), 0644)
+}
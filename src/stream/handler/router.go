@@ -14,9 +14,22 @@ func InitRouter() {
 	// 设置路由组
 	stream_v1 := router.Group("/v1/steaming")
 	{
-		stream_v1.POST("/upload_streaming_file", UploadStreamingFile)
-		stream_v1.POST("/upload_streaming_file_only", UploadStreamingFileOnly)
+		stream_v1.POST("/upload_streaming_file", middleware.InternalAuth(), UploadStreamingFile)
+		stream_v1.POST("/upload_streaming_file_only", middleware.InternalAuth(), UploadStreamingFileOnly)
+		stream_v1.POST("/upload/init", middleware.InternalAuth(), InitUpload)
+		stream_v1.GET("/upload/:upload_id", middleware.InternalAuth(), GetUploadStatus)
+		stream_v1.PUT("/upload/:upload_id/chunk/:index", middleware.InternalAuth(), UploadChunk)
+		stream_v1.POST("/upload/:upload_id/complete", middleware.InternalAuth(), CompleteUpload)
 		stream_v1.GET("/player/:filename", StreamingPlayer)
+		stream_v1.GET("/key/:player_id", StreamingKey)
+		stream_v1.GET("/preview/:filename", StreamingPreview)
+		stream_v1.GET("/cover/:filename", StreamingCover)
+		stream_v1.GET("/waveform/:player_id", StreamingWaveform)
+		stream_v1.GET("/transcode/:player_id", middleware.InternalAuth(), GetTranscodeJob)
+		stream_v1.POST("/transcode/:player_id/retry", middleware.InternalAuth(), RetryTranscodeJob)
+		stream_v1.POST("/retire/:player_id", middleware.InternalAuth(), RetirePlayer)
+		stream_v1.GET("/download/:ticket_id", StreamingDownload)
+		stream_v1.GET("/stats/segment_cache", middleware.InternalAuth(), GetSegmentCacheStats)
 	}
 
 	log.Infof("初始化流媒体服务成功, URL：%s", config.CommonConfig.HttpServer.Addr)
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"container/list"
+	"eshop_server/src/common/api"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	segmentCache = NewSegmentCache(model.SegmentCacheDefaultSize) // 分片内存缓存, InitSegmentCache按配置重新初始化
+)
+
+// 分片缓存项
+type segmentCacheEntry struct {
+	key     string    // 存储key
+	data    []byte    // 分片内容
+	modTime time.Time // 存储中的最后修改时间, 用于校验缓存是否过期
+}
+
+// SegmentCache 按大小限制的LRU分片内存缓存, 并发安全
+type SegmentCache struct {
+	mu       sync.Mutex
+	capacity int64                    // 缓存大小（Byte字节）
+	size     int64                    // 已用大小（Byte字节）
+	ll       *list.List               // 最近使用的在前
+	items    map[string]*list.Element // key -> *segmentCacheEntry
+	hits     int64
+	misses   int64
+}
+
+// NewSegmentCache 创建分片内存缓存
+// @param capacity 缓存大小（Byte字节）, 小于等于0时不缓存
+func NewSegmentCache(capacity int64) *SegmentCache {
+	return &SegmentCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// InitSegmentCache 根据配置初始化分片内存缓存
+func InitSegmentCache() {
+	capacity := config.StreamConfig.SegmentCacheSize
+	if capacity == 0 {
+		capacity = model.SegmentCacheDefaultSize
+	}
+	segmentCache = NewSegmentCache(capacity)
+	log.Infof("InitSegmentCache 初始化分片内存缓存成功, capacity: %d", capacity)
+}
+
+// Cacheable 判断指定大小的分片是否可缓存
+func (sc *SegmentCache) Cacheable(size int64) bool {
+	return sc.capacity > 0 && size > 0 && size <= sc.capacity/model.SegmentCacheItemSizeFactor
+}
+
+// Get 获取缓存的分片, 存储中的文件已更新（modTime/大小不一致）时视为未命中并淘汰
+func (sc *SegmentCache) Get(key string, modTime time.Time, size int64) (*segmentCacheEntry, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if e, ok := sc.items[key]; ok {
+		entry := e.Value.(*segmentCacheEntry)
+		if entry.modTime.Equal(modTime) && int64(len(entry.data)) == size {
+			sc.ll.MoveToFront(e)
+			atomic.AddInt64(&sc.hits, 1)
+			return entry, true
+		}
+		sc.removeElement(e)
+	}
+	atomic.AddInt64(&sc.misses, 1)
+	return nil, false
+}
+
+// Add 写入分片, 超出缓存大小时淘汰最久未使用的分片
+func (sc *SegmentCache) Add(entry *segmentCacheEntry) {
+	if !sc.Cacheable(int64(len(entry.data))) {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if e, ok := sc.items[entry.key]; ok {
+		sc.removeElement(e)
+	}
+	sc.items[entry.key] = sc.ll.PushFront(entry)
+	sc.size += int64(len(entry.data))
+	for sc.size > sc.capacity {
+		if e := sc.ll.Back(); e != nil {
+			sc.removeElement(e)
+		}
+	}
+}
+
+// RemovePrefix 淘汰key前缀匹配的分片, 用于文件重新发布后
+func (sc *SegmentCache) RemovePrefix(prefix string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for key, e := range sc.items {
+		if strings.HasPrefix(key, prefix) {
+			sc.removeElement(e)
+		}
+	}
+}
+
+// Stats 获取缓存统计
+func (sc *SegmentCache) Stats() *model.SegmentCacheStats {
+	sc.mu.Lock()
+	stats := &model.SegmentCacheStats{
+		Items:    int64(sc.ll.Len()),
+		Size:     sc.size,
+		Capacity: sc.capacity,
+	}
+	sc.mu.Unlock()
+	stats.Hits = atomic.LoadInt64(&sc.hits)
+	stats.Misses = atomic.LoadInt64(&sc.misses)
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+func (sc *SegmentCache) removeElement(e *list.Element) {
+	entry := sc.ll.Remove(e).(*segmentCacheEntry)
+	delete(sc.items, entry.key)
+	sc.size -= int64(len(entry.data))
+}
+
+// @Title		 分片缓存统计
+// @Description  获取分片内存缓存的命中/未命中次数及使用量
+// @Response     json
+// @Router       /v1/steaming/stats/segment_cache [get]
+func GetSegmentCacheStats(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+	dataMap["result"] = segmentCache.Stats()
+	api.Success(c, dataMap)
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSegmentCache_LRU(t *testing.T) {
+	modTime := time.Unix(1700000000, 0)
+	sc := NewSegmentCache(64)
+	for _, key := range []string{"segments/a_0.ts", "segments/a_1.ts", "segments/a_2.ts", "segments/a_3.ts"} {
+		sc.Add(&segmentCacheEntry{key: key, data: make([]byte, 4), modTime: modTime})
+	}
+	if _, ok := sc.Get("segments/a_0.ts", modTime, 4); !ok {
+		t.Fatalf("expected hit for a_0")
+	}
+
+	// 超出容量时淘汰最久未使用的a_1
+	for i := 0; i < 13; i++ {
+		sc.Add(&segmentCacheEntry{key: "segments/b_" + string(rune('a'+i)) + ".ts", data: make([]byte, 4), modTime: modTime})
+	}
+	if _, ok := sc.Get("segments/a_1.ts", modTime, 4); ok {
+		t.Fatalf("expected a_1 to be evicted")
+	}
+	if _, ok := sc.Get("segments/a_0.ts", modTime, 4); !ok {
+		t.Fatalf("expected a_0 to survive eviction")
+	}
+
+	// 文件已更新时视为未命中
+	if _, ok := sc.Get("segments/a_0.ts", modTime.Add(time.Second), 4); ok {
+		t.Fatalf("expected miss for modified file")
+	}
+
+	sc.RemovePrefix("segments/b_")
+	stats := sc.Stats()
+	if stats.Size > 64 || stats.Items != 2 {
+		t.Fatalf("unexpected stats after RemovePrefix: %+v", stats)
+	}
+	if stats.Hits != 2 || stats.Misses != 2 {
+		t.Fatalf("unexpected hit/miss counters: %+v", stats)
+	}
+}
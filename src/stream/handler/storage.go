@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SegmentCacheControl  = "private, max-age=31536000, immutable" // 分片内容不变, 客户端长期缓存
+	PlaylistCacheControl = "private, max-age=10"                  // m3u8携带播放凭证, 仅短期缓存
+)
+
+var (
+	streamingContentTypeMap = map[string]string{ // 流媒体文件响应类型
+		".m3u8": M3u8ContentType,
+		".ts":   TsContentType,
+		".m4s":  "video/iso.segment",
+		".mp4":  "audio/mp4",
+		".key":  "application/octet-stream",
+		".jpg":  "image/jpeg",
+		".png":  "image/png",
+		".json": "application/json",
+	}
+)
+
+// GetStreamingContentType 获取流媒体文件响应类型
+func GetStreamingContentType(filename string) string {
+	if ct, ok := streamingContentTypeMap[strings.ToLower(filepath.Ext(filename))]; ok {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// 获取预签名地址有效时长
+func getPresignExpire() time.Duration {
+	if config.StreamConfig.Storage.PresignExpire > 0 {
+		return time.Duration(config.StreamConfig.Storage.PresignExpire) * time.Second
+	}
+	return storage.DefaultPresignExpire
+}
+
+// 将本地生成的player流媒体文件发布到存储, 非本地存储时清理本地副本
+// 包括切片、试听、封面及波形目录下{player_id}开头的文件, 以及密钥文件
+func publishStreamingFiles(playerId string) error {
+	localFiles := make([]string, 0)
+	for _, dir := range []string{model.StreamFileSegmentPath, model.StreamFilePreviewPath, model.StreamFileCoverPath, model.StreamFileWaveformPath} {
+		matches, err := filepath.Glob(filepath.Join(dir, playerId+"*"))
+		if err != nil {
+			return err
+		}
+		localFiles = append(localFiles, matches...)
+	}
+	localFiles = append(localFiles, GetHlsKeyFile(playerId))
+	// 重新转码时分片名不变, 淘汰旧分片缓存
+	segmentCache.RemovePrefix(storage.Key(model.StreamFileSegmentPath, playerId))
+	segmentCache.RemovePrefix(storage.Key(model.StreamFilePreviewPath, playerId))
+
+	for _, localFile := range localFiles {
+		key := storage.Key(filepath.Base(filepath.Dir(localFile)), filepath.Base(localFile))
+		if err := storage.PutFile(storage.Default, key, localFile, GetStreamingContentType(localFile)); err != nil {
+			log.Errorf("publishStreamingFiles 上传文件失败, localFile: %s, key: %s, error: %v", localFile, key, err)
+			return err
+		}
+	}
+
+	if !storage.IsLocal(storage.Default) {
+		localFiles = append(localFiles, GetHlsKeyInfoFile(playerId))
+		removeLocalFiles(localFiles...)
+	}
+	return nil
+}
+
+// 清理本地文件
+func removeLocalFiles(files ...string) {
+	for _, f := range files {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			log.Errorf("removeLocalFiles 清理本地文件失败, file: %s, error: %v", f, err)
+		}
+	}
+}
+
+// 返回存储中的文件, 支持预签名的存储重定向到预签名地址
+func serveStorageObject(c *gin.Context, key string) {
+	contentType := GetStreamingContentType(key)
+
+	// 本地存储直接返回文件
+	if localPath, ok := storage.LocalPath(storage.Default, key); ok {
+		c.Header("Content-Type", contentType)
+		c.File(localPath)
+		return
+	}
+
+	// 远端存储重定向到预签名地址
+	if presignUrl, err := storage.Default.Presign(key, getPresignExpire()); err == nil {
+		c.Redirect(http.StatusFound, presignUrl)
+		return
+	}
+
+	// 不支持预签名时由服务端转发
+	info, err := storage.Default.Stat(key)
+	if err != nil {
+		log.Errorf("serveStorageObject 获取文件信息失败, key: %s, error: %v", key, err)
+		c.Status(http.StatusNotFound)
+		return
+	}
+	r, err := storage.Default.Get(key)
+	if err != nil {
+		log.Errorf("serveStorageObject 读取文件失败, key: %s, error: %v", key, err)
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer r.Close()
+	c.DataFromReader(http.StatusOK, info.Size, contentType, r, nil)
+}
+
+// 存储对象的强ETag, 由最后修改时间及大小确定
+func storageObjectETag(info *storage.ObjectInfo) string {
+	return fmt.Sprintf("\"%x-%x\"", info.ModTime.UnixNano(), info.Size)
+}
+
+// 内存内容的强ETag, 由内容摘要确定
+func contentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "\"" + hex.EncodeToString(sum[:16]) + "\""
+}
+
+// 返回内存中的文件内容, 由http.ServeContent处理Range及If-None-Match/If-Modified-Since条件请求
+func serveContent(c *gin.Context, name string, modTime time.Time, content []byte) {
+	c.Header("Content-Type", GetStreamingContentType(name))
+	http.ServeContent(c.Writer, c.Request, filepath.Base(name), modTime, bytes.NewReader(content))
+}
+
+// 返回分片文件, 优先读取内存缓存, 超出单个缓存大小限制的分片直接返回存储中的文件
+// @param info 分片的存储对象信息
+func serveSegmentObject(c *gin.Context, info *storage.ObjectInfo) {
+	entry, ok := segmentCache.Get(info.Key, info.ModTime, info.Size)
+	if !ok {
+		if !segmentCache.Cacheable(info.Size) {
+			// 预签名地址会过期, 重定向响应不可长期缓存
+			if storage.IsLocal(storage.Default) {
+				c.Header("Cache-Control", SegmentCacheControl)
+				c.Header("ETag", storageObjectETag(info))
+			}
+			serveStorageObject(c, info.Key)
+			return
+		}
+		data, err := storage.ReadAll(storage.Default, info.Key)
+		if err != nil {
+			log.Errorf("serveSegmentObject 读取文件失败, key: %s, error: %v", info.Key, err)
+			c.Status(http.StatusNotFound)
+			return
+		}
+		entry = &segmentCacheEntry{key: info.Key, data: data, modTime: info.ModTime}
+		segmentCache.Add(entry)
+	}
+	c.Header("Cache-Control", SegmentCacheControl)
+	c.Header("ETag", storageObjectETag(info))
+	serveContent(c, info.Key, entry.modTime, entry.data)
+}
@@ -1,18 +1,21 @@
 package handler
 
 import (
-	"bytes"
+	"errors"
 	"eshop_server/src/common/api"
 	router_dao "eshop_server/src/router/dao"
+	"eshop_server/src/router/middleware"
 	router_model "eshop_server/src/router/model"
 	"eshop_server/src/stream/model"
 	"eshop_server/src/utils/common"
 	"eshop_server/src/utils/config"
 	uerrors "eshop_server/src/utils/errors"
 	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
 	"eshop_server/src/utils/uuid"
-	"os"
+	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,16 +23,15 @@ import (
 )
 
 // @Title		 上传流媒体文件
-// @Description  上传流媒体文件到stream服务器, 更新数据库
+// @Description  上传流媒体文件到stream服务器, 更新数据库, 转码任务异步处理
 // @Response     json
 // @Router       /v1/steaming/upload_streaming_file [post]
 func UploadStreamingFile(c *gin.Context) {
 	var err error
 	dataMap := make(map[string]interface{})
 
-	// 设置文件大小限制
-	// 放在 Nginx 限制
-	// c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 8<<20) // 8 MiB
+	// 设置文件大小限制, 预留1MiB表单开销; Nginx同样需要配置client_max_body_size
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, getUploadMaxSize()+1<<20)
 
 	// 从请求中获取product_id
 	product_id := c.PostForm("product_id")
@@ -47,28 +49,16 @@ func UploadStreamingFile(c *gin.Context) {
 		return
 	}
 
-	// 验证文件真实类型
-	// fileHeader, _ := file.Open()
-	// defer fileHeader.Close()
-	// buffer := make([]byte, 12)
-	// _, err = fileHeader.Read(buffer)
-	// if err != nil {
-	// 	log.Errorf("UploadStreamingFile 请求参数错误, file read failed, error: %s", err.Error())
-	// 	api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":文件读取失败")
-	// 	return
-	// }
-	// 魔数校验示例，需根据实际情况完善
-	// isValid := validateFileType(buffer)
-	// if !isValid {
-	// 	log.Errorf("UploadStreamingFile 请求参数错误, 文件类型必须为mp3或wav, filename: %s", file.Filename)
-	// 	api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
-	// 	return
-	// }
 	if !common.CheckFileTypes(file.Filename, router_model.ProductPlayerSupportFileTypeList) {
 		log.Errorf("UploadStreamingFile 请求参数错误, 文件类型必须为mp3或wav, filename: %s", file.Filename)
 		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
 		return
 	}
+	if file.Size > getUploadMaxSize() {
+		log.Errorf("UploadStreamingFile 文件大小超出限制, filename: %s, filesize: %d", file.Filename, file.Size)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileTooLarge.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileTooLarge.Error()).Detail)
+		return
+	}
 	fileType := strings.ToLower(filepath.Ext(file.Filename))
 	log.Infof("UploadStreamingFile 获取到文件, filename: %s, filesize: %vMB", file.Filename, file.Size/1024/1024)
 
@@ -84,6 +74,11 @@ func UploadStreamingFile(c *gin.Context) {
 		PlayType:  "",
 		PlayUrl:   "",
 	}
+	if err = assignPlayerTrack(player, nil); err != nil {
+		log.Errorf("UploadStreamingFile 获取曲目序号失败, product_id: %s, error: %s", product_id, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
 	_, err = router_dao.CreateProductsPlayer(player)
 	if err != nil || player == nil {
 		log.Errorf("UploadStreamingFile 创建ProductsPlayer记录失败, filename: %s , error: %s", file.Filename, err.Error())
@@ -93,6 +88,12 @@ func UploadStreamingFile(c *gin.Context) {
 
 	// 修改 defer 函数，通过闭包捕获 err 变量地址
 	defer func() {
+		var quarantineErr *QuarantineError
+		if errors.As(err, &quarantineErr) {
+			// 文件已隔离, player状态已更新
+			api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileQuarantined.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileQuarantined.Error()).Detail+":"+quarantineErr.Reason)
+			return
+		}
 		if err != nil {
 			// 更新ProductsPlayer记录为错误状态
 			player.Status = router_model.ProductsPlayerStatusError
@@ -114,37 +115,16 @@ func UploadStreamingFile(c *gin.Context) {
 		return
 	}
 
-	// 更新数据库
-	player.Filename = player.Id
-	player.Duration, err = GetMediaDuration(srcFile)
-	if err != nil {
-		log.Errorf("UploadStreamingFile 获取文件时长失败, srcFile: %s, error: %s", srcFile, err.Error())
-		return
-	}
-	player.Status = router_model.ProductsPlayerStatusParsing
-	if _, err = router_dao.UpdateProductsPlayerByField(player, []string{"filename", "duration", "status"}); err != nil {
-		log.Errorf("UploadStreamingFile 更新ProductsPlayer记录失败, m: %+v , error: %s", player, err.Error())
-		return
-	}
-
-	// 生成m3u8文件和.ts分片文件
-	m3u8File := filepath.Join(model.StreamFileSegmentPath, player.Id+".m3u8") // {file_id}.m3u8
-	err = GenerateAudioM3u8(srcFile, m3u8File)
+	// 写入存储并创建转码任务
+	autoFill, _ := strconv.ParseBool(c.PostForm("auto_fill"))
+	job, err := submitUploadedFile(player, srcFile, autoFill, "")
 	if err != nil {
-		log.Errorf("UploadStreamingFile 生成m3u8文件失败, srcFile: %s, m3u8File: %s, error: %s", srcFile, m3u8File, err.Error())
-		return
-	}
-
-	// 更新数据库
-	player.PlayType = router_model.ProductsPlayerPlayTypeHls
-	player.PlayUrl = config.StreamConfig.Host + player.Id + ".m3u8"
-	player.Status = router_model.ProductsPlayerStatusOk
-	if _, err = router_dao.UpdateProductsPlayerByField(player, []string{"play_type", "play_url", "status"}); err != nil {
-		log.Errorf("UploadStreamingFile 更新ProductsPlayer记录失败, m: %+v , error: %s", player, err.Error())
+		log.Errorf("UploadStreamingFile 提交源文件失败, player_id: %s, error: %s", player.Id, err.Error())
 		return
 	}
 
 	// 返回成功响应
+	dataMap["job"] = job
 	dataMap["result"] = player
 	api.Success(c, dataMap)
 }
@@ -171,6 +151,11 @@ func UploadStreamingFileOnly(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
 		return
 	}
+	if file.Size > getUploadMaxSize() {
+		log.Errorf("UploadStreamingFileOnly 文件大小超出限制, filename: %s, filesize: %d", file.Filename, file.Size)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileTooLarge.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileTooLarge.Error()).Detail)
+		return
+	}
 	fileType := strings.ToLower(filepath.Ext(file.Filename))
 	log.Infof("UploadStreamingFileOnly 获取到文件, filename: %s, filesize: %vMB", file.Filename, file.Size/1024/1024)
 
@@ -196,6 +181,17 @@ func UploadStreamingFileOnly(c *gin.Context) {
 		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
 		return
 	}
+	// 校验文件内容, 不通过时隔离
+	if err = checkUploadedFile(player, srcFile); err != nil {
+		log.Errorf("UploadStreamingFileOnly 源文件校验失败, srcFile: %s, error: %s", srcFile, err.Error())
+		var quarantineErr *QuarantineError
+		if errors.As(err, &quarantineErr) {
+			api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileQuarantined.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileQuarantined.Error()).Detail+":"+quarantineErr.Reason)
+			return
+		}
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
 	// 更新文件时长
 	player.Duration, err = GetMediaDuration(srcFile)
 	if err != nil {
@@ -204,17 +200,61 @@ func UploadStreamingFileOnly(c *gin.Context) {
 		return
 	}
 
-	// 生成m3u8文件和.ts分片文件
-	m3u8FilePath := filepath.Join(model.StreamFileSegmentPath, player.Id+".m3u8") // {file_id}.m3u8
-	err = GenerateAudioM3u8(srcFile, m3u8FilePath)
+	// 响度标准化测量, 失败时保持原始响度
+	audioFilter, err := GetLoudnormFilter(srcFile, 0)
+	if err != nil {
+		log.Errorf("UploadStreamingFileOnly 响度标准化测量失败, srcFile: %s, error: %s", srcFile, err.Error())
+	}
+
+	// 生成AES-128密钥
+	keyFile, keyInfoFile, err := GenerateHlsKey(player.Id)
+	if err != nil {
+		log.Errorf("UploadStreamingFileOnly 生成密钥失败, player_id: %s, error: %s", player.Id, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileStreamingFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileStreamingFailed.Error()).Detail)
+		return
+	}
+
+	// 生成多码率音轨及master m3u8
+	hifiPlaylist, err := GenerateAudioHls(player.Id, srcFile, keyInfoFile, audioFilter)
 	if err != nil {
-		log.Errorf("UploadStreamingFileOnly 生成m3u8文件失败, srcFile: %s, m3u8File: %s, error: %s", srcFile, m3u8FilePath, err.Error())
+		log.Errorf("UploadStreamingFileOnly 生成m3u8文件失败, srcFile: %s, error: %s", srcFile, err.Error())
 		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileStreamingFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileStreamingFailed.Error()).Detail)
 		return
 	}
+	// 生成试听片段, 失败不影响完整音频
+	if previewUrl, previewDuration, previewErr := GeneratePreview(player.Id, srcFile, player.Duration, audioFilter); previewErr != nil {
+		log.Errorf("UploadStreamingFileOnly 生成试听片段失败, srcFile: %s, error: %s", srcFile, previewErr.Error())
+	} else {
+		player.PreviewUrl = previewUrl
+		player.PreviewDuration = previewDuration
+	}
+	// 生成波形峰值, 失败不影响完整音频
+	if waveformErr := GenerateWaveform(player.Id, srcFile, float64(player.Duration), audioFilter); waveformErr != nil {
+		log.Errorf("UploadStreamingFileOnly 生成波形峰值失败, srcFile: %s, error: %s", srcFile, waveformErr.Error())
+	}
+
+	// 发布流媒体文件到存储
+	if err = publishStreamingFiles(player.Id); err != nil {
+		log.Errorf("UploadStreamingFileOnly 发布流媒体文件失败, player_id: %s, error: %s", player.Id, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileStreamingFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileStreamingFailed.Error()).Detail)
+		return
+	}
+	if err = storage.PutFile(storage.Default, storage.Key(model.StreamFileUploadPath, newFilename), srcFile, ""); err != nil {
+		log.Errorf("UploadStreamingFileOnly 源文件写入存储失败, srcFile: %s, error: %s", srcFile, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
+	if !storage.IsLocal(storage.Default) {
+		removeLocalFiles(srcFile)
+	}
+
 	// 更新player数据
 	player.PlayType = router_model.ProductsPlayerPlayTypeHls
 	player.PlayUrl = config.StreamConfig.Host + player.Id + ".m3u8"
+	if hifiPlaylist != "" {
+		player.HifiUrl = config.StreamConfig.Host + hifiPlaylist
+	}
+	player.KeyFile = keyFile
 	player.Status = router_model.ProductsPlayerStatusOk
 	player.CreateAt = time.Now()
 	player.UpdateAt = time.Now()
@@ -226,9 +266,9 @@ func UploadStreamingFileOnly(c *gin.Context) {
 }
 
 // @Title		 播放流媒体文件
-// @Description  获取hls流媒体文件
+// @Description  获取hls流媒体文件, 需携带播放凭证token
 // @Response     file
-// @Router       /v1/steaming/player/:filename [get]
+// @Router       /v1/steaming/player/:filename?token= [get]
 func StreamingPlayer(c *gin.Context) {
 	// 请求参数校验
 	filename := c.Param("filename")
@@ -238,46 +278,223 @@ func StreamingPlayer(c *gin.Context) {
 		return
 	}
 
+	// 检查请求文件类型是否为m3u8或分片
+	if !common.CheckFileTypes(filename, HlsPlayerFileTypeList) {
+		log.Errorf("StreamingPlayer 请求参数格式错误, filename:%s", filename)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
+		return
+	}
+
+	// 校验播放凭证
+	token := c.Query("token")
+	claims, err := middleware.ValidatePlaybackToken(token)
+	if err != nil {
+		log.Errorf("StreamingPlayer 播放凭证无效, filename:%s, error:%v", filename, err)
+		api.FailWithAuthorization(c)
+		return
+	}
+	// 播放凭证仅对所属player的文件有效
+	if playerIdFromFilename(filename) != claims.PlayerId {
+		log.Errorf("StreamingPlayer 播放凭证与请求文件不匹配, filename:%s, player_id:%s, user_id:%s", filename, claims.PlayerId, claims.UserId)
+		api.FailWithAuthorization(c)
+		return
+	}
+	log.Infof("StreamingPlayer 请求参数, filename:%s, player_id:%s, user_id:%s", filename, claims.PlayerId, claims.UserId)
+
+	// 判断文件是否存在
+	fileKey := storage.Key(model.StreamFileSegmentPath, filename)
+	info, err := storage.Default.Stat(fileKey)
+	if err != nil {
+		log.Errorf("StreamingPlayer 文件不存在, fileKey:%s, error:%v", fileKey, err)
+		api.FailWithFileNotFound(c)
+		return
+	}
+
+	// 同时播放会话数限制
+	if err = checkPlaybackConcurrency(c, claims, token); err != nil {
+		return
+	}
+
+	// 分片直接返回
+	if strings.ToLower(filepath.Ext(filename)) != ".m3u8" {
+		log.Infof("StreamingPlayer 分片请求成功, fileKey:%s", fileKey)
+		recordPlaybackSegment(claims, token, filename)
+		serveSegmentObject(c, info)
+		return
+	}
+
+	// m3u8请求时复核用户购买权限
+	player, err := checkPlaybackEntitlement(c, claims)
+	if err != nil {
+		return
+	}
+	detectPlaybackSharing(c, claims)
+
+	// 重写m3u8, 为每个分片地址追加相同的播放凭证
+	content, err := storage.ReadAll(storage.Default, fileKey)
+	if err == nil {
+		content, err = RewriteM3u8WithToken(content, token)
+	}
+	if err != nil {
+		log.Errorf("StreamingPlayer 重写m3u8文件失败, fileKey:%s, error:%v", fileKey, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Code, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Detail)
+		return
+	}
+
+	log.Infof("StreamingPlayer 文件请求成功, fileKey:%s", fileKey)
+	recordPlaybackStart(c, claims, token, player)
+
+	c.Header("Cache-Control", PlaylistCacheControl)
+	c.Header("ETag", contentETag(content))
+	serveContent(c, fileKey, info.ModTime, content)
+}
+
+// @Title		 播放试听文件
+// @Description  获取hls试听文件, 无需播放凭证
+// @Response     file
+// @Router       /v1/steaming/preview/:filename [get]
+func StreamingPreview(c *gin.Context) {
+	// 请求参数校验
+	filename := c.Param("filename")
+	if filename == "" || !strings.Contains(filename, PreviewFileSuffix) {
+		log.Errorf("StreamingPreview 请求参数错误, filename:%s", filename)
+		api.FailWithFileNotFound(c)
+		return
+	}
+
 	// 检查请求文件类型是否为m3u8或ts
 	if !common.CheckFileTypes(filename, []string{".m3u8", ".ts"}) {
-		log.Errorf("StreamingPlayer 请求参数格式错误, filename:%s", filename)
+		log.Errorf("StreamingPreview 请求参数格式错误, filename:%s", filename)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
+		return
+	}
+
+	// 判断文件是否存在
+	fileKey := storage.Key(model.StreamFilePreviewPath, filename)
+	info, err := storage.Default.Stat(fileKey)
+	if err != nil {
+		api.FailWithFileNotFound(c)
+		return
+	}
+
+	log.Infof("StreamingPreview 文件请求成功, fileKey:%s", fileKey)
+	if strings.ToLower(filepath.Ext(filename)) != ".m3u8" {
+		serveSegmentObject(c, info)
+		return
+	}
+	c.Header("Cache-Control", PlaylistCacheControl)
+	c.Header("ETag", storageObjectETag(info))
+	serveStorageObject(c, fileKey)
+}
+
+// @Title		 获取封面
+// @Description  获取音频内嵌封面, 公开访问
+// @Response     file
+// @Router       /v1/steaming/cover/:filename [get]
+func StreamingCover(c *gin.Context) {
+	// 请求参数校验
+	filename := c.Param("filename")
+	if filename == "" || !strings.Contains(filename, model.CoverFileSuffix) {
+		log.Errorf("StreamingCover 请求参数错误, filename:%s", filename)
+		api.FailWithFileNotFound(c)
+		return
+	}
+
+	// 检查请求文件类型是否为jpg或png
+	if !common.CheckFileTypes(filename, []string{".jpg", ".png"}) {
+		log.Errorf("StreamingCover 请求参数格式错误, filename:%s", filename)
 		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
 		return
 	}
-	log.Infof("StreamingPlayer 请求参数, filename:%s", filename)
 
 	// 判断文件是否存在
-	filePath := filepath.Join(model.StreamFileSegmentPath, filename)
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	fileKey := storage.Key(model.StreamFileCoverPath, filename)
+	if _, err := storage.Default.Stat(fileKey); err != nil {
 		api.FailWithFileNotFound(c)
 		return
 	}
 
-	// TODO 文件请求成功，日志记录，新增缓存
-	log.Infof("StreamingPlayer 文件请求成功, filePath:%s", filePath)
-	// cache key: IP-用户-m3u8文件
+	serveStorageObject(c, fileKey)
+}
+
+// @Title		 获取流媒体解密密钥
+// @Description  获取hls分片AES-128密钥, 需携带播放凭证token
+// @Response     file
+// @Router       /v1/steaming/key/:player_id?token= [get]
+func StreamingKey(c *gin.Context) {
+	// 请求参数校验
+	playerId := c.Param("player_id")
+	if playerId == "" {
+		log.Errorf("StreamingKey 请求参数错误, player_id为空")
+		api.FailWithFileNotFound(c)
+		return
+	}
 
-	c.File(filePath)
+	// 校验播放凭证
+	claims, err := middleware.ValidatePlaybackToken(c.Query("token"))
+	if err != nil {
+		log.Errorf("StreamingKey 播放凭证无效, player_id:%s, error:%v", playerId, err)
+		api.FailWithAuthorization(c)
+		return
+	}
+	if claims.PlayerId != playerId {
+		log.Errorf("StreamingKey 播放凭证与请求密钥不匹配, player_id:%s, claims.player_id:%s, user_id:%s", playerId, claims.PlayerId, claims.UserId)
+		api.FailWithAuthorization(c)
+		return
+	}
+
+	// 复核用户购买权限
+	player, err := checkPlaybackEntitlement(c, claims)
+	if err != nil {
+		return
+	}
+	detectPlaybackSharing(c, claims)
+	if player.KeyFile == "" {
+		log.Errorf("StreamingKey player未配置密钥, player_id:%s", playerId)
+		api.FailWithFileNotFound(c)
+		return
+	}
+
+	// 读取密钥
+	key, err := storage.ReadAll(storage.Default, storage.Key(model.StreamFileKeyPath, filepath.Base(player.KeyFile)))
+	if err != nil {
+		log.Errorf("StreamingKey 读取密钥失败, player_id:%s, key_file:%s, error:%v", playerId, player.KeyFile, err)
+		api.FailWithFileNotFound(c)
+		return
+	}
+
+	log.Infof("StreamingKey 密钥请求成功, player_id:%s, user_id:%s", playerId, claims.UserId)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/octet-stream", key)
 }
 
-// 验证文件类型的魔数校验函数(暂不使用)
-func validateFileType(buffer []byte) bool {
-	println("DEBUG validateFileType buffer: ", buffer)
-	// mp3 文件魔数
-	if bytes.HasPrefix(buffer, []byte{0x49, 0x44, 0x33}) {
-		return true
+// 从hls文件名提取player_id, 取第一个_或.之前的部分
+// 2c6443db0a3c4c18aa3eeb4c8775_128k_0.ts -> 2c6443db0a3c4c18aa3eeb4c8775
+func playerIdFromFilename(filename string) string {
+	if idx := strings.IndexAny(filename, "_."); idx >= 0 {
+		return filename[:idx]
 	}
-	// wav 文件魔数
-	if bytes.HasPrefix(buffer, []byte{0x52, 0x49, 0x46, 0x46}) {
-		return true
+	return filename
+}
+
+// 校验播放凭证对应用户是否拥有player所属商品的播放权限, 校验失败时已写入响应
+func checkPlaybackEntitlement(c *gin.Context, claims *middleware.PlaybackClaims) (*router_model.ProductsPlayer, error) {
+	player, err := router_dao.GetProductsPlayerById(claims.PlayerId)
+	if err != nil || player.Status != router_model.ProductsPlayerStatusOk {
+		log.Errorf("checkPlaybackEntitlement 查询player失败或player不可用, player_id:%s, player:%+v, error:%v", claims.PlayerId, player, err)
+		api.FailWithFileNotFound(c)
+		return nil, errors.New("player不可用")
 	}
-	// m4a 文件魔数
-	if bytes.HasPrefix(buffer, []byte{0x4D, 0x41, 0x44, 0x46}) {
-		return true
+	purchased, err := router_dao.CheckUserPurchasedProduct(claims.UserId, player.ProductId)
+	if err != nil {
+		log.Errorf("checkPlaybackEntitlement 查询用户购买记录失败, user_id:%s, product_id:%s, error:%v", claims.UserId, player.ProductId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Code, uerrors.Parse(uerrors.ErrDbQueryFail.Error()).Detail)
+		return nil, err
 	}
-	// mpeg 文件魔数
-	if bytes.HasPrefix(buffer, []byte{0x4D, 0x50, 0x45, 0x47}) {
-		return true
+	if !purchased {
+		log.Errorf("checkPlaybackEntitlement 用户未购买该商品, user_id:%s, product_id:%s", claims.UserId, player.ProductId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamNotPurchased.Error()).Code, uerrors.Parse(uerrors.ErrorStreamNotPurchased.Error()).Detail)
+		return nil, errors.New("用户未购买该商品")
 	}
-	return false
+	return player, nil
 }
@@ -0,0 +1,420 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"eshop_server/src/common/api"
+	"eshop_server/src/common/cache"
+	router_dao "eshop_server/src/router/dao"
+	router_model "eshop_server/src/router/model"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/config"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
+	"eshop_server/src/utils/uuid"
+	"fmt"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TranscodePopTimeout         = 5 * time.Second  // 转码队列阻塞等待时长
+	TranscodeErrorBackoff       = 3 * time.Second  // 转码队列异常时等待时长
+	TranscodeLeaseRenewInterval = 10 * time.Second // 节点租约续期间隔, 需小于租约有效时长
+)
+
+var (
+	transcodeNodeId = uuid.GetUuid() // 本节点id, 每次启动重新生成, 处理中任务按节点隔离
+)
+
+// InitTranscodeWorkers 恢复中断的转码任务并启动转码协程池
+// 多节点部署时仅回收租约已过期节点的处理中任务, 不影响其他节点正在处理的任务
+func InitTranscodeWorkers() {
+	if err := cache.RenewStreamTranscodeLease(transcodeNodeId); err != nil {
+		log.Errorf("InitTranscodeWorkers 续期节点租约失败, node_id: %s, error: %v", transcodeNodeId, err)
+	}
+	requeueExpiredTranscodeJobs()
+	go transcodeLeaseKeeper()
+
+	workers := config.StreamConfig.Transcode.Workers
+	if workers <= 0 {
+		workers = model.TranscodeDefaultWorkers
+	}
+	for i := 0; i < workers; i++ {
+		go transcodeWorker(i)
+	}
+	log.Infof("InitTranscodeWorkers 启动转码协程成功, workers: %d", workers)
+}
+
+// EnqueueTranscodeJob 创建转码任务并加入队列
+// @param playerId 播放id
+// @param srcFile 源文件存储key
+// @param autoFill 是否根据元数据自动填充商品标题及图片
+// @param replacePlayerId 被替换的播放id, 为空时不替换
+func EnqueueTranscodeJob(playerId string, srcFile string, autoFill bool, replacePlayerId string) (job *model.TranscodeJob, err error) {
+	maxRetry := config.StreamConfig.Transcode.MaxRetry
+	if maxRetry <= 0 {
+		maxRetry = model.TranscodeDefaultMaxRetry
+	}
+	now := time.Now().Unix()
+	job = &model.TranscodeJob{
+		PlayerId:  playerId,
+		SrcFile:   srcFile,
+		AutoFill:  autoFill,
+		Status:    model.TranscodeJobStatusPending,
+		MaxRetry:  maxRetry,
+		CreatedAt: now,
+		UpdatedAt: now,
+
+		ReplacePlayerId: replacePlayerId,
+	}
+	if err = saveTranscodeJob(job); err != nil {
+		return nil, err
+	}
+	if err = cache.PushStreamTranscodeQueue(playerId); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// 获取转码任务
+func getTranscodeJob(playerId string) (job *model.TranscodeJob, err error) {
+	ok, b := cache.GetStreamTranscodeJob(playerId)
+	if !ok {
+		return nil, errors.New("转码任务不存在")
+	}
+	job = new(model.TranscodeJob)
+	if err = json.Unmarshal(b, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// 保存转码任务
+func saveTranscodeJob(job *model.TranscodeJob) error {
+	job.UpdatedAt = time.Now().Unix()
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return cache.SaveStreamTranscodeJob(job.PlayerId, b)
+}
+
+// 回收租约已过期节点中断的转码任务
+func requeueExpiredTranscodeJobs() {
+	count, err := cache.RequeueExpiredStreamTranscodeLeases()
+	if err != nil {
+		log.Errorf("requeueExpiredTranscodeJobs 恢复中断的转码任务失败, error: %v", err)
+	} else if count > 0 {
+		log.Infof("requeueExpiredTranscodeJobs 恢复中断的转码任务, count: %d", count)
+	}
+}
+
+// 节点租约心跳协程, 定期续期本节点租约并回收其他已过期节点的任务
+func transcodeLeaseKeeper() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("transcodeLeaseKeeper 租约协程异常退出, panic: %v", r)
+			go transcodeLeaseKeeper()
+		}
+	}()
+
+	ticker := time.NewTicker(TranscodeLeaseRenewInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := cache.RenewStreamTranscodeLease(transcodeNodeId); err != nil {
+			log.Errorf("transcodeLeaseKeeper 续期节点租约失败, node_id: %s, error: %v", transcodeNodeId, err)
+			continue
+		}
+		requeueExpiredTranscodeJobs()
+	}
+}
+
+// 转码协程, 循环消费转码队列
+func transcodeWorker(idx int) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("transcodeWorker 转码协程异常退出, idx: %d, panic: %v", idx, r)
+			go transcodeWorker(idx)
+		}
+	}()
+
+	for {
+		playerId, err := cache.PopStreamTranscodeQueue(transcodeNodeId, TranscodePopTimeout)
+		if err != nil {
+			log.Errorf("transcodeWorker 获取转码任务失败, idx: %d, error: %v", idx, err)
+			time.Sleep(TranscodeErrorBackoff)
+			continue
+		}
+		if playerId == "" {
+			continue
+		}
+		handleTranscodeJob(playerId)
+		if err = cache.AckStreamTranscodeQueue(transcodeNodeId, playerId); err != nil {
+			log.Errorf("transcodeWorker 确认转码任务失败, player_id: %s, error: %v", playerId, err)
+		}
+	}
+}
+
+// 处理单个转码任务, 失败时按重试次数重新入队或标记失败
+func handleTranscodeJob(playerId string) {
+	job, err := getTranscodeJob(playerId)
+	if err != nil {
+		log.Errorf("handleTranscodeJob 获取转码任务失败, player_id: %s, error: %v", playerId, err)
+		return
+	}
+	player, err := router_dao.GetProductsPlayerById(playerId)
+	if err != nil {
+		log.Errorf("handleTranscodeJob 获取ProductsPlayer记录失败, player_id: %s, error: %v", playerId, err)
+		job.Status = model.TranscodeJobStatusFailed
+		job.Error = "播放记录不存在"
+		_ = saveTranscodeJob(job)
+		return
+	}
+	// 排队期间已下架的播放记录不再转码
+	if player.Status == router_model.ProductsPlayerStatusInvalid {
+		log.Errorf("handleTranscodeJob 播放记录已下架, 跳过转码, player_id: %s", playerId)
+		job.Status = model.TranscodeJobStatusFailed
+		job.Error = "播放记录已下架"
+		_ = saveTranscodeJob(job)
+		return
+	}
+
+	// 标记解析中
+	job.Status = model.TranscodeJobStatusRunning
+	job.Attempts++
+	if err = saveTranscodeJob(job); err != nil {
+		log.Errorf("handleTranscodeJob 保存转码任务失败, player_id: %s, error: %v", playerId, err)
+	}
+	player.Status = router_model.ProductsPlayerStatusParsing
+	if _, err = router_dao.UpdateProductsPlayerByField(player, []string{"status"}); err != nil {
+		log.Errorf("handleTranscodeJob 更新ProductsPlayer记录失败, player: %+v, error: %v", player, err)
+	}
+
+	log.Infof("handleTranscodeJob 开始转码, player_id: %s, attempts: %d", playerId, job.Attempts)
+	if err = transcodePlayer(player, job); err == nil {
+		job.Status = model.TranscodeJobStatusSuccess
+		job.Error = ""
+		if err = saveTranscodeJob(job); err != nil {
+			log.Errorf("handleTranscodeJob 保存转码任务失败, player_id: %s, error: %v", playerId, err)
+		}
+		log.Infof("handleTranscodeJob 转码成功, player_id: %s", playerId)
+		return
+	}
+
+	// 转码失败
+	log.Errorf("handleTranscodeJob 转码失败, player_id: %s, attempts: %d, error: %v", playerId, job.Attempts, err)
+	job.Error = err.Error()
+	if job.Attempts < job.MaxRetry {
+		job.Status = model.TranscodeJobStatusPending
+		if err = saveTranscodeJob(job); err == nil {
+			err = cache.PushStreamTranscodeQueue(playerId)
+		}
+		if err == nil {
+			return
+		}
+		log.Errorf("handleTranscodeJob 转码任务重新入队失败, player_id: %s, error: %v", playerId, err)
+	}
+	job.Status = model.TranscodeJobStatusFailed
+	if err = saveTranscodeJob(job); err != nil {
+		log.Errorf("handleTranscodeJob 保存转码任务失败, player_id: %s, error: %v", playerId, err)
+	}
+	player.Status = router_model.ProductsPlayerStatusError
+	if _, err = router_dao.UpdateProductsPlayerByField(player, []string{"status"}); err != nil {
+		log.Errorf("handleTranscodeJob 更新ProductsPlayer记录失败, player: %+v, error: %v", player, err)
+	}
+}
+
+// 执行转码: 获取时长及元数据、响度标准化、生成密钥、多码率切片、试听片段及波形, 发布到存储并更新数据库为就绪
+// 替换任务在同一事务中上架新播放记录并下架旧播放记录, 随后回收旧文件
+// @param job 转码任务, 包含源文件存储key、是否自动填充及被替换的播放id
+func transcodePlayer(player *router_model.ProductsPlayer, job *model.TranscodeJob) (err error) {
+	// 源文件不在本节点时从存储下载
+	srcKey := job.SrcFile
+	srcFile := filepath.Join(model.StreamFileUploadPath, path.Base(srcKey))
+	if err = storage.FetchFile(storage.Default, srcKey, srcFile); err != nil {
+		return fmt.Errorf("获取源文件失败: %w", err)
+	}
+	if !storage.IsLocal(storage.Default) {
+		defer removeLocalFiles(srcFile)
+	}
+
+	player.Duration, err = GetMediaDuration(srcFile)
+	if err != nil {
+		return fmt.Errorf("获取文件时长失败: %w", err)
+	}
+
+	// 解析元数据及内嵌封面, 失败不影响转码
+	meta, metaErr := ExtractPlayerMetadata(player, srcFile)
+	if metaErr != nil {
+		log.Errorf("transcodePlayer 解析元数据失败, srcFile: %s, error: %v", srcFile, metaErr)
+	}
+
+	// 响度标准化测量, 失败时保持原始响度
+	sampleRate, duration := int64(0), float64(player.Duration)
+	if meta != nil {
+		sampleRate = meta.SampleRate
+		if meta.Duration > 0 {
+			duration = meta.Duration
+		}
+	}
+	audioFilter, loudnormErr := GetLoudnormFilter(srcFile, sampleRate)
+	if loudnormErr != nil {
+		log.Errorf("transcodePlayer 响度标准化测量失败, srcFile: %s, error: %v", srcFile, loudnormErr)
+	}
+
+	// 生成AES-128密钥
+	keyFile, keyInfoFile, err := GenerateHlsKey(player.Id)
+	if err != nil {
+		return fmt.Errorf("生成密钥失败: %w", err)
+	}
+
+	// 生成多码率音轨及master m3u8
+	hifiPlaylist, err := GenerateAudioHls(player.Id, srcFile, keyInfoFile, audioFilter)
+	if err != nil {
+		return fmt.Errorf("生成m3u8文件失败: %w", err)
+	}
+
+	// 生成试听片段, 失败不影响完整音频
+	if previewUrl, previewDuration, previewErr := GeneratePreview(player.Id, srcFile, player.Duration, audioFilter); previewErr != nil {
+		log.Errorf("transcodePlayer 生成试听片段失败, srcFile: %s, error: %s", srcFile, previewErr.Error())
+	} else {
+		player.PreviewUrl = previewUrl
+		player.PreviewDuration = previewDuration
+	}
+
+	// 生成波形峰值, 失败不影响完整音频
+	if waveformErr := GenerateWaveform(player.Id, srcFile, duration, audioFilter); waveformErr != nil {
+		log.Errorf("transcodePlayer 生成波形峰值失败, srcFile: %s, error: %v", srcFile, waveformErr)
+	}
+
+	// 发布流媒体文件到存储
+	if err = publishStreamingFiles(player.Id); err != nil {
+		return fmt.Errorf("发布流媒体文件失败: %w", err)
+	}
+
+	// 更新数据库
+	player.PlayType = router_model.ProductsPlayerPlayTypeHls
+	player.PlayUrl = config.StreamConfig.Host + player.Id + ".m3u8"
+	player.HifiUrl = ""
+	if hifiPlaylist != "" {
+		player.HifiUrl = config.StreamConfig.Host + hifiPlaylist
+	}
+	player.KeyFile = keyFile
+	player.Status = router_model.ProductsPlayerStatusOk
+	update_fields := []string{"duration", "play_type", "play_url", "hifi_url", "key_file", "preview_url", "preview_duration", "status"}
+	// 自动填充时以标签标题作为曲目标题, 替换音频时保留原曲目标题
+	if job.AutoFill && job.ReplacePlayerId == "" && meta != nil && meta.Title != "" {
+		player.Title = truncateRunes(meta.Title, playerTitleMaxLength)
+		update_fields = append(update_fields, "title")
+	}
+	if job.ReplacePlayerId == "" {
+		if _, err = router_dao.UpdateProductsPlayerByField(player, update_fields); err != nil {
+			return fmt.Errorf("更新ProductsPlayer记录失败: %w", err)
+		}
+	} else {
+		if _, err = router_dao.SwapProductsPlayer(player, update_fields, job.ReplacePlayerId); err != nil {
+			return fmt.Errorf("替换ProductsPlayer记录失败: %w", err)
+		}
+		log.Infof("transcodePlayer 替换播放记录成功, player_id: %s, replace_player_id: %s", player.Id, job.ReplacePlayerId)
+		if _, recycleErr := recyclePlayerFiles(job.ReplacePlayerId); recycleErr != nil {
+			log.Errorf("transcodePlayer 回收旧播放文件失败, replace_player_id: %s, error: %v", job.ReplacePlayerId, recycleErr)
+		}
+	}
+
+	// 保存元数据
+	if meta != nil {
+		if _, metaErr = router_dao.ReplaceProductsPlayerMetadata(meta); metaErr != nil {
+			log.Errorf("transcodePlayer 保存元数据失败, player_id: %s, error: %v", player.Id, metaErr)
+		} else if job.AutoFill {
+			autoFillProduct(meta)
+		}
+	}
+	return nil
+}
+
+// @Title		 查询转码任务
+// @Description  查询流媒体转码任务状态
+// @Response     json
+// @Router       /v1/steaming/transcode/:player_id [get]
+func GetTranscodeJob(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	playerId := c.Param("player_id")
+	job, err := getTranscodeJob(playerId)
+	if err != nil {
+		log.Errorf("GetTranscodeJob 获取转码任务失败, player_id: %s, error: %v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotFound.Error()).Detail)
+		return
+	}
+	player, err := router_dao.GetProductsPlayerById(playerId)
+	if err != nil {
+		log.Errorf("GetTranscodeJob 获取ProductsPlayer记录失败, player_id: %s, error: %v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotFound.Error()).Detail)
+		return
+	}
+
+	// 元数据在转码成功后写入, 不存在时返回空
+	metadata, _ := router_dao.GetProductsPlayerMetadataByPlayerId(playerId)
+
+	dataMap["job"] = job
+	dataMap["result"] = player
+	dataMap["metadata"] = metadata
+	api.Success(c, dataMap)
+}
+
+// @Title		 重试转码任务
+// @Description  将处理失败的转码任务重新加入队列
+// @Response     json
+// @Router       /v1/steaming/transcode/:player_id/retry [post]
+func RetryTranscodeJob(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	playerId := c.Param("player_id")
+	job, err := getTranscodeJob(playerId)
+	if err != nil {
+		log.Errorf("RetryTranscodeJob 获取转码任务失败, player_id: %s, error: %v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotFound.Error()).Detail)
+		return
+	}
+	if job.Status != model.TranscodeJobStatusFailed {
+		log.Errorf("RetryTranscodeJob 转码任务状态不可重试, player_id: %s, status: %s", playerId, job.Status)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotRetry.Error()).Code, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotRetry.Error()).Detail)
+		return
+	}
+	player, err := router_dao.GetProductsPlayerById(playerId)
+	if err != nil {
+		log.Errorf("RetryTranscodeJob 获取ProductsPlayer记录失败, player_id: %s, error: %v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotFound.Error()).Detail)
+		return
+	}
+	// 仅异常状态可重试, 已下架或已隔离的player重试会使其重新上线
+	if player.Status != router_model.ProductsPlayerStatusError {
+		log.Errorf("RetryTranscodeJob player状态不可重试, player_id: %s, status: %d", playerId, player.Status)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotRetry.Error()).Code, uerrors.Parse(uerrors.ErrorStreamTranscodeJobNotRetry.Error()).Detail)
+		return
+	}
+
+	// 重置player状态并重新入队
+	player.Status = router_model.ProductsPlayerStatusInit
+	if _, err = router_dao.UpdateProductsPlayerByField(player, []string{"status"}); err != nil {
+		log.Errorf("RetryTranscodeJob 更新ProductsPlayer记录失败, player: %+v, error: %v", player, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Code, uerrors.Parse(uerrors.ErrDboperationFail.Error()).Detail)
+		return
+	}
+	job, err = EnqueueTranscodeJob(playerId, job.SrcFile, job.AutoFill, job.ReplacePlayerId)
+	if err != nil {
+		log.Errorf("RetryTranscodeJob 转码任务入队失败, player_id: %s, error: %v", playerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Code, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Detail)
+		return
+	}
+	log.Infof("RetryTranscodeJob 转码任务重新入队成功, player_id: %s", playerId)
+
+	dataMap["job"] = job
+	dataMap["result"] = player
+	api.Success(c, dataMap)
+}
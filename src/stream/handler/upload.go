@@ -0,0 +1,435 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"eshop_server/src/common/api"
+	"eshop_server/src/common/cache"
+	router_dao "eshop_server/src/router/dao"
+	router_model "eshop_server/src/router/model"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/common"
+	"eshop_server/src/utils/config"
+	uerrors "eshop_server/src/utils/errors"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
+	"eshop_server/src/utils/uuid"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 获取分片存储key
+// uploads/chunks/{upload_id}/{index}
+func getUploadChunkKey(uploadId string, index int64) string {
+	return storage.Key(model.StreamFileUploadPath, model.UploadChunkPath, uploadId, strconv.FormatInt(index, 10))
+}
+
+// 获取分片上传任务
+func getUploadSession(uploadId string) (session *model.UploadSession, err error) {
+	ok, b := cache.GetStreamUpload(uploadId)
+	if !ok {
+		return nil, errors.New("上传任务不存在")
+	}
+	session = new(model.UploadSession)
+	if err = json.Unmarshal(b, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// 获取已接收的分片序号列表
+func getUploadedChunks(uploadId string) (res []int64, err error) {
+	chunks, err := cache.GetStreamUploadChunks(uploadId)
+	if err != nil {
+		return nil, err
+	}
+	res = make([]int64, 0, len(chunks))
+	for k := range chunks {
+		index, parseErr := strconv.ParseInt(k, 10, 64)
+		if parseErr != nil {
+			continue
+		}
+		res = append(res, index)
+	}
+	return res, nil
+}
+
+// 清理分片及上传任务
+func cleanUploadChunks(session *model.UploadSession) {
+	for i := int64(0); i < session.TotalChunks; i++ {
+		if err := storage.Default.Delete(getUploadChunkKey(session.UploadId, i)); err != nil {
+			log.Errorf("cleanUploadChunks 清理分片失败, upload_id: %s, index: %d, error: %v", session.UploadId, i, err)
+		}
+	}
+	cache.DelStreamUpload(session.UploadId)
+}
+
+// 校验源文件, 写入存储并创建转码任务
+// @param player 播放记录
+// @param srcFile 本地源文件路径
+// @param autoFill 是否根据元数据自动填充商品标题及图片
+// @param replacePlayerId 被替换的播放id, 为空时不替换
+func submitUploadedFile(player *router_model.ProductsPlayer, srcFile string, autoFill bool, replacePlayerId string) (job *model.TranscodeJob, err error) {
+	// 校验文件内容, 不通过时隔离, 不进入转码
+	if err = checkUploadedFile(player, srcFile); err != nil {
+		return nil, err
+	}
+
+	// 源文件写入存储, 供任意节点的转码协程读取
+	srcKey := storage.Key(model.StreamFileUploadPath, filepath.Base(srcFile))
+	if err = storage.PutFile(storage.Default, srcKey, srcFile, ""); err != nil {
+		return nil, fmt.Errorf("源文件写入存储失败: %w", err)
+	}
+	if !storage.IsLocal(storage.Default) {
+		removeLocalFiles(srcFile)
+	}
+
+	// 更新数据库
+	player.Filename = player.Id
+	if _, err = router_dao.UpdateProductsPlayerByField(player, []string{"filename", "file_size"}); err != nil {
+		return nil, fmt.Errorf("更新ProductsPlayer记录失败: %w", err)
+	}
+
+	// 创建转码任务, 由转码协程异步处理
+	job, err = EnqueueTranscodeJob(player.Id, srcKey, autoFill, replacePlayerId)
+	if err != nil {
+		return nil, fmt.Errorf("创建转码任务失败: %w", err)
+	}
+	return job, nil
+}
+
+// 设置新播放记录的曲目信息
+// 替换音频时沿用被替换记录的碟号、曲目序号及标题, 否则以文件名为标题追加到默认碟末尾
+func assignPlayerTrack(player *router_model.ProductsPlayer, replace *router_model.ProductsPlayer) error {
+	if replace != nil {
+		player.DiscNo = replace.DiscNo
+		player.TrackNo = replace.TrackNo
+		player.Title = replace.Title
+		return nil
+	}
+	trackNo, err := router_dao.GetProductsPlayerMaxTrackNo(player.ProductId, router_model.ProductsPlayerDefaultDiscNo)
+	if err != nil {
+		return err
+	}
+	player.DiscNo = router_model.ProductsPlayerDefaultDiscNo
+	player.TrackNo = trackNo + 1
+	player.Title = player.Filename
+	return nil
+}
+
+// @Title		 初始化分片上传
+// @Description  创建分片上传任务及ProductsPlayer记录, 返回upload_id及分片信息
+// @Response     json
+// @Router       /v1/steaming/upload/init [post]
+func InitUpload(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	// 解析请求参数
+	var req model.UploadInitReq
+	if err = c.ShouldBindJSON(&req); err != nil {
+		log.Errorf("InitUpload 解析请求体失败, error: %s", err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail)
+		return
+	}
+	if req.ProductId == "" || req.Filename == "" || req.FileSize <= 0 {
+		log.Errorf("InitUpload 请求参数错误, req: %+v", req)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":缺失必要参数")
+		return
+	}
+	if !common.CheckFileTypes(req.Filename, router_model.ProductPlayerSupportFileTypeList) {
+		log.Errorf("InitUpload 请求参数错误, 文件类型必须为mp3或wav, filename: %s", req.Filename)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":不支持该文件类型")
+		return
+	}
+	if req.FileSize > getUploadMaxSize() {
+		log.Errorf("InitUpload 文件大小超出限制, filename: %s, file_size: %d", req.Filename, req.FileSize)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileTooLarge.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileTooLarge.Error()).Detail)
+		return
+	}
+	// 替换音频时, 被替换的播放记录须属于同一商品且未下架
+	var old *router_model.ProductsPlayer
+	if req.ReplacePlayerId != "" {
+		var getErr error
+		old, getErr = router_dao.GetProductsPlayerById(req.ReplacePlayerId)
+		if getErr != nil || old.ProductId != req.ProductId || old.Status == router_model.ProductsPlayerStatusInvalid {
+			log.Errorf("InitUpload 被替换的播放记录无效, req: %+v, old: %+v, error: %v", req, old, getErr)
+			api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":被替换的播放记录无效")
+			return
+		}
+	}
+
+	// 分片大小
+	chunkSize := req.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = config.StreamConfig.UploadChunkSize
+	}
+	if chunkSize <= 0 {
+		chunkSize = model.UploadDefaultChunkSize
+	}
+	if chunkSize > model.UploadMaxChunkSize {
+		chunkSize = model.UploadMaxChunkSize
+	}
+
+	// 创建数据库记录
+	fileType := strings.ToLower(filepath.Ext(req.Filename))
+	player := &router_model.ProductsPlayer{
+		Id:        uuid.GetUuid(),
+		ProductId: req.ProductId,
+		Filename:  strings.TrimSuffix(filepath.Base(req.Filename), filepath.Ext(req.Filename)), // 只保留文件名不包含后缀
+		FileType:  fileType,
+		FileSize:  req.FileSize,
+		Status:    router_model.ProductsPlayerStatusInit,
+	}
+	if err = assignPlayerTrack(player, old); err != nil {
+		log.Errorf("InitUpload 获取曲目序号失败, product_id: %s, error: %s", req.ProductId, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
+	if _, err = router_dao.CreateProductsPlayer(player); err != nil {
+		log.Errorf("InitUpload 创建ProductsPlayer记录失败, filename: %s, error: %s", req.Filename, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
+
+	// 保存上传任务
+	session := &model.UploadSession{
+		UploadId:    uuid.GetUuid(),
+		PlayerId:    player.Id,
+		ProductId:   req.ProductId,
+		Filename:    req.Filename,
+		FileType:    fileType,
+		FileSize:    req.FileSize,
+		ChunkSize:   chunkSize,
+		TotalChunks: (req.FileSize + chunkSize - 1) / chunkSize,
+		Checksum:    strings.ToLower(req.Checksum),
+		AutoFill:    req.AutoFill,
+		CreatedAt:   time.Now().Unix(),
+
+		ReplacePlayerId: req.ReplacePlayerId,
+	}
+	b, _ := json.Marshal(session)
+	if err = cache.SaveStreamUpload(session.UploadId, b); err != nil {
+		log.Errorf("InitUpload 保存上传任务失败, session: %+v, error: %s", session, err.Error())
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
+	log.Infof("InitUpload 创建上传任务成功, session: %+v", session)
+
+	dataMap["result"] = session
+	api.Success(c, dataMap)
+}
+
+// @Title		 上传分片
+// @Description  上传单个分片, 请求体为分片内容, 请求头X-Chunk-Sha256为分片sha256, 重复上传覆盖
+// @Response     json
+// @Router       /v1/steaming/upload/:upload_id/chunk/:index [put]
+func UploadChunk(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	uploadId := c.Param("upload_id")
+	session, err := getUploadSession(uploadId)
+	if err != nil {
+		log.Errorf("UploadChunk 获取上传任务失败, upload_id: %s, error: %v", uploadId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamUploadNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamUploadNotFound.Error()).Detail)
+		return
+	}
+	index, err := strconv.ParseInt(c.Param("index"), 10, 64)
+	if err != nil || index < 0 || index >= session.TotalChunks {
+		log.Errorf("UploadChunk 分片序号错误, upload_id: %s, index: %s", uploadId, c.Param("index"))
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":分片序号错误")
+		return
+	}
+	checksum := strings.ToLower(c.GetHeader(model.UploadChunkChecksumHeader))
+	if checksum == "" {
+		log.Errorf("UploadChunk 缺失分片校验值, upload_id: %s, index: %d", uploadId, index)
+		api.Fail(c, uerrors.Parse(uerrors.ErrParam.Error()).Code, uerrors.Parse(uerrors.ErrParam.Error()).Detail+":缺失分片校验值")
+		return
+	}
+
+	// 读取分片, 最后一个分片允许小于分片大小
+	expectSize := session.ChunkSize
+	if index == session.TotalChunks-1 {
+		expectSize = session.FileSize - session.ChunkSize*index
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, expectSize+1))
+	if err != nil {
+		log.Errorf("UploadChunk 读取分片失败, upload_id: %s, index: %d, error: %v", uploadId, index, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != expectSize || hex.EncodeToString(sum[:]) != checksum {
+		log.Errorf("UploadChunk 分片校验失败, upload_id: %s, index: %d, size: %d, expect_size: %d", uploadId, index, len(data), expectSize)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamUploadChunkInvalid.Error()).Code, uerrors.Parse(uerrors.ErrorStreamUploadChunkInvalid.Error()).Detail)
+		return
+	}
+
+	// 保存分片
+	chunkKey := getUploadChunkKey(uploadId, index)
+	if err = storage.Default.Put(chunkKey, bytes.NewReader(data), int64(len(data)), ""); err != nil {
+		log.Errorf("UploadChunk 保存分片失败, chunk_key: %s, error: %v", chunkKey, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
+	if err = cache.SaveStreamUploadChunk(uploadId, strconv.FormatInt(index, 10), checksum); err != nil {
+		log.Errorf("UploadChunk 记录分片失败, upload_id: %s, index: %d, error: %v", uploadId, index, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
+
+	dataMap["upload_id"] = uploadId
+	dataMap["index"] = index
+	api.Success(c, dataMap)
+}
+
+// @Title		 查询分片上传进度
+// @Description  返回已接收的分片序号, 用于断点续传
+// @Response     json
+// @Router       /v1/steaming/upload/:upload_id [get]
+func GetUploadStatus(c *gin.Context) {
+	dataMap := make(map[string]interface{})
+
+	uploadId := c.Param("upload_id")
+	session, err := getUploadSession(uploadId)
+	if err != nil {
+		log.Errorf("GetUploadStatus 获取上传任务失败, upload_id: %s, error: %v", uploadId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamUploadNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamUploadNotFound.Error()).Detail)
+		return
+	}
+	uploaded, err := getUploadedChunks(uploadId)
+	if err != nil {
+		log.Errorf("GetUploadStatus 获取已接收分片失败, upload_id: %s, error: %v", uploadId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Code, uerrors.Parse(uerrors.ErrorStreamServiceUnknownError.Error()).Detail)
+		return
+	}
+
+	dataMap["result"] = session
+	dataMap["uploaded_chunks"] = uploaded
+	api.Success(c, dataMap)
+}
+
+// @Title		 完成分片上传
+// @Description  校验并合并所有分片到上传目录, 创建转码任务
+// @Response     json
+// @Router       /v1/steaming/upload/:upload_id/complete [post]
+func CompleteUpload(c *gin.Context) {
+	var err error
+	dataMap := make(map[string]interface{})
+
+	uploadId := c.Param("upload_id")
+	session, err := getUploadSession(uploadId)
+	if err != nil {
+		log.Errorf("CompleteUpload 获取上传任务失败, upload_id: %s, error: %v", uploadId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamUploadNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamUploadNotFound.Error()).Detail)
+		return
+	}
+	if !cache.LockStreamUploadComplete(uploadId) {
+		log.Errorf("CompleteUpload 上传任务正在合并, upload_id: %s", uploadId)
+		api.Fail(c, uerrors.Parse(uerrors.ErrBusy.Error()).Code, uerrors.Parse(uerrors.ErrBusy.Error()).Detail)
+		return
+	}
+	defer cache.UnlockStreamUploadComplete(uploadId)
+
+	// 检查分片是否全部上传
+	uploaded, err := getUploadedChunks(uploadId)
+	if err != nil || int64(len(uploaded)) != session.TotalChunks {
+		log.Errorf("CompleteUpload 分片未全部上传, upload_id: %s, uploaded: %d, total: %d, error: %v", uploadId, len(uploaded), session.TotalChunks, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamUploadIncomplete.Error()).Code, uerrors.Parse(uerrors.ErrorStreamUploadIncomplete.Error()).Detail)
+		return
+	}
+
+	player, err := router_dao.GetProductsPlayerById(session.PlayerId)
+	if err != nil {
+		log.Errorf("CompleteUpload 获取ProductsPlayer记录失败, player_id: %s, error: %v", session.PlayerId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamUploadNotFound.Error()).Code, uerrors.Parse(uerrors.ErrorStreamUploadNotFound.Error()).Detail)
+		return
+	}
+
+	// 合并分片
+	srcFile := filepath.Join(model.StreamFileUploadPath, player.Id+session.FileType)
+	if err = assembleUploadChunks(session, srcFile); err != nil {
+		log.Errorf("CompleteUpload 合并分片失败, upload_id: %s, error: %v", uploadId, err)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamUploadIncomplete.Error()).Code, uerrors.Parse(uerrors.ErrorStreamUploadIncomplete.Error()).Detail)
+		return
+	}
+
+	// 写入存储并创建转码任务
+	player.FileSize = session.FileSize
+	job, err := submitUploadedFile(player, srcFile, session.AutoFill, session.ReplacePlayerId)
+	var quarantineErr *QuarantineError
+	if errors.As(err, &quarantineErr) {
+		cleanUploadChunks(session)
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileQuarantined.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileQuarantined.Error()).Detail+":"+quarantineErr.Reason)
+		return
+	}
+	if err != nil {
+		log.Errorf("CompleteUpload 提交源文件失败, player_id: %s, error: %v", player.Id, err)
+		player.Status = router_model.ProductsPlayerStatusError
+		if _, updateErr := router_dao.UpdateProductsPlayerByField(player, []string{"status"}); updateErr != nil {
+			log.Errorf("CompleteUpload 更新ProductsPlayer记录失败, player: %+v, error: %v", player, updateErr)
+		}
+		api.Fail(c, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Code, uerrors.Parse(uerrors.ErrorStreamFileUploadFailed.Error()).Detail)
+		return
+	}
+
+	// 清理分片及上传任务
+	cleanUploadChunks(session)
+	log.Infof("CompleteUpload 分片上传完成, upload_id: %s, player_id: %s", uploadId, player.Id)
+
+	dataMap["job"] = job
+	dataMap["result"] = player
+	api.Success(c, dataMap)
+}
+
+// 按序合并分片到本地文件, 并校验文件大小及整体sha256
+func assembleUploadChunks(session *model.UploadSession, dstFile string) (err error) {
+	tmpFile := dstFile + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(tmpFile)
+		}
+	}()
+
+	hash := sha256.New()
+	w := io.MultiWriter(f, hash)
+	var size int64
+	for i := int64(0); i < session.TotalChunks; i++ {
+		r, getErr := storage.Default.Get(getUploadChunkKey(session.UploadId, i))
+		if getErr != nil {
+			return fmt.Errorf("读取分片%d失败: %w", i, getErr)
+		}
+		n, copyErr := io.Copy(w, r)
+		r.Close()
+		if copyErr != nil {
+			return fmt.Errorf("写入分片%d失败: %w", i, copyErr)
+		}
+		size += n
+	}
+	if size != session.FileSize {
+		return fmt.Errorf("文件大小不一致, size: %d, expect: %d", size, session.FileSize)
+	}
+	if session.Checksum != "" && hex.EncodeToString(hash.Sum(nil)) != session.Checksum {
+		return errors.New("文件sha256校验失败")
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, dstFile)
+}
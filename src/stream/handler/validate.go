@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	router_dao "eshop_server/src/router/dao"
+	router_model "eshop_server/src/router/model"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sniffHeaderSize = 16 // 魔数识别读取的文件头长度
+)
+
+var (
+	audioSnifferMap = map[string]func(header []byte) bool{ // 文件类型对应的魔数识别, 新增支持格式时在此注册
+		".mp3": isMp3Header,
+		".wav": isWavHeader,
+	}
+)
+
+// 源文件校验失败, 文件已隔离
+type QuarantineError struct {
+	Reason string // 隔离原因
+}
+
+func (e *QuarantineError) Error() string {
+	return "源文件校验失败, 已隔离: " + e.Reason
+}
+
+// 获取上传文件大小上限
+func getUploadMaxSize() int64 {
+	if config.StreamConfig.UploadMaxSize > 0 {
+		return config.StreamConfig.UploadMaxSize
+	}
+	return model.UploadDefaultMaxSize
+}
+
+// SniffAudioFileType 根据文件头魔数识别音频文件类型, 无法识别时返回空
+// @return 文件后缀, 如.mp3/.wav
+func SniffAudioFileType(header []byte) string {
+	for fileType, sniffer := range audioSnifferMap {
+		if sniffer(header) {
+			return fileType
+		}
+	}
+	return ""
+}
+
+// mp3: ID3v2标签头, 或无标签时的MPEG音频帧同步头
+func isMp3Header(header []byte) bool {
+	if len(header) >= 10 && bytes.HasPrefix(header, []byte("ID3")) {
+		return true
+	}
+	if len(header) < 4 || header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return false
+	}
+	version := (header[1] >> 3) & 0x03         // 01保留
+	layer := (header[1] >> 1) & 0x03           // 00保留, 同时排除ADTS
+	bitrateIndex := header[2] >> 4             // 1111无效
+	sampleRateIndex := (header[2] >> 2) & 0x03 // 11保留
+	return version != 0x01 && layer != 0x00 && bitrateIndex != 0x0F && sampleRateIndex != 0x03
+}
+
+// wav: RIFF/RF64/BW64容器且格式为WAVE
+func isWavHeader(header []byte) bool {
+	if len(header) < 12 || !bytes.Equal(header[8:12], []byte("WAVE")) {
+		return false
+	}
+	return bytes.HasPrefix(header, []byte("RIFF")) || bytes.HasPrefix(header, []byte("RF64")) || bytes.HasPrefix(header, []byte("BW64"))
+}
+
+// 校验上传的源文件: 大小限制、魔数与扩展名一致、ffprobe存在可解码音频流
+// @param srcFile 本地源文件路径
+// @param fileType 扩展名, 如.mp3
+// @return reason 校验不通过原因, 通过时为空; err 为读取文件等系统错误
+func validateUploadedFile(srcFile string, fileType string) (reason string, err error) {
+	info, err := os.Stat(srcFile)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() == 0 {
+		return "空文件", nil
+	}
+	if maxSize := getUploadMaxSize(); info.Size() > maxSize {
+		return fmt.Sprintf("文件大小%d超出限制%d", info.Size(), maxSize), nil
+	}
+
+	// 魔数识别
+	f, err := os.Open(srcFile)
+	if err != nil {
+		return "", err
+	}
+	header := make([]byte, sniffHeaderSize)
+	n, err := io.ReadFull(f, header)
+	f.Close()
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	sniffed := SniffAudioFileType(header[:n])
+	if sniffed == "" {
+		return "无法识别的文件内容", nil
+	}
+	if sniffed != strings.ToLower(fileType) {
+		return fmt.Sprintf("文件内容(%s)与扩展名(%s)不符", sniffed, fileType), nil
+	}
+
+	// ffprobe检查可解码的音频流
+	probe, err := GetMediaProbe(srcFile)
+	if err != nil {
+		return fmt.Sprintf("ffprobe解析失败: %v", err), nil
+	}
+	hasAudio := false
+	for _, stream := range probe.Streams {
+		sampleRate, _ := strconv.ParseInt(stream.SampleRate, 10, 64)
+		if stream.CodecType == "audio" && stream.CodecName != "" && sampleRate > 0 && stream.Channels > 0 {
+			hasAudio = true
+			break
+		}
+	}
+	if !hasAudio {
+		return "未找到可解码的音频流", nil
+	}
+	if duration, _ := strconv.ParseFloat(probe.Format.Duration, 64); duration <= 0 {
+		return "音频时长无效", nil
+	}
+	return "", nil
+}
+
+// 将校验失败的源文件移动到隔离目录, 记录隔离原因并更新player状态
+// quarantine/{player_id}.mp3, quarantine/{player_id}.json
+func quarantineUploadedFile(player *router_model.ProductsPlayer, srcFile string, reason string) {
+	log.Errorf("quarantineUploadedFile 源文件校验失败, 移动到隔离目录, player_id: %s, srcFile: %s, reason: %s", player.Id, srcFile, reason)
+
+	fileKey := storage.Key(model.StreamFileQuarantinePath, filepath.Base(srcFile))
+	if err := storage.PutFile(storage.Default, fileKey, srcFile, ""); err != nil {
+		log.Errorf("quarantineUploadedFile 源文件写入隔离目录失败, srcFile: %s, key: %s, error: %v", srcFile, fileKey, err)
+	} else {
+		removeLocalFiles(srcFile)
+	}
+
+	record := &model.QuarantineRecord{
+		PlayerId:  player.Id,
+		ProductId: player.ProductId,
+		Filename:  player.Filename,
+		FileType:  player.FileType,
+		FileSize:  player.FileSize,
+		FileKey:   fileKey,
+		Reason:    reason,
+		CreatedAt: time.Now().Unix(),
+	}
+	b, _ := json.Marshal(record)
+	recordKey := storage.Key(model.StreamFileQuarantinePath, player.Id+".json")
+	if err := storage.Default.Put(recordKey, bytes.NewReader(b), int64(len(b)), "application/json"); err != nil {
+		log.Errorf("quarantineUploadedFile 写入隔离记录失败, record: %+v, error: %v", record, err)
+	}
+
+	player.Status = router_model.ProductsPlayerStatusQuarantine
+	if _, err := router_dao.UpdateProductsPlayerByField(player, []string{"status"}); err != nil {
+		log.Errorf("quarantineUploadedFile 更新ProductsPlayer记录失败, player: %+v, error: %v", player, err)
+	}
+}
+
+// 校验源文件, 不通过时隔离并返回QuarantineError
+func checkUploadedFile(player *router_model.ProductsPlayer, srcFile string) error {
+	reason, err := validateUploadedFile(srcFile, player.FileType)
+	if err != nil {
+		return fmt.Errorf("校验源文件失败: %w", err)
+	}
+	if reason != "" {
+		quarantineUploadedFile(player, srcFile, reason)
+		return &QuarantineError{Reason: reason}
+	}
+	return nil
+}
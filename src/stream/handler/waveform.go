@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"eshop_server/src/common/api"
+	"eshop_server/src/stream/model"
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWaveformFile 获取波形文件名
+// {player_id}_waveform.json
+func GetWaveformFile(playerId string) string {
+	return playerId + model.WaveformFileSuffix + ".json"
+}
+
+// GenerateWaveform 解码源文件计算降采样后的波形峰值, 写入波形目录
+// 源文件解码为单声道8kHz pcm, 按samples_per_pixel分段取最小/最大值
+// @param playerId 播放id
+// @param srcFile 源文件路径
+// @param duration 源文件时长（秒）, 用于计算分段大小
+// @param audioFilter 音频滤镜, 与hls保持一致, 为空时不处理
+func GenerateWaveform(playerId string, srcFile string, duration float64, audioFilter string) (err error) {
+	points := config.StreamConfig.WaveformPoints
+	if points <= 0 {
+		points = model.WaveformDefaultPoints
+	}
+	samplesPerPixel := int64(math.Ceil(duration * float64(model.WaveformSampleRate) / float64(points)))
+	if samplesPerPixel < 1 {
+		samplesPerPixel = 1
+	}
+	log.Infof("GenerateWaveform 计算波形峰值, srcFile: %s, duration: %v, samplesPerPixel: %d", srcFile, duration, samplesPerPixel)
+
+	// 示例命令：ffmpeg -v error -i src -vn -af anull -ac 1 -ar 8000 -f s16le -
+	cmd := exec.Command(
+		"ffmpeg",
+		"-v", "error",
+		"-i", srcFile, // 输入文件
+		"-vn",                               // 忽略封面等视频流
+		"-af", withAudioFilter(audioFilter), // 音频滤镜
+		"-ac", "1", // 单声道
+		"-ar", strconv.FormatInt(model.WaveformSampleRate, 10), // 降采样
+		"-f", "s16le", // 16bit小端pcm输出到stdout
+		"-",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	waveform := &model.Waveform{
+		Version:         model.WaveformVersion,
+		Channels:        1,
+		SampleRate:      model.WaveformSampleRate,
+		SamplesPerPixel: samplesPerPixel,
+		Bits:            model.WaveformBits,
+		Data:            make([]int8, 0, points*2),
+	}
+	var minSample, maxSample int16
+	var count int64
+	reader := bufio.NewReaderSize(stdout, 64*1024)
+	sample := make([]byte, 2)
+	for {
+		if _, err = io.ReadFull(reader, sample); err != nil {
+			break
+		}
+		v := int16(binary.LittleEndian.Uint16(sample))
+		if count == 0 || v < minSample {
+			minSample = v
+		}
+		if count == 0 || v > maxSample {
+			maxSample = v
+		}
+		count++
+		if count == samplesPerPixel {
+			waveform.Data = append(waveform.Data, int8(minSample>>8), int8(maxSample>>8))
+			count = 0
+		}
+	}
+	if count > 0 {
+		waveform.Data = append(waveform.Data, int8(minSample>>8), int8(maxSample>>8))
+	}
+	if err = cmd.Wait(); err != nil {
+		return fmt.Errorf("GenerateWaveform ffmpeg execution failed: %v", err)
+	}
+	waveform.Length = int64(len(waveform.Data) / 2)
+
+	b, err := json.Marshal(waveform)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(model.StreamFileWaveformPath, GetWaveformFile(playerId)), b, 0644)
+}
+
+// @Title		 获取波形峰值
+// @Description  获取player的波形峰值json, 供前端绘制可拖动的波形进度条, 公开访问
+// @Response     file
+// @Router       /v1/steaming/waveform/:player_id [get]
+func StreamingWaveform(c *gin.Context) {
+	// 请求参数校验
+	playerId := c.Param("player_id")
+	if playerId == "" {
+		log.Errorf("StreamingWaveform 请求参数错误, player_id为空")
+		api.FailWithFileNotFound(c)
+		return
+	}
+
+	// 判断文件是否存在
+	fileKey := storage.Key(model.StreamFileWaveformPath, GetWaveformFile(playerId))
+	if _, err := storage.Default.Stat(fileKey); err != nil {
+		api.FailWithFileNotFound(c)
+		return
+	}
+
+	serveStorageObject(c, fileKey)
+}
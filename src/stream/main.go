@@ -1,12 +1,15 @@
 package main
 
 import (
+	"eshop_server/src/router/middleware"
 	"eshop_server/src/stream/handler"
 	"eshop_server/src/stream/model"
 	"eshop_server/src/utils/config"
 
 	"eshop_server/src/utils/db"
 	"eshop_server/src/utils/log"
+	"eshop_server/src/utils/storage"
+	"eshop_server/src/utils/uredis"
 	"fmt"
 )
 
@@ -28,12 +31,37 @@ func main() {
 	db.InitMysqlAll(config.DbConfig.Mysql.Host, config.DbConfig.Mysql.Db, config.DbConfig.Mysql.MaxCon, db.Con_Main, config.CommonConfig.OpenDbLog)
 	log.Info("初始化Mysql数据库成功")
 
+	// 初始化redis
+	uredis.InitRedis(config.DbConfig.Redis.Host, config.DbConfig.Redis.Password, config.DbConfig.Redis.Db)
+	log.Info("初始化Redis缓存成功")
+
+	// 初始化jwt密钥
+	if err = middleware.InitJwtKeys(); err != nil {
+		log.Errorf("初始化jwt密钥失败: %v", err)
+		return
+	}
+
 	// 初始化音频路径
 	if err = model.InitStreamingPaths(); err != nil {
 		log.Error("初始化项目路径失败")
 		return
 	}
 
+	// 初始化对象存储
+	if err = storage.InitStorage(); err != nil {
+		log.Errorf("初始化对象存储失败: %v", err)
+		return
+	}
+
+	// 初始化分片内存缓存
+	handler.InitSegmentCache()
+
+	// 启动转码任务协程
+	handler.InitTranscodeWorkers()
+
+	// 启动播放统计落库协程
+	handler.InitPlaybackFlusher()
+
 	// 初始化路由
 	handler.InitRouter()
 }
@@ -0,0 +1,16 @@
+package model
+
+const (
+	DownloadWatermarkFrequency   = 18500  // 音频水印载波频率（Hz）, 接近人耳听觉上限
+	DownloadWatermarkAmplitude   = 0.0005 // 音频水印幅度, 约-66dBFS
+	DownloadWatermarkBitDuration = 0.5    // 音频水印每位时长（秒）
+	DownloadWatermarkBits        = 32     // 音频水印编码位数, 循环重复
+)
+
+var (
+	DownloadCodecArgsMap = map[string][]string{ // 下载格式对应的ffmpeg编码参数
+		"mp3":  {"-c:a", "libmp3lame", "-b:a", "320k", "-id3v2_version", "3"},
+		"wav":  {"-c:a", "pcm_s24le"},
+		"flac": {"-c:a", "flac"},
+	}
+)
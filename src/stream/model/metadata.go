@@ -0,0 +1,50 @@
+package model
+
+const (
+	CoverFileSuffix string = "_cover" // 封面文件名后缀
+)
+
+// ffprobe -show_format -show_streams 输出
+type MediaProbe struct {
+	Format  MediaProbeFormat   `json:"format"`
+	Streams []MediaProbeStream `json:"streams"`
+}
+
+// ffprobe 封装信息
+type MediaProbeFormat struct {
+	FormatName string            `json:"format_name"` // 封装格式, 如mp3/wav
+	Duration   string            `json:"duration"`    // 时长（秒）
+	BitRate    string            `json:"bit_rate"`    // 总码率（bps）
+	Tags       map[string]string `json:"tags"`        // ID3/RIFF INFO等标签
+}
+
+// ffprobe 流信息
+type MediaProbeStream struct {
+	Index            int64             `json:"index"`
+	CodecType        string            `json:"codec_type"` // audio/video, 封面为video
+	CodecName        string            `json:"codec_name"`
+	SampleRate       string            `json:"sample_rate"`
+	Channels         int64             `json:"channels"`
+	ChannelLayout    string            `json:"channel_layout"`
+	BitRate          string            `json:"bit_rate"`
+	BitsPerSample    int64             `json:"bits_per_sample"`
+	BitsPerRawSample string            `json:"bits_per_raw_sample"`
+	Disposition      map[string]int64  `json:"disposition"` // attached_pic=1为内嵌封面
+	Tags             map[string]string `json:"tags"`
+}
+
+// EBU R128 响度测量结果
+type MediaLoudness struct {
+	IntegratedLoudness float64 // 综合响度（LUFS）
+	LoudnessRange      float64 // 响度范围（LU）
+	TruePeak           float64 // 真峰值（dBTP）
+}
+
+// loudnorm 第一遍测量结果, 数值为字符串, 静音文件为-inf
+type LoudnormMeasurement struct {
+	InputI       string `json:"input_i"`       // 输入综合响度（LUFS）
+	InputTp      string `json:"input_tp"`      // 输入真峰值（dBTP）
+	InputLra     string `json:"input_lra"`     // 输入响度范围（LU）
+	InputThresh  string `json:"input_thresh"`  // 输入门限（LUFS）
+	TargetOffset string `json:"target_offset"` // 目标增益偏移（LU）
+}
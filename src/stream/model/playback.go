@@ -0,0 +1,11 @@
+package model
+
+const (
+	PlaybackDefaultFlushInterval = 60  // 默认播放会话落库间隔（秒）
+	PlaybackFlushBatchSize       = 500 // 每批落库的播放会话数
+
+	ConcurrencyDefaultMaxStreams   = 2    // 默认每个用户同时播放的最大会话数
+	ConcurrencyDefaultActiveWindow = 60   // 默认会话活跃时长（秒）, 需大于分片时长
+	ConcurrencyDefaultIpWindow     = 3600 // 默认共享检测统计窗口（秒）
+	ConcurrencyDefaultMaxIps       = 5    // 默认统计窗口内允许的最大不同ip数
+)
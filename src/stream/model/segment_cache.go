@@ -0,0 +1,16 @@
+package model
+
+const (
+	SegmentCacheDefaultSize    int64 = 64 << 20 // 默认分片内存缓存大小 64MiB
+	SegmentCacheItemSizeFactor int64 = 16       // 单个分片不超过缓存大小的1/16时才缓存
+)
+
+// 分片内存缓存统计
+type SegmentCacheStats struct {
+	Hits     int64   `json:"hits"`     // 命中次数
+	Misses   int64   `json:"misses"`   // 未命中次数
+	HitRate  float64 `json:"hit_rate"` // 命中率
+	Items    int64   `json:"items"`    // 缓存分片数
+	Size     int64   `json:"size"`     // 已用大小（Byte字节）
+	Capacity int64   `json:"capacity"` // 缓存大小（Byte字节）
+}
@@ -9,6 +9,15 @@ const (
 	StreamFileUploadPath string = "uploads" // 音频上传路径
 	StreamFileSegmentPath string = "segments" // 音频切片路径
 	StreamFileRecyclePath string = "recycle" // 音频回收站路径
+	StreamFileKeyPath string = "keys" // 音频加密密钥路径（不对外直接暴露）
+	StreamFilePreviewPath string = "previews" // 音频试听切片路径（公开访问）
+	StreamFileCoverPath string = "covers" // 音频内嵌封面路径（公开访问）
+	StreamFileWaveformPath string = "waveforms" // 音频波形峰值路径（公开访问）
+	StreamFileQuarantinePath string = "quarantine" // 校验失败的上传文件隔离路径（不对外暴露）
+	StreamFileDownloadPath string = "downloads" // 购买下载文件临时生成路径（不对外直接暴露）
+
+	StreamFileRecycleDefaultRetention int64 = 30 // 回收站文件默认保留天数
+	StreamAuditReportMaxItems int = 20 // HLS完整性巡检报告最多列出的异常player数
 )
 
 // 初始化流媒体服务路径
@@ -22,5 +31,23 @@ func InitStreamingPaths() (err error) {
 	if err = os.MkdirAll(StreamFileRecyclePath, os.ModePerm); err != nil {
 		return err
 	}
+	if err = os.MkdirAll(StreamFileKeyPath, 0700); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(StreamFilePreviewPath, os.ModePerm); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(StreamFileCoverPath, os.ModePerm); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(StreamFileWaveformPath, os.ModePerm); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(StreamFileQuarantinePath, 0700); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(StreamFileDownloadPath, 0700); err != nil {
+		return err
+	}
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,26 @@
+package model
+
+const (
+	TranscodeJobStatusPending = "pending" // 转码任务状态 等待处理
+	TranscodeJobStatusRunning = "running" // 转码任务状态 处理中
+	TranscodeJobStatusSuccess = "success" // 转码任务状态 处理成功
+	TranscodeJobStatusFailed  = "failed"  // 转码任务状态 处理失败
+
+	TranscodeDefaultWorkers  = 2 // 默认转码并发数
+	TranscodeDefaultMaxRetry = 3 // 默认最大自动重试次数
+)
+
+// 流媒体转码任务
+type TranscodeJob struct {
+	PlayerId  string `json:"player_id"`  // 播放id
+	SrcFile   string `json:"src_file"`   // 源文件存储key
+	AutoFill  bool   `json:"auto_fill"`  // 是否根据元数据自动填充商品标题及图片
+	Status    string `json:"status"`     // 任务状态
+	Attempts  int64  `json:"attempts"`   // 已尝试次数
+	MaxRetry  int64  `json:"max_retry"`  // 最大自动重试次数
+	Error     string `json:"error"`      // 最近一次失败原因
+	CreatedAt int64  `json:"created_at"` // 创建时间戳
+	UpdatedAt int64  `json:"updated_at"` // 更新时间戳
+
+	ReplacePlayerId string `json:"replace_player_id"` // 被替换的播放id, 转码成功后下架并回收其文件
+}
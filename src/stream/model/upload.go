@@ -0,0 +1,50 @@
+package model
+
+const (
+	UploadDefaultChunkSize    int64  = 8 << 20          // 默认分片大小 8MiB
+	UploadMaxChunkSize        int64  = 64 << 20         // 最大分片大小 64MiB
+	UploadDefaultMaxSize      int64  = 1 << 30          // 默认上传文件大小上限 1GiB
+	UploadChunkPath           string = "chunks"         // 分片存储目录, 位于上传目录下
+	UploadChunkChecksumHeader        = "X-Chunk-Sha256" // 分片校验值请求头
+)
+
+// 分片上传任务
+type UploadSession struct {
+	UploadId    string `json:"upload_id"`    // 上传id
+	PlayerId    string `json:"player_id"`    // 播放id
+	ProductId   string `json:"product_id"`   // 商品id
+	Filename    string `json:"filename"`     // 原始文件名
+	FileType    string `json:"file_type"`    // 文件类型
+	FileSize    int64  `json:"file_size"`    // 文件大小（Byte字节）
+	ChunkSize   int64  `json:"chunk_size"`   // 分片大小（Byte字节）
+	TotalChunks int64  `json:"total_chunks"` // 分片数量
+	Checksum    string `json:"checksum"`     // 整个文件sha256, 为空时不校验
+	AutoFill    bool   `json:"auto_fill"`    // 是否根据元数据自动填充商品标题及图片
+	CreatedAt   int64  `json:"created_at"`   // 创建时间戳
+
+	ReplacePlayerId string `json:"replace_player_id"` // 被替换的播放id
+}
+
+// 初始化分片上传请求
+type UploadInitReq struct {
+	ProductId string `json:"product_id"` // 商品id
+	Filename  string `json:"filename"`   // 原始文件名
+	FileSize  int64  `json:"file_size"`  // 文件大小（Byte字节）
+	ChunkSize int64  `json:"chunk_size"` // 分片大小（Byte字节）, 为0时使用服务端配置
+	Checksum  string `json:"checksum"`   // 整个文件sha256, 可选
+	AutoFill  bool   `json:"auto_fill"`  // 是否根据元数据自动填充商品标题及图片, 可选
+
+	ReplacePlayerId string `json:"replace_player_id"` // 被替换的播放id, 可选, 转码成功后替换该播放记录
+}
+
+// 隔离记录, 与隔离文件一同写入隔离目录
+type QuarantineRecord struct {
+	PlayerId  string `json:"player_id"`  // 播放id
+	ProductId string `json:"product_id"` // 商品id
+	Filename  string `json:"filename"`   // 原始文件名
+	FileType  string `json:"file_type"`  // 文件类型
+	FileSize  int64  `json:"file_size"`  // 文件大小（Byte字节）
+	FileKey   string `json:"file_key"`   // 隔离文件存储key
+	Reason    string `json:"reason"`     // 隔离原因
+	CreatedAt int64  `json:"created_at"` // 隔离时间戳
+}
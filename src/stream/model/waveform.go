@@ -0,0 +1,21 @@
+package model
+
+const (
+	WaveformFileSuffix    string = "_waveform" // 波形文件名后缀
+	WaveformVersion       int64  = 2           // 波形数据格式版本, 兼容audiowaveform json
+	WaveformSampleRate    int64  = 8000        // 波形计算采样率（Hz）
+	WaveformBits          int64  = 8           // 波形峰值位深
+	WaveformDefaultPoints int64  = 2000        // 默认波形峰值点数
+)
+
+// 波形峰值数据, 格式兼容audiowaveform json, 前端可直接用于peaks.js等组件
+// data按[min0, max0, min1, max1, ...]排列, 取值范围[-128, 127]
+type Waveform struct {
+	Version         int64  `json:"version"`
+	Channels        int64  `json:"channels"`
+	SampleRate      int64  `json:"sample_rate"`
+	SamplesPerPixel int64  `json:"samples_per_pixel"`
+	Bits            int64  `json:"bits"`
+	Length          int64  `json:"length"`
+	Data            []int8 `json:"data"`
+}
@@ -70,6 +70,47 @@ type LarkAlarm struct {
 	ErrorBotWebhook string `mapstructure:"error_bot_webhook"`
 }
 
+// 密码哈希配置
+type PasswordConf struct {
+	Algorithm     string `mapstructure:"algorithm"`      // 哈希算法 bcrypt/argon2id，为空时使用bcrypt
+	BcryptCost    int    `mapstructure:"bcrypt_cost"`    // bcrypt计算成本，为0时使用默认值
+	Argon2Time    uint32 `mapstructure:"argon2_time"`    // argon2id迭代次数，为0时使用默认值
+	Argon2Memory  uint32 `mapstructure:"argon2_memory"`  // argon2id内存（KiB），为0时使用默认值
+	Argon2Threads uint8  `mapstructure:"argon2_threads"` // argon2id并行度，为0时使用默认值
+}
+
+// 登录风控配置
+type LoginGuardConf struct {
+	FailWindow         int64 `mapstructure:"fail_window"`          // 登录失败计数窗口（秒），为0时使用默认值
+	AccountMaxAttempts int64 `mapstructure:"account_max_attempts"` // 账号在计数窗口内的最大失败次数，达到时临时锁定账号，为0时使用默认值，小于0时不锁定
+	IpMaxAttempts      int64 `mapstructure:"ip_max_attempts"`      // ip在计数窗口内的最大失败次数，达到时临时限制该ip登录，为0时使用默认值，小于0时不限制
+	LockDuration       int64 `mapstructure:"lock_duration"`        // 临时锁定时长（秒），到期自动解锁，为0时使用默认值
+	DelayAfter         int64 `mapstructure:"delay_after"`          // 失败次数超过该值后逐次倍增延迟响应，为0时使用默认值，小于0时不延迟
+	MaxDelay           int64 `mapstructure:"max_delay"`            // 最大延迟响应时长（秒），为0时使用默认值
+}
+
+// 登录会话配置
+type SessionConf struct {
+	RefreshTokenTimeout int64 `mapstructure:"refresh_token_timeout"` // 刷新凭证有效时长（秒），每次刷新后重新计算，为0时使用默认值
+	MaxSessions         int64 `mapstructure:"max_sessions"`          // 每个用户最多保留的登录会话数，超出时注销最早的会话，为0时使用默认值，小于0时不限制
+}
+
+// jwt签名密钥配置
+type JwtKeyConf struct {
+	Kid            string `mapstructure:"kid"`             // 密钥id，写入token header的kid
+	PrivateKeyFile string `mapstructure:"private_key_file"` // PEM私钥文件路径（RSA/Ed25519），仅签发token的服务需要配置
+	PublicKeyFile  string `mapstructure:"public_key_file"`  // PEM公钥文件路径，为空时由私钥导出
+	NotAfter       int64  `mapstructure:"not_after"`        // 停止校验的时间戳（秒），轮换后保留至旧token全部过期，为0时不限制
+}
+
+// jwt配置
+type JwtConf struct {
+	SigningKid          string       `mapstructure:"signing_kid"`           // 当前签发token使用的密钥id，为空时使用jwt_secret按HS256签发
+	Keys                []JwtKeyConf `mapstructure:"keys"`                  // 签名及校验密钥列表，轮换时先加入新密钥并发布，再切换signing_kid
+	JwksUrl             string       `mapstructure:"jwks_url"`              // 远程jwks地址，配置后从该地址获取校验公钥（stream、cronjob等仅校验token的服务）
+	JwksRefreshInterval int64        `mapstructure:"jwks_refresh_interval"` // 远程jwks刷新间隔（秒），为0时使用默认值
+}
+
 // 通用配置
 type CommonConf struct {
 	AppName      string            `mapstructure:"app_name"`      // 应用名称
@@ -80,10 +121,14 @@ type CommonConf struct {
 	ApiHost      string            `mapstructure:"api_host"`      // api域名
 	HttpServer   HttpServerConf    `mapstructure:"http_server"`   // http服务
 	StreamServer StreamServerConf  `mapstructure:"stream_server"` // 流媒体服务
-	JwtSecret    string            `mapstructure:"jwt_secret"`    // jwt密钥
+	JwtSecret    string            `mapstructure:"jwt_secret"`    // jwt HS256密钥，已弃用，仅用于校验迁移前签发的token
+	Jwt          JwtConf           `mapstructure:"jwt"`           // jwt签名配置
 	YltAccount   map[string]string `mapstructure:"ylt_account"`   // ylt账号
 	Smtp         SmtpConfig        `mapstructure:"smtp"`          // smtp配置
 	LarkAlarm    LarkAlarm         `mapstructure:"lark_alarm"`    // 飞书告警配置
+	Password     PasswordConf      `mapstructure:"password"`      // 密码哈希配置
+	LoginGuard   LoginGuardConf    `mapstructure:"login_guard"`   // 登录风控配置
+	Session      SessionConf       `mapstructure:"session"`       // 登录会话配置
 
 }
 
@@ -95,5 +140,80 @@ type DbConf struct {
 
 // 流媒体配置
 type StreamConf struct {
-	Host string `mapstructure:"host"` // 流媒体服务地址
+	Host             string          `mapstructure:"host"`               // 流媒体服务地址
+	ApiHost          string          `mapstructure:"api_host"`           // 流媒体服务接口地址, 如http://127.0.0.1:8081
+	InternalSecret   string          `mapstructure:"internal_secret"`    // 服务间内部接口共享密钥，用于上传、转码、下架等管理接口，为空时拒绝全部内部接口请求
+	ApiTimeout       int64           `mapstructure:"api_timeout"`        // 调用流媒体服务接口超时时长（秒），修改后需重启生效，为0时使用默认值
+	UploadChunkSize  int64           `mapstructure:"upload_chunk_size"`  // 分片上传大小（Byte字节），为0时使用默认值
+	UploadMaxSize    int64           `mapstructure:"upload_max_size"`    // 上传文件大小上限（Byte字节），为0时使用默认值
+	PlayTokenTimeout int64           `mapstructure:"play_token_timeout"` // 播放凭证有效时长（秒），为0时使用默认值
+	SegmentCacheSize int64           `mapstructure:"segment_cache_size"` // 分片内存缓存大小（Byte字节），为0时使用默认值，小于0时不缓存
+	RecycleRetention int64           `mapstructure:"recycle_retention"`  // 回收站文件保留天数，为0时使用默认值
+	PreviewHost      string          `mapstructure:"preview_host"`       // 试听服务地址
+	CoverHost        string          `mapstructure:"cover_host"`         // 封面服务地址
+	DownloadHost     string          `mapstructure:"download_host"`      // 下载服务地址
+	Download         DownloadConf    `mapstructure:"download"`           // 购买下载配置
+	Preview          PreviewConf     `mapstructure:"preview"`            // 试听片段配置
+	Transcode        TranscodeConf   `mapstructure:"transcode"`          // 转码任务配置
+	BitrateLadder    []int64         `mapstructure:"bitrate_ladder"`     // AAC码率阶梯（kbps），为空时使用默认值
+	LosslessCodec    string          `mapstructure:"lossless_codec"`     // wav源文件的无损音轨编码 flac/alac，为空时不生成
+	Loudnorm         LoudnormConf    `mapstructure:"loudnorm"`           // 响度标准化配置
+	WaveformPoints   int64           `mapstructure:"waveform_points"`    // 波形峰值点数，为0时使用默认值
+	Analytics        AnalyticsConf   `mapstructure:"analytics"`          // 播放统计配置
+	Concurrency      ConcurrencyConf `mapstructure:"concurrency"`        // 并发播放限制配置
+	Storage          StorageConf     `mapstructure:"storage"`            // 对象存储配置
+}
+
+// 对象存储配置
+type StorageConf struct {
+	Type          string `mapstructure:"type"`           // 存储类型 local/s3/obs，为空时使用local
+	Root          string `mapstructure:"root"`           // local存储根目录，为空时使用当前工作目录
+	Endpoint      string `mapstructure:"endpoint"`       // s3服务地址
+	Region        string `mapstructure:"region"`         // s3区域
+	Bucket        string `mapstructure:"bucket"`         // s3桶名称
+	AccessKey     string `mapstructure:"access_key"`     // s3访问密钥id
+	SecretKey     string `mapstructure:"secret_key"`     // s3访问密钥
+	PathStyle     bool   `mapstructure:"path_style"`     // 是否使用路径风格地址，MinIO需开启
+	PresignExpire int64  `mapstructure:"presign_expire"` // 预签名地址有效时长（秒），为0时使用默认值
+}
+
+// 响度标准化配置
+type LoudnormConf struct {
+	Enable   bool    `mapstructure:"enable"`    // 是否在生成hls时进行两遍loudnorm响度标准化
+	Target   float64 `mapstructure:"target"`    // 目标综合响度（LUFS），为0时使用默认值
+	TruePeak float64 `mapstructure:"true_peak"` // 真峰值上限（dBTP），为0时使用默认值
+	Lra      float64 `mapstructure:"lra"`       // 目标响度范围（LU），为0时使用默认值
+}
+
+// 播放统计配置
+type AnalyticsConf struct {
+	FlushInterval int64 `mapstructure:"flush_interval"` // 播放会话落库间隔（秒），为0时使用默认值
+}
+
+// 并发播放限制及共享检测配置
+type ConcurrencyConf struct {
+	MaxStreams   int64 `mapstructure:"max_streams"`   // 每个用户同时播放的最大会话数，为0时使用默认值，小于0时不限制
+	ActiveWindow int64 `mapstructure:"active_window"` // 会话活跃时长（秒），超过该时长无请求视为停止播放，为0时使用默认值
+	IpWindow     int64 `mapstructure:"ip_window"`     // 共享检测统计窗口（秒），为0时使用默认值
+	MaxIps       int64 `mapstructure:"max_ips"`       // 统计窗口内允许的最大不同ip数，超出时飞书告警，为0时使用默认值，小于0时不检测
+}
+
+// 购买下载配置
+type DownloadConf struct {
+	LinkTimeout  int64 `mapstructure:"link_timeout"`  // 下载链接有效时长（秒），为0时使用默认值
+	MaxDownloads int64 `mapstructure:"max_downloads"` // 每笔购买的最大下载次数，为0时使用默认值，小于0时不限制
+	Watermark    bool  `mapstructure:"watermark"`     // 是否在下载文件中嵌入低电平音频水印
+}
+
+// 转码任务配置
+type TranscodeConf struct {
+	Workers  int   `mapstructure:"workers"`   // 转码并发数，为0时使用默认值
+	MaxRetry int64 `mapstructure:"max_retry"` // 最大自动重试次数，为0时使用默认值
+}
+
+// 试听片段配置
+type PreviewConf struct {
+	Offset   int64 `mapstructure:"offset"`   // 试听起始位置（秒）
+	Duration int64 `mapstructure:"duration"` // 试听时长（秒），为0时使用默认值
+	FadeOut  int64 `mapstructure:"fade_out"` // 淡出时长（秒），为0时使用默认值
 }
@@ -13,13 +13,35 @@ package errors
 //
 // user错误码 [33000,34000)
 const (
-	ErrorCodeStreamServiceUnknownError int32 = 33001
-	ErrorCodeStreamFileUploadFailed    int32 = 33002
-	ErrorCodeStreamFileStreamingFailed int32 = 33003
+	ErrorCodeStreamServiceUnknownError  int32 = 33001
+	ErrorCodeStreamFileUploadFailed     int32 = 33002
+	ErrorCodeStreamFileStreamingFailed  int32 = 33003
+	ErrorCodeStreamNotPurchased         int32 = 33004
+	ErrorCodeStreamTranscodeJobNotFound int32 = 33005
+	ErrorCodeStreamTranscodeJobNotRetry int32 = 33006
+	ErrorCodeStreamUploadNotFound       int32 = 33007
+	ErrorCodeStreamUploadChunkInvalid   int32 = 33008
+	ErrorCodeStreamUploadIncomplete     int32 = 33009
+	ErrorCodeStreamFileQuarantined      int32 = 33010
+	ErrorCodeStreamFileTooLarge         int32 = 33011
+	ErrorCodeStreamConcurrentLimit      int32 = 33012
+	ErrorCodeStreamDownloadLimit        int32 = 33013
+	ErrorCodeStreamDownloadNotFound     int32 = 33014
 )
 
 var (
-	ErrorStreamServiceUnknownError = New("", "流媒体服务发生未知错误，请联系管理员", ErrorCodeStreamServiceUnknownError)
-	ErrorStreamFileUploadFailed    = New("", "上传流媒体文件失败", ErrorCodeStreamFileUploadFailed)
-	ErrorStreamFileStreamingFailed = New("", "文件流媒体处理失败", ErrorCodeStreamFileStreamingFailed)
+	ErrorStreamServiceUnknownError  = New("", "流媒体服务发生未知错误，请联系管理员", ErrorCodeStreamServiceUnknownError)
+	ErrorStreamFileUploadFailed     = New("", "上传流媒体文件失败", ErrorCodeStreamFileUploadFailed)
+	ErrorStreamFileStreamingFailed  = New("", "文件流媒体处理失败", ErrorCodeStreamFileStreamingFailed)
+	ErrorStreamNotPurchased         = New("", "未购买该商品，暂无播放权限", ErrorCodeStreamNotPurchased)
+	ErrorStreamTranscodeJobNotFound = New("", "转码任务不存在", ErrorCodeStreamTranscodeJobNotFound)
+	ErrorStreamTranscodeJobNotRetry = New("", "转码任务当前状态不可重试", ErrorCodeStreamTranscodeJobNotRetry)
+	ErrorStreamUploadNotFound       = New("", "上传任务不存在或已过期", ErrorCodeStreamUploadNotFound)
+	ErrorStreamUploadChunkInvalid   = New("", "分片校验失败", ErrorCodeStreamUploadChunkInvalid)
+	ErrorStreamUploadIncomplete     = New("", "分片未全部上传或文件校验失败", ErrorCodeStreamUploadIncomplete)
+	ErrorStreamFileQuarantined      = New("", "文件内容校验失败，已隔离", ErrorCodeStreamFileQuarantined)
+	ErrorStreamFileTooLarge         = New("", "文件大小超出限制", ErrorCodeStreamFileTooLarge)
+	ErrorStreamConcurrentLimit      = New("", "同时播放的设备数超出限制，请停止其他设备播放后重试", ErrorCodeStreamConcurrentLimit)
+	ErrorStreamDownloadLimit        = New("", "下载次数已达上限", ErrorCodeStreamDownloadLimit)
+	ErrorStreamDownloadNotFound     = New("", "下载链接不存在或已失效", ErrorCodeStreamDownloadNotFound)
 )
@@ -56,6 +56,16 @@ const (
 	ErrCodeRegisterMailExisted        int32 = 31047
 	ErrCodePasswordNotSame            int32 = 31048
 	ErrorCodeUserBanned               int32 = 31049
+	ErrorCodeResetCodeInvalid         int32 = 31050
+	ErrorCodeSendMailFastFail         int32 = 31051
+	ErrorCodeUserLocked               int32 = 31052
+	ErrorCodeLoginIpLimited           int32 = 31053
+	ErrorCodePermissionDenied         int32 = 31054
+	ErrorCodeMfaTokenInvalid          int32 = 31055
+	ErrorCodeTotpCodeInvalid          int32 = 31056
+	ErrorCodeTotpAlreadyEnabled       int32 = 31057
+	ErrorCodeTotpNotEnabled           int32 = 31058
+	ErrorCodeAdminLoginRequired       int32 = 31059
 )
 
 var (
@@ -96,4 +106,14 @@ var (
 	ErrorRegisterMailExisted      = New("user", "该邮箱已被注册，换一个试试吧", ErrCodeRegisterMailExisted)
 	ErrorPasswordNotSame          = New("user", "密码有误", ErrCodePasswordNotSame)
 	ErrorUserBanned               = New("user", "该用户已被禁用，请联系管理员", ErrorCodeUserBanned)
+	ErrorResetCodeInvalid         = New("user", "验证码错误或已失效", ErrorCodeResetCodeInvalid)
+	ErrorSendMailFastFail         = New("user", "邮件发送太频繁，请稍后再试", ErrorCodeSendMailFastFail)
+	ErrorUserLocked               = New("user", "登录失败次数过多，账户已临时锁定，请稍后再试", ErrorCodeUserLocked)
+	ErrorLoginIpLimited           = New("user", "登录尝试过于频繁，请稍后再试", ErrorCodeLoginIpLimited)
+	ErrorPermissionDenied         = New("user", "权限不足", ErrorCodePermissionDenied)
+	ErrorMfaTokenInvalid          = New("user", "两步验证已过期，请重新登录", ErrorCodeMfaTokenInvalid)
+	ErrorTotpCodeInvalid          = New("user", "验证码或恢复码不正确", ErrorCodeTotpCodeInvalid)
+	ErrorTotpAlreadyEnabled       = New("user", "已绑定两步验证", ErrorCodeTotpAlreadyEnabled)
+	ErrorTotpNotEnabled           = New("user", "未绑定两步验证", ErrorCodeTotpNotEnabled)
+	ErrorAdminLoginRequired       = New("user", "后台账号请使用后台登录", ErrorCodeAdminLoginRequired)
 )
@@ -0,0 +1,161 @@
+//@Author	AInoriex
+//@Desc		用户密码哈希, 支持bcrypt及argon2id, 兼容迁移前直接存储客户端密码的旧数据
+
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"eshop_server/src/utils/config"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"   // bcrypt哈希
+	AlgorithmArgon2id = "argon2id" // argon2id哈希
+
+	DefaultBcryptCost    = 12        // bcrypt默认计算成本
+	DefaultArgon2Time    = 3         // argon2id默认迭代次数
+	DefaultArgon2Memory  = 64 * 1024 // argon2id默认内存（KiB）
+	DefaultArgon2Threads = 2         // argon2id默认并行度
+
+	MaxLength = 72 // 密码最大长度, bcrypt仅支持72字节
+
+	argon2SaltLength = 16 // argon2id盐长度（Byte字节）
+	argon2KeyLength  = 32 // argon2id哈希长度（Byte字节）
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("password: unsupported algorithm")
+	ErrInvalidHash          = errors.New("password: invalid hash format")
+)
+
+// argon2id哈希参数
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// 获取配置的哈希算法
+func getAlgorithm() string {
+	algorithm := strings.ToLower(config.CommonConfig.Password.Algorithm)
+	if algorithm == "" {
+		return AlgorithmBcrypt
+	}
+	return algorithm
+}
+
+// 获取配置的bcrypt计算成本
+func getBcryptCost() int {
+	cost := config.CommonConfig.Password.BcryptCost
+	if cost == 0 {
+		return DefaultBcryptCost
+	}
+	return cost
+}
+
+// 获取配置的argon2id参数
+func getArgon2Params() argon2Params {
+	conf := config.CommonConfig.Password
+	params := argon2Params{time: conf.Argon2Time, memory: conf.Argon2Memory, threads: conf.Argon2Threads}
+	if params.time == 0 {
+		params.time = DefaultArgon2Time
+	}
+	if params.memory == 0 {
+		params.memory = DefaultArgon2Memory
+	}
+	if params.threads == 0 {
+		params.threads = DefaultArgon2Threads
+	}
+	return params
+}
+
+// Hash 按配置的算法生成密码哈希, 每次生成随机盐
+// bcrypt格式: $2a${cost}$...
+// argon2id格式: $argon2id$v=19$m={memory},t={time},p={threads}${salt}${hash}
+func Hash(plain string) (string, error) {
+	switch getAlgorithm() {
+	case AlgorithmBcrypt:
+		b, err := bcrypt.GenerateFromPassword([]byte(plain), getBcryptCost())
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case AlgorithmArgon2id:
+		params := getArgon2Params()
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(plain), salt, params.time, params.memory, params.threads, argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.memory, params.time, params.threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		return "", ErrUnsupportedAlgorithm
+	}
+}
+
+// 判断是否为bcrypt哈希
+func isBcryptHash(hashed string) bool {
+	return strings.HasPrefix(hashed, "$2a$") || strings.HasPrefix(hashed, "$2b$") || strings.HasPrefix(hashed, "$2y$")
+}
+
+// Verify 校验密码
+// @return ok 密码是否一致
+// @return needRehash 密码一致且为旧数据或哈希参数与当前配置不一致, 需调用Hash重新生成并保存
+func Verify(plain string, hashed string) (ok bool, needRehash bool) {
+	switch {
+	case isBcryptHash(hashed):
+		if bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(hashed))
+		return true, getAlgorithm() != AlgorithmBcrypt || err != nil || cost != getBcryptCost()
+	case strings.HasPrefix(hashed, "$argon2id$"):
+		params, salt, key, err := decodeArgon2Hash(hashed)
+		if err != nil {
+			return false, false
+		}
+		other := argon2.IDKey([]byte(plain), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false
+		}
+		return true, getAlgorithm() != AlgorithmArgon2id || params != getArgon2Params()
+	default:
+		// 旧数据直接保存客户端提交的密码
+		if hashed == "" || subtle.ConstantTimeCompare([]byte(hashed), []byte(plain)) != 1 {
+			return false, false
+		}
+		return true, true
+	}
+}
+
+// 解析argon2id哈希
+func decodeArgon2Hash(hashed string) (params argon2Params, salt []byte, key []byte, err error) {
+	// ["", "argon2id", "v=19", "m=65536,t=3,p=2", salt, hash]
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,57 @@
+package password
+
+import (
+	"eshop_server/src/utils/config"
+	"strings"
+	"testing"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	defer func() { config.CommonConfig.Password = config.PasswordConf{} }()
+
+	for _, conf := range []config.PasswordConf{
+		{Algorithm: AlgorithmBcrypt, BcryptCost: 4},
+		{Algorithm: AlgorithmArgon2id, Argon2Time: 1, Argon2Memory: 1024, Argon2Threads: 1},
+	} {
+		config.CommonConfig.Password = conf
+		hashed, err := Hash("secret123")
+		if err != nil {
+			t.Fatalf("Hash(%s) error: %v", conf.Algorithm, err)
+		}
+		if other, _ := Hash("secret123"); other == hashed {
+			t.Fatalf("Hash(%s) should use random salt", conf.Algorithm)
+		}
+		if ok, needRehash := Verify("secret123", hashed); !ok || needRehash {
+			t.Fatalf("Verify(%s) = %v, %v, want true, false", conf.Algorithm, ok, needRehash)
+		}
+		if ok, _ := Verify("secret124", hashed); ok {
+			t.Fatalf("Verify(%s) should reject wrong password", conf.Algorithm)
+		}
+	}
+}
+
+func TestVerifyNeedRehash(t *testing.T) {
+	defer func() { config.CommonConfig.Password = config.PasswordConf{} }()
+
+	// 旧数据直接保存客户端密码
+	if ok, needRehash := Verify("legacy-hash", "legacy-hash"); !ok || !needRehash {
+		t.Fatalf("Verify(legacy) = %v, %v, want true, true", ok, needRehash)
+	}
+	if ok, _ := Verify("other", "legacy-hash"); ok {
+		t.Fatalf("Verify(legacy) should reject wrong password")
+	}
+	if ok, _ := Verify("", ""); ok {
+		t.Fatalf("Verify should reject empty stored password")
+	}
+
+	// 哈希参数变更
+	config.CommonConfig.Password = config.PasswordConf{Algorithm: AlgorithmBcrypt, BcryptCost: 4}
+	hashed, err := Hash("secret123")
+	if err != nil || !strings.HasPrefix(hashed, "$2a$04$") {
+		t.Fatalf("Hash(bcrypt) = %s, %v", hashed, err)
+	}
+	config.CommonConfig.Password = config.PasswordConf{Algorithm: AlgorithmArgon2id, Argon2Time: 1, Argon2Memory: 1024, Argon2Threads: 1}
+	if ok, needRehash := Verify("secret123", hashed); !ok || !needRehash {
+		t.Fatalf("Verify after algorithm change = %v, %v, want true, true", ok, needRehash)
+	}
+}
@@ -66,3 +66,14 @@ func PrintQrCodeImage(data string) error {
 	fmt.Println(qr.ToSmallString(false))
 	return nil
 }
+
+// EncodeToBase64Png 生成二维码PNG图片并编码为base64字符串
+// data: 二维码内容
+// size: 图片边长（像素）
+func EncodeToBase64Png(data string, size int) (string, error) {
+	png, err := qrcode.Encode(data, qrcode.Medium, size)
+	if err != nil {
+		return "", fmt.Errorf("EncodeToBase64Png 生成二维码失败, %s", err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(png), nil
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"eshop_server/src/utils/config"
+	"eshop_server/src/utils/log"
+	"fmt"
+	"strings"
+)
+
+var (
+	Default Storage = NewLocalStorage("") // 默认存储
+)
+
+// InitStorage 根据配置初始化默认存储
+func InitStorage() error {
+	conf := config.StreamConfig.Storage
+	switch strings.ToLower(conf.Type) {
+	case "", TypeLocal:
+		Default = NewLocalStorage(conf.Root)
+	case TypeS3:
+		Default = NewS3Storage(conf.Endpoint, conf.Region, conf.Bucket, conf.AccessKey, conf.SecretKey, conf.PathStyle)
+	case TypeObs:
+		// 复用华为OBS配置, OBS兼容S3协议
+		obs := config.CommonConfig.HuaweiOBS
+		Default = NewS3Storage(obs.Url, conf.Region, obs.Bucket, obs.SecretId, obs.SecretKey, conf.PathStyle)
+	default:
+		return fmt.Errorf("storage: 不支持的存储类型: %s", conf.Type)
+	}
+	log.Infof("InitStorage 初始化存储成功, type: %s", conf.Type)
+	return nil
+}
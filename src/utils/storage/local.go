@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 本地文件系统存储
+type LocalStorage struct {
+	Root string // 存储根目录
+}
+
+// NewLocalStorage 创建本地文件系统存储
+// @param root 存储根目录, 为空时使用当前工作目录
+func NewLocalStorage(root string) *LocalStorage {
+	if root == "" {
+		root = "."
+	}
+	return &LocalStorage{Root: root}
+}
+
+// LocalPath 获取对象对应的本地路径
+func (s *LocalStorage) LocalPath(key string) string {
+	// 清理key防止越出根目录
+	cleanKey := filepath.FromSlash(filepath.Clean("/" + key))
+	return filepath.Join(s.Root, cleanKey)
+}
+
+func (s *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	dst := s.LocalPath(key)
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	tmpFile := dst + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmpFile)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return os.Rename(tmpFile, dst)
+}
+
+func (s *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.LocalPath(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	return f, err
+}
+
+func (s *LocalStorage) Stat(key string) (*ObjectInfo, error) {
+	info, err := os.Stat(s.LocalPath(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrNotExist
+	}
+	return &ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	err := os.Remove(s.LocalPath(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// List 列出前缀下所有对象, 前缀按目录/文件名前缀匹配
+func (s *LocalStorage) List(prefix string) (res []*ObjectInfo, err error) {
+	dir, namePrefix := prefix, ""
+	if !strings.HasSuffix(prefix, "/") {
+		dir, namePrefix = filepath.Split(filepath.FromSlash(prefix))
+	}
+	root := s.LocalPath(dir)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if os.IsNotExist(walkErr) {
+				return filepath.SkipDir
+			}
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, relErr := filepath.Rel(s.Root, p)
+		if relErr != nil {
+			return relErr
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, Key(dir, namePrefix)) {
+			return nil
+		}
+		info, infoErr := d.Info()
+		if infoErr != nil {
+			return infoErr
+		}
+		res = append(res, &ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return res, err
+}
+
+// Presign 本地存储无法生成临时访问地址, 由调用方直接读取
+func (s *LocalStorage) Presign(key string, expire time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+func (s *LocalStorage) Move(srcKey string, dstKey string) error {
+	dst := s.LocalPath(dstKey)
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	err := os.Rename(s.LocalPath(srcKey), dst)
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	if err != nil {
+		return err
+	}
+	// 与对象存储复制语义一致, 移动后的修改时间为移动时间, 回收站按此计算保留时长
+	now := time.Now()
+	return os.Chtimes(dst, now, now)
+}
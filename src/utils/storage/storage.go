@@ -0,0 +1,167 @@
+//@Author	AInoriex
+//@Desc		对象存储抽象, 支持本地文件系统及S3兼容存储
+
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	TypeLocal = "local" // 本地文件系统
+	TypeS3    = "s3"    // S3兼容存储, 如MinIO
+	TypeObs   = "obs"   // 华为OBS, 使用S3兼容接口
+
+	DefaultPresignExpire = 10 * time.Minute // 默认预签名地址有效时长
+)
+
+var (
+	ErrNotExist            = errors.New("storage: object not exist")
+	ErrPresignNotSupported = errors.New("storage: presign not supported")
+)
+
+// 对象信息
+type ObjectInfo struct {
+	Key     string    // 对象key, 如segments/xxx.m3u8
+	Size    int64     // 对象大小（Byte字节）
+	ModTime time.Time // 最后修改时间
+}
+
+// 对象存储接口
+type Storage interface {
+	// Put 写入对象
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象, 对象不存在时返回ErrNotExist
+	Get(key string) (io.ReadCloser, error)
+	// Stat 获取对象信息, 对象不存在时返回ErrNotExist
+	Stat(key string) (*ObjectInfo, error)
+	// Delete 删除对象, 对象不存在时不返回错误
+	Delete(key string) error
+	// List 列出前缀下所有对象
+	List(prefix string) ([]*ObjectInfo, error)
+	// Presign 生成对象的临时访问地址, 不支持时返回ErrPresignNotSupported
+	Presign(key string, expire time.Duration) (string, error)
+}
+
+// 对象移动接口, 存储实现支持时优先使用
+type mover interface {
+	Move(srcKey string, dstKey string) error
+}
+
+// 本地路径映射接口, 用于判断本地文件是否已位于存储中
+type localPather interface {
+	LocalPath(key string) string
+}
+
+// Key 拼接对象key
+// Key("segments", "xxx.m3u8") -> segments/xxx.m3u8
+func Key(elem ...string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Join(elem...)), "/")
+}
+
+// PutFile 将本地文件写入存储, 文件已位于本地存储对应位置时跳过
+func PutFile(s Storage, key string, localFile string, contentType string) error {
+	if lp, ok := s.(localPather); ok && samePath(lp.LocalPath(key), localFile) {
+		return nil
+	}
+	f, err := os.Open(localFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return s.Put(key, f, info.Size(), contentType)
+}
+
+// FetchFile 将存储中的对象下载到本地文件, 本地文件已存在时跳过
+func FetchFile(s Storage, key string, localFile string) error {
+	if _, err := os.Stat(localFile); err == nil {
+		return nil
+	}
+	r, err := s.Get(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err = os.MkdirAll(filepath.Dir(localFile), os.ModePerm); err != nil {
+		return err
+	}
+	tmpFile := localFile + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmpFile)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return os.Rename(tmpFile, localFile)
+}
+
+// ReadAll 读取对象全部内容
+func ReadAll(s Storage, key string) ([]byte, error) {
+	r, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Move 移动对象, 存储不支持时以复制后删除实现
+func Move(s Storage, srcKey string, dstKey string) error {
+	if m, ok := s.(mover); ok {
+		return m.Move(srcKey, dstKey)
+	}
+	info, err := s.Stat(srcKey)
+	if err != nil {
+		return err
+	}
+	r, err := s.Get(srcKey)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err = s.Put(dstKey, r, info.Size, ""); err != nil {
+		return err
+	}
+	return s.Delete(srcKey)
+}
+
+// IsLocal 判断存储是否为本地文件系统
+func IsLocal(s Storage) bool {
+	_, ok := s.(localPather)
+	return ok
+}
+
+// LocalPath 获取对象在本地存储中的路径, 非本地存储时返回false
+func LocalPath(s Storage, key string) (string, bool) {
+	lp, ok := s.(localPather)
+	if !ok {
+		return "", false
+	}
+	return lp.LocalPath(key), true
+}
+
+// 判断两个本地路径是否指向同一文件
+func samePath(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
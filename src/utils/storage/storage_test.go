@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 内存版S3兼容服务, 仅支持路径风格的基础对象操作
+func newFakeS3Server(t *testing.T, bucket string) *httptest.Server {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), s3Algorithm) && r.URL.Query().Get("X-Amz-Signature") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+
+		key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+bucket), "/")
+		switch {
+		case r.Method == http.MethodGet && key == "":
+			type content struct {
+				Key  string `xml:"Key"`
+				Size int64  `xml:"Size"`
+			}
+			result := struct {
+				XMLName  xml.Name  `xml:"ListBucketResult"`
+				Contents []content `xml:"Contents"`
+			}{}
+			keys := make([]string, 0)
+			for k := range objects {
+				if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				result.Contents = append(result.Contents, content{Key: k, Size: int64(len(objects[k]))})
+			}
+			xml.NewEncoder(w).Encode(result)
+		case r.Method == http.MethodPut:
+			if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+				data, ok := objects[strings.TrimPrefix(src, "/"+bucket+"/")]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				objects[key] = data
+				return
+			}
+			data, _ := io.ReadAll(r.Body)
+			objects[key] = data
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			data, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			if r.Method == http.MethodGet {
+				w.Write(data)
+			}
+		case r.Method == http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+}
+
+// 存储通用行为测试
+func testStorage(t *testing.T, s Storage) {
+	if err := s.Put("segments/a.m3u8", strings.NewReader("#EXTM3U"), 7, "application/vnd.apple.mpegurl"); err != nil {
+		t.Fatal("Put err=", err)
+	}
+	if err := s.Put("segments/a_0.ts", strings.NewReader("ts"), 2, ""); err != nil {
+		t.Fatal("Put err=", err)
+	}
+
+	data, err := ReadAll(s, "segments/a.m3u8")
+	if err != nil || string(data) != "#EXTM3U" {
+		t.Fatalf("ReadAll data=%q err=%v", data, err)
+	}
+
+	info, err := s.Stat("segments/a_0.ts")
+	if err != nil || info.Size != 2 {
+		t.Fatalf("Stat info=%+v err=%v", info, err)
+	}
+	if _, err = s.Stat("segments/missing.ts"); err != ErrNotExist {
+		t.Fatal("Stat missing err=", err)
+	}
+
+	list, err := s.List("segments/")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List len=%d err=%v", len(list), err)
+	}
+
+	if err = Move(s, "segments/a_0.ts", "recycle/a_0.ts"); err != nil {
+		t.Fatal("Move err=", err)
+	}
+	if _, err = s.Stat("recycle/a_0.ts"); err != nil {
+		t.Fatal("Stat moved err=", err)
+	}
+	if _, err = s.Stat("segments/a_0.ts"); err != ErrNotExist {
+		t.Fatal("Stat moved source err=", err)
+	}
+
+	if err = s.Delete("segments/a.m3u8"); err != nil {
+		t.Fatal("Delete err=", err)
+	}
+	if err = s.Delete("segments/a.m3u8"); err != nil {
+		t.Fatal("Delete missing err=", err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, NewLocalStorage(t.TempDir()))
+}
+
+func TestS3Storage(t *testing.T) {
+	server := newFakeS3Server(t, "eshop")
+	defer server.Close()
+	testStorage(t, NewS3Storage(server.URL, "", "eshop", "access", "secret", true))
+}
+
+func TestS3StoragePresign(t *testing.T) {
+	s := NewS3Storage("http://127.0.0.1:9000", "", "eshop", "access", "secret", true)
+	u, err := s.Presign("segments/a_0.ts", 0)
+	if err != nil {
+		t.Fatal("Presign err=", err)
+	}
+	if !strings.HasPrefix(u, "http://127.0.0.1:9000/eshop/segments/a_0.ts?") || !strings.Contains(u, "X-Amz-Signature=") {
+		t.Fatal("Presign url=", u)
+	}
+}
@@ -0,0 +1,48 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateCode(t *testing.T) {
+	// RFC 6238 附录B SHA1测试向量, 取后6位
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for ts, want := range cases {
+		got, err := GenerateCode(secret, ts/Period)
+		if err != nil || got != want {
+			t.Fatalf("unexpected code at %d, got: %s, want: %s, err: %v", ts, got, want, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	code, _ := GenerateCode(secret, now.Unix()/Period-1)
+	if step, ok := Validate(secret, code, now); !ok || step != now.Unix()/Period-1 {
+		t.Fatalf("expected previous step code to be valid, step: %d", step)
+	}
+	if _, ok := Validate(secret, code, now.Add(3*Period*time.Second)); ok {
+		t.Fatalf("expected expired code to be invalid")
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Fatalf("expected short code to be invalid")
+	}
+	if uri := KeyUri("eshop", "a@b.com", secret); !strings.HasPrefix(uri, "otpauth://totp/eshop:a@b.com?") {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+}
@@ -431,6 +431,18 @@ func RPop(con *redis.Client, key string) (string, error) {
 	return con.RPop(context.Background(), key).Result()
 }
 
+func RPopLPush(con *redis.Client, source string, destination string) (string, error) {
+	return con.RPopLPush(context.Background(), source, destination).Result()
+}
+
+func BRPopLPush(con *redis.Client, source string, destination string, timeout time.Duration) (string, error) {
+	return con.BRPopLPush(context.Background(), source, destination, timeout).Result()
+}
+
+func LRem(con *redis.Client, key string, count int64, value interface{}) error {
+	return con.LRem(context.Background(), key, count, value).Err()
+}
+
 func IsExists(redisClient *redis.Client, name string, key string) (bool, error) {
 	k := name + key
 	ENum, err := redisClient.Exists(context.Background(), k).Result()